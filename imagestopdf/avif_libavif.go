@@ -0,0 +1,171 @@
+//go:build avif && cgo
+
+package imagestopdf
+
+/*
+#cgo pkg-config: libavif
+#include <stdlib.h>
+#include <string.h>
+#include <avif/avif.h>
+
+// avif_probe reads the container headers and reports the image geometry.
+static const char *avif_probe(const uint8_t *data, size_t size,
+		uint32_t *width, uint32_t *height, uint32_t *depth) {
+	avifDecoder *decoder = avifDecoderCreate();
+	if (decoder == NULL) {
+		return "out of memory";
+	}
+	avifResult result = avifDecoderSetIOMemory(decoder, data, size);
+	if (result == AVIF_RESULT_OK) {
+		result = avifDecoderParse(decoder);
+	}
+	if (result != AVIF_RESULT_OK) {
+		avifDecoderDestroy(decoder);
+		return avifResultToString(result);
+	}
+	*width = decoder->image->width;
+	*height = decoder->image->height;
+	*depth = decoder->image->depth;
+	avifDecoderDestroy(decoder);
+	return NULL;
+}
+
+// avif_decode decodes the first frame into a malloc'd RGBA buffer. Sources
+// deeper than 8 bits are converted at 16 bits per channel so the caller can
+// reduce them to 8 bits with proper rounding.
+static const char *avif_decode(const uint8_t *data, size_t size, uint8_t **pixels,
+		uint32_t *width, uint32_t *height, uint32_t *depth, uint32_t *rowBytes) {
+	avifDecoder *decoder = avifDecoderCreate();
+	if (decoder == NULL) {
+		return "out of memory";
+	}
+	avifResult result = avifDecoderSetIOMemory(decoder, data, size);
+	if (result == AVIF_RESULT_OK) {
+		result = avifDecoderParse(decoder);
+	}
+	if (result == AVIF_RESULT_OK) {
+		result = avifDecoderNextImage(decoder);
+	}
+	if (result != AVIF_RESULT_OK) {
+		avifDecoderDestroy(decoder);
+		return avifResultToString(result);
+	}
+
+	avifRGBImage rgb;
+	avifRGBImageSetDefaults(&rgb, decoder->image);
+	rgb.format = AVIF_RGB_FORMAT_RGBA;
+	rgb.depth = decoder->image->depth > 8 ? 16 : 8;
+
+	result = avifRGBImageAllocatePixels(&rgb);
+	if (result == AVIF_RESULT_OK) {
+		result = avifImageYUVToRGB(decoder->image, &rgb);
+	}
+	if (result != AVIF_RESULT_OK) {
+		avifRGBImageFreePixels(&rgb);
+		avifDecoderDestroy(decoder);
+		return avifResultToString(result);
+	}
+
+	size_t length = (size_t)rgb.rowBytes * rgb.height;
+	*pixels = malloc(length);
+	if (*pixels == NULL) {
+		avifRGBImageFreePixels(&rgb);
+		avifDecoderDestroy(decoder);
+		return "out of memory";
+	}
+	memcpy(*pixels, rgb.pixels, length);
+
+	*width = rgb.width;
+	*height = rgb.height;
+	*depth = rgb.depth;
+	*rowBytes = rgb.rowBytes;
+
+	avifRGBImageFreePixels(&rgb);
+	avifDecoderDestroy(decoder);
+	return NULL;
+}
+*/
+import "C"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"unsafe"
+)
+
+// avifSupported reports whether this binary was built with an AVIF decoder
+const avifSupported = true
+
+func init() {
+	image.RegisterFormat("avif", "????ftypavif", decodeAVIF, decodeAVIFConfig)
+	image.RegisterFormat("avif", "????ftypavis", decodeAVIF, decodeAVIFConfig)
+}
+
+// decodeAVIFConfig returns the dimensions of an AVIF image without decoding pixel data
+func decodeAVIFConfig(r io.Reader) (image.Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	if len(data) == 0 {
+		return image.Config{}, errors.New("avif: empty input")
+	}
+
+	var width, height, depth C.uint32_t
+	if msg := C.avif_probe((*C.uint8_t)(unsafe.Pointer(&data[0])), C.size_t(len(data)), &width, &height, &depth); msg != nil {
+		return image.Config{}, fmt.Errorf("avif: %s", C.GoString(msg))
+	}
+
+	return image.Config{
+		ColorModel: color.NRGBAModel,
+		Width:      int(width),
+		Height:     int(height),
+	}, nil
+}
+
+// decodeAVIF decodes the first frame of an AVIF image into an 8-bit NRGBA image
+func decodeAVIF(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("avif: empty input")
+	}
+
+	var pixels *C.uint8_t
+	var width, height, depth, rowBytes C.uint32_t
+	if msg := C.avif_decode((*C.uint8_t)(unsafe.Pointer(&data[0])), C.size_t(len(data)),
+		&pixels, &width, &height, &depth, &rowBytes); msg != nil {
+		return nil, fmt.Errorf("avif: %s", C.GoString(msg))
+	}
+	defer C.free(unsafe.Pointer(pixels))
+
+	w, h, stride := int(width), int(height), int(rowBytes)
+	src := unsafe.Slice((*byte)(unsafe.Pointer(pixels)), stride*h)
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+
+	if depth == 8 {
+		for y := 0; y < h; y++ {
+			copy(img.Pix[y*img.Stride:y*img.Stride+w*4], src[y*stride:y*stride+w*4])
+		}
+		return img, nil
+	}
+
+	// 10/12-bit sources come back as native-endian 16-bit samples. Round to
+	// the nearest 8-bit value instead of truncating to avoid visible banding.
+	for y := 0; y < h; y++ {
+		row := src[y*stride:]
+		dst := img.Pix[y*img.Stride:]
+		for i := 0; i < w*4; i++ {
+			v := uint32(binary.NativeEndian.Uint16(row[i*2:]))
+			dst[i] = uint8((v*255 + 32767) / 65535)
+		}
+	}
+
+	return img, nil
+}
@@ -0,0 +1,7 @@
+//go:build !avif || !cgo
+
+package imagestopdf
+
+// avifSupported reports whether this binary was built with an AVIF decoder.
+// Build with -tags avif (requires cgo and libavif) to enable it.
+const avifSupported = false
@@ -0,0 +1,123 @@
+package imagestopdf
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// unstablePlaceholders change between runs, so --resume can't find earlier batches named with them
+var unstablePlaceholders = []string{"{n}", "{count}", "{date}", "{time}"}
+
+// validateBatches checks the --batch-size and --resume combination
+func validateBatches(o Options) error {
+	if o.BatchSize < 0 {
+		return fmt.Errorf("invalid batch size %d, must not be negative", o.BatchSize)
+	}
+	if o.BatchSize == 0 {
+		if o.Resume {
+			return fmt.Errorf("--resume only applies to --batch-size runs")
+		}
+		return nil
+	}
+	if o.InputDir2 != "" {
+		return fmt.Errorf("--batch-size can't be combined with --input2, fronts and backs are interleaved as a whole")
+	}
+	if o.Resume {
+		for _, placeholder := range unstablePlaceholders {
+			if strings.Contains(o.Name, placeholder) {
+				return fmt.Errorf("--resume needs an output name that is the same on every run, %s in %q is not", placeholder, o.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// batchName is the output name of one batch, its 1-based number goes before the extension:
+// scans.pdf becomes scans_part001.pdf, scans_part002.pdf and so on
+func batchName(name string, part int) string {
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s_part%03d%s", strings.TrimSuffix(name, ext), part, ext)
+}
+
+// batchDone reports whether a batch's PDF exists and was written after every one of its images
+// was last modified. Batches are saved through a .partial file that is only renamed into place
+// once complete, so an interrupted batch never leaves a PDF behind and is converted again.
+func batchDone(outputPath string, images []string) bool {
+	output, err := os.Stat(longPath(outputPath))
+	if err != nil {
+		return false
+	}
+	for _, imagePath := range images {
+		info, err := os.Stat(longPath(imagePath))
+		if err != nil || !info.ModTime().Before(output.ModTime()) {
+			return false
+		}
+	}
+	return true
+}
+
+// convertInBatches discovers the images once and converts them --batch-size at a time,
+// each batch into its own numbered PDF with its own manifest and report
+func convertInBatches(ctx context.Context, opts Options) error {
+	images, err := discoverImages(ctx, opts.Inputs, newRetrier(opts.Retries), opts)
+	if err != nil {
+		return err
+	}
+	if images, err = pageOrder(images, opts); err != nil {
+		return err
+	}
+
+	var batches [][]string
+	for start := 0; start < len(images); start += opts.BatchSize {
+		batches = append(batches, images[start:min(start+opts.BatchSize, len(images))])
+	}
+	fmt.Fprintf(opts.status(), "Found %d image files, converting in %d batch(es) of up to %d\n", len(images), len(batches), opts.BatchSize)
+
+	// Named outputs are known up front, so an existing one fails before any batch is converted
+	if opts.NoOverwrite && !opts.Resume && !strings.Contains(opts.Name, "{") {
+		for part := range batches {
+			path := filepath.Join(opts.OutputDir, batchName(opts.Name, part+1))
+			if _, err := os.Stat(path); err == nil {
+				return fmt.Errorf("%w: %s", ErrOutputExists, path)
+			}
+		}
+	}
+
+	written, skipped := 0, 0
+	for i, batch := range batches {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		batchOpts := opts
+		batchOpts.batch = batch
+		batchOpts.Name = batchName(opts.Name, i+1)
+		if opts.ManifestPath != "" && opts.ManifestPath != defaultManifestPath {
+			batchOpts.ManifestPath = batchName(opts.ManifestPath, i+1)
+		}
+		if opts.ReportPath != "" && opts.ReportPath != defaultReportPath {
+			batchOpts.ReportPath = batchName(opts.ReportPath, i+1)
+		}
+
+		if opts.Resume {
+			outputPath := filepath.Join(opts.OutputDir, expandNameTemplate(batchOpts.Name, primaryInputDir(opts.Inputs), opts.OutputDir, 0, opts.now()))
+			if batchDone(outputPath, batch) {
+				fmt.Fprintf(opts.status(), "Batch %d/%d is up to date, skipping: %s\n", i+1, len(batches), outputPath)
+				skipped++
+				continue
+			}
+		}
+
+		fmt.Fprintf(opts.status(), "Batch %d/%d: %s to %s\n", i+1, len(batches), filepath.Base(batch[0]), filepath.Base(batch[len(batch)-1]))
+		if err := writePDF(ctx, batchOpts); err != nil {
+			return fmt.Errorf("batch %d/%d: %w", i+1, len(batches), err)
+		}
+		written++
+	}
+
+	fmt.Fprintf(opts.status(), "Wrote %d PDF(s), skipped %d finished batch(es)\n", written, skipped)
+	return nil
+}
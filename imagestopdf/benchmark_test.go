@@ -0,0 +1,77 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+// Benchmarks run on generated images the size of 12 megapixel phone photos. Compare runs with
+// benchstat, and profile a real conversion with --cpuprofile and --memprofile.
+
+// phonePhoto returns a 4000x3000 photo as the JPEG decoder hands it over, in YCbCr
+func phonePhoto(b *testing.B) image.Image {
+	b.Helper()
+	img, err := jpeg.Decode(bytes.NewReader(encodeJPEG(b, photoImage(4000, 3000, 1), 90)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return img
+}
+
+func BenchmarkScaleImageToWidth(b *testing.B) {
+	img := phonePhoto(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scaleImageToWidth(img, optimizedWidth)
+	}
+}
+
+func BenchmarkConvertPNGToOptimalJPEG(b *testing.B) {
+	img := scaleImageToWidth(translucentImage(4000, 3000), optimizedWidth)
+	bounds := img.Bounds()
+	output := filepath.Join(b.TempDir(), "out.jpg")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := convertPNGToOptimalJPEG(img, output, bounds.Dx()*bounds.Dy(), 0, colorWhite); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkCompressImageWithTargetSize encodes a scaled photo under a limit only the lowest
+// quality meets, so every attempt runs
+func BenchmarkCompressImageWithTargetSize(b *testing.B) {
+	img := scaleImageToWidth(phonePhoto(b), optimizedWidth)
+	bounds := img.Bounds()
+	output := filepath.Join(b.TempDir(), "out.jpg")
+	encode := jpegEncoder("optimize_jpeg", img, output, bounds.Dx()*bounds.Dy(), nil, Options{})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := compressImageWithTargetSize(io.Discard, encode, 95, 1024); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkConvert runs the whole pipeline over a small corpus: phone photos, a PNG photo and
+// a screenshot
+func BenchmarkConvert(b *testing.B) {
+	dir := b.TempDir()
+	for i := 0; i < 6; i++ {
+		writeJPEG(b, filepath.Join(dir, fmt.Sprintf("photo%d.jpg", i)), photoImage(4000, 3000, int64(i)), 90)
+	}
+	writePNG(b, filepath.Join(dir, "photo.png"), photoImage(2000, 1500, 7))
+	writePNG(b, filepath.Join(dir, "screenshot.png"), screenshotImage(1920, 1080, true))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Convert(context.Background(), io.Discard, Options{Inputs: []string{dir}, Status: io.Discard}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package imagestopdf
+
+import (
+	"image"
+)
+
+// bayer8 is the 8x8 ordered dithering matrix, values 0-63
+var bayer8 = [8][8]uint32{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+// isHighBitDepth reports whether the decoder returned more than 8 bits per channel
+func isHighBitDepth(img image.Image) bool {
+	switch img.(type) {
+	case *image.NRGBA64, *image.RGBA64, *image.Gray16:
+		return true
+	}
+	return false
+}
+
+// reduceBitDepth converts 16-bit images to 8 bits per channel. Values are rounded to the nearest
+// level, or with dither set spread over neighboring levels using an ordered (Bayer) pattern so
+// smooth gradients don't turn into visible bands. Other images are returned unchanged.
+func reduceBitDepth(img image.Image, dither bool) image.Image {
+	// threshold returns the offset, in 1/65535 units of an 8-bit level, added before truncating
+	threshold := func(x, y int) uint32 {
+		if !dither {
+			return 32767
+		}
+		return (bayer8[y&7][x&7]*2 + 1) * 65535 / 128
+	}
+	to8 := func(v uint16, t uint32) uint8 {
+		q := (uint32(v)*255 + t) / 65535
+		if q > 255 {
+			q = 255
+		}
+		return uint8(q)
+	}
+
+	bounds := img.Bounds()
+	switch src := img.(type) {
+	case *image.Gray16:
+		dst := image.NewGray(bounds)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				i := src.PixOffset(x, y)
+				v := uint16(src.Pix[i])<<8 | uint16(src.Pix[i+1])
+				dst.Pix[dst.PixOffset(x, y)] = to8(v, threshold(x, y))
+			}
+		}
+		return dst
+
+	case *image.NRGBA64:
+		dst := image.NewNRGBA(bounds)
+		reduceChannels(src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y):], src.Stride, dst.Pix, dst.Stride, bounds, threshold, to8)
+		return dst
+
+	case *image.RGBA64:
+		dst := image.NewRGBA(bounds)
+		reduceChannels(src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y):], src.Stride, dst.Pix, dst.Stride, bounds, threshold, to8)
+		// Dithering the color but not the alpha of premultiplied pixels can push a channel above alpha
+		for i := 0; i+3 < len(dst.Pix); i += 4 {
+			for c := 0; c < 3; c++ {
+				if dst.Pix[i+c] > dst.Pix[i+3] {
+					dst.Pix[i+c] = dst.Pix[i+3]
+				}
+			}
+		}
+		return dst
+	}
+
+	return img
+}
+
+// reduceChannels converts big-endian 16-bit RGBA samples to 8 bits, dithering color but only rounding alpha
+func reduceChannels(src []byte, srcStride int, dst []byte, dstStride int, bounds image.Rectangle,
+	threshold func(x, y int) uint32, to8 func(v uint16, t uint32) uint8) {
+	for y := 0; y < bounds.Dy(); y++ {
+		srcRow := src[y*srcStride:]
+		dstRow := dst[y*dstStride:]
+		for x := 0; x < bounds.Dx(); x++ {
+			t := threshold(bounds.Min.X+x, bounds.Min.Y+y)
+			for c := 0; c < 4; c++ {
+				v := uint16(srcRow[x*8+c*2])<<8 | uint16(srcRow[x*8+c*2+1])
+				if c == 3 {
+					dstRow[x*4+c] = to8(v, 32767)
+				} else {
+					dstRow[x*4+c] = to8(v, t)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package imagestopdf
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// TestReduceBitDepthRounds converts every 16-bit value once and expects the nearest 8-bit level
+func TestReduceBitDepthRounds(t *testing.T) {
+	src := image.NewGray16(image.Rect(0, 0, 256, 256))
+	for v := 0; v <= math.MaxUint16; v++ {
+		src.SetGray16(v%256, v/256, color.Gray16{Y: uint16(v)})
+	}
+	dst, ok := reduceBitDepth(src, false).(*image.Gray)
+	if !ok {
+		t.Fatalf("got %T, want *image.Gray", reduceBitDepth(src, false))
+	}
+	for v := 0; v <= math.MaxUint16; v++ {
+		want := uint8(math.Round(float64(v) * 255 / 65535))
+		if got := dst.GrayAt(v%256, v/256).Y; got != want {
+			t.Fatalf("16-bit %d became %d, want %d", v, got, want)
+		}
+	}
+
+	// Color and alpha round the same way, alpha is never dithered
+	rgba := image.NewNRGBA64(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			rgba.SetNRGBA64(x, y, color.NRGBA64{R: 0xffff, G: 128, B: 129, A: 257 * 100})
+		}
+	}
+	for _, dither := range []bool{false, true} {
+		out := reduceBitDepth(rgba, dither).(*image.NRGBA)
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				c := out.NRGBAAt(x, y)
+				if c.R != 255 || c.A != 100 {
+					t.Fatalf("dither %v: (%d,%d) is %v, want red 255 and alpha 100", dither, x, y, c)
+				}
+				if !dither && (c.G != 0 || c.B != 1) {
+					t.Fatalf("(%d,%d) is %v, want green 0 and blue 1", x, y, c)
+				}
+			}
+		}
+	}
+
+	// 8-bit images are left alone
+	gray := image.NewGray(image.Rect(0, 0, 1, 1))
+	if reduceBitDepth(gray, true) != image.Image(gray) {
+		t.Error("an 8-bit image was converted")
+	}
+}
+
+// TestReduceBitDepthDitherAvoidsBanding reduces a 16-bit gradient spanning two 8-bit levels. Rounded,
+// it comes out as three flat bands; dithered, the average over each 8x8 tile follows the gradient
+// without flat steps.
+func TestReduceBitDepthDitherAvoidsBanding(t *testing.T) {
+	const width, height, low = 512, 64, 100
+	src := image.NewGray16(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			src.SetGray16(x, y, color.Gray16{Y: uint16(low*257 + 2*257*x/(width-1))})
+		}
+	}
+
+	// tileMeans averages the 8x8 tiles of the first tile row, in 8-bit levels
+	tileMeans := func(img *image.Gray) []float64 {
+		means := make([]float64, width/8)
+		for i := range means {
+			var sum float64
+			for y := 0; y < 8; y++ {
+				for x := i * 8; x < i*8+8; x++ {
+					sum += float64(img.GrayAt(x, y).Y)
+				}
+			}
+			means[i] = sum / 64
+		}
+		return means
+	}
+	// longestFlat is the longest run of equal tile means
+	longestFlat := func(means []float64) int {
+		longest, run := 1, 1
+		for i := 1; i < len(means); i++ {
+			if means[i] == means[i-1] {
+				run++
+			} else {
+				run = 1
+			}
+			longest = max(longest, run)
+		}
+		return longest
+	}
+
+	rounded := tileMeans(reduceBitDepth(src, false).(*image.Gray))
+	dithered := tileMeans(reduceBitDepth(src, true).(*image.Gray))
+
+	if flat := longestFlat(rounded); flat < len(rounded)/4 {
+		t.Errorf("rounded gradient has flat runs of at most %d tiles, want a band of at least %d", flat, len(rounded)/4)
+	}
+	if flat := longestFlat(dithered); flat > 2 {
+		t.Errorf("dithered gradient has a flat run of %d tiles, want at most 2", flat)
+	}
+	for i, mean := range dithered {
+		if i > 0 && mean < dithered[i-1] {
+			t.Errorf("dithered tile %d averages %.3f, below the %.3f before it", i, mean, dithered[i-1])
+		}
+		// The source level at the tile's center
+		want := low + 2*(float64(i*8)+3.5)/(width-1)
+		if math.Abs(mean-want) > 0.1 {
+			t.Errorf("dithered tile %d averages %.3f, want %.3f", i, mean, want)
+		}
+	}
+}
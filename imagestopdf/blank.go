@@ -0,0 +1,51 @@
+package imagestopdf
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// blankPageList holds the source image names that should be followed by a blank page
+type blankPageList map[string]bool
+
+// loadBlankPageList reads a --insert-blank file. Each non-empty line names a source image,
+// either by base name or by its path relative to the input directory. Lines starting with # are comments.
+func loadBlankPageList(path string) (blankPageList, error) {
+	list := blankPageList{}
+	if path == "" {
+		return list, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list[filepath.ToSlash(filepath.Clean(line))] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return list, nil
+}
+
+// matches reports whether a blank page should follow the given source image
+func (l blankPageList) matches(sourcePath, inputDir string) bool {
+	for _, key := range sourceNameKeys(sourcePath, inputDir) {
+		if l[key] {
+			return true
+		}
+	}
+	return false
+}
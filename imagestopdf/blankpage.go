@@ -0,0 +1,127 @@
+package imagestopdf
+
+import (
+	"fmt"
+	"image"
+	"slices"
+)
+
+const (
+	// blankSampleWidth is the largest width of the grayscale copy blankness is measured on
+	blankSampleWidth = 512
+	// blankGridColumns is the width of the grid blankness is measured on, cells are square
+	blankGridColumns = 128
+)
+
+// blankPageError reports a page dropped by --skip-blank
+type blankPageError struct {
+	score float64 // percentage of cells matching the background
+}
+
+func (e *blankPageError) Error() string {
+	return fmt.Sprintf("page is blank (%.2f%% background)", e.score)
+}
+
+// blankScore returns the percentage of the page that is plain background, measured on the
+// grayscale copy from blankSample. The copy is divided into a grid, and a cell counts as background
+// only if none of its pixels' brightness differs from the dominant (paper) brightness by more than
+// tolerance. Working with the largest difference per cell rather than an average keeps thin, faint
+// strokes such as pencil from being averaged away, while a few dust specks only affect their cells.
+func blankScore(img image.Image, tolerance int) float64 {
+	gray, paper := blankSample(img)
+	width, height := gray.Rect.Dx(), gray.Rect.Dy()
+	if width == 0 || height == 0 {
+		return 100
+	}
+
+	cell := max(1, width/blankGridColumns)
+	cols := (width + cell - 1) / cell
+	rows := (height + cell - 1) / cell
+
+	deviation := make([]int, cols*rows)
+	for y := 0; y < height; y++ {
+		rowCells := deviation[(y/cell)*cols:]
+		for x, v := range gray.Pix[y*gray.Stride : y*gray.Stride+width] {
+			d := int(v) - paper
+			if d < 0 {
+				d = -d
+			}
+			if d > rowCells[x/cell] {
+				rowCells[x/cell] = d
+			}
+		}
+	}
+
+	background := 0
+	for _, d := range deviation {
+		if d <= tolerance {
+			background++
+		}
+	}
+	return float64(background) / float64(len(deviation)) * 100
+}
+
+// blankSample returns a grayscale copy of img at most blankSampleWidth wide and the dominant paper
+// brightness. Each pixel of the copy holds the brightness in its block that is furthest from the
+// paper's, so a pencil stroke thinner than a block keeps its full contrast instead of being
+// averaged into the paper.
+func blankSample(img image.Image) (*image.Gray, int) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return image.NewGray(image.Rectangle{}), 0
+	}
+
+	sample := rgbaSampler(img)
+	var px [4]byte
+	luma := func(x, y int) int {
+		sample(x, y, px[:])
+		return (299*int(px[0]) + 587*int(px[1]) + 114*int(px[2])) / 1000
+	}
+
+	block := max(1, (width+blankSampleWidth-1)/blankSampleWidth)
+	paper := dominantLuma(bounds, block, luma)
+	gray := image.NewGray(image.Rect(0, 0, (width+block-1)/block, (height+block-1)/block))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(paper)
+	}
+	furthest := make([]int, len(gray.Pix))
+	for y := 0; y < height; y++ {
+		row := (y / block) * gray.Stride
+		for x := 0; x < width; x++ {
+			v := luma(bounds.Min.X+x, bounds.Min.Y+y)
+			d := v - paper
+			if d < 0 {
+				d = -d
+			}
+			if i := row + x/block; d > furthest[i] {
+				furthest[i] = d
+				gray.Pix[i] = uint8(v)
+			}
+		}
+	}
+	return gray, paper
+}
+
+// dominantLuma returns the most common brightness, the paper color of a scan, from one sample per cell
+func dominantLuma(bounds image.Rectangle, cell int, luma func(x, y int) int) int {
+	var histogram [256]int
+	for y := bounds.Min.Y + cell/2; y < bounds.Max.Y; y += cell {
+		for x := bounds.Min.X + cell/2; x < bounds.Max.X; x += cell {
+			histogram[luma(x, y)]++
+		}
+	}
+	dominant := 0
+	for v, count := range histogram {
+		if count > histogram[dominant] {
+			dominant = v
+		}
+	}
+	return dominant
+}
+
+// dropBlankPages removes the images --skip-blank found blank. They stay in the list until then so
+// interleaving pairs every front with the back scanned with it, a blank back only drops that page.
+func dropBlankPages(images []optimizedImage) []optimizedImage {
+	return slices.DeleteFunc(images, func(img optimizedImage) bool { return img.blank })
+}
@@ -0,0 +1,87 @@
+package imagestopdf
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+// Scan fixtures are letter pages at 200 DPI: light gray paper with scanner noise
+const (
+	scanWidth, scanHeight = 1700, 2200
+	scanPaper             = 235
+)
+
+// scanPage returns a blank scanned page, draw adds content on top
+func scanPage(seed int64, draw func(img *image.Gray)) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, scanWidth, scanHeight))
+	rng := rand.New(rand.NewSource(seed))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(scanPaper + rng.Intn(13) - 6)
+	}
+	if draw != nil {
+		draw(img)
+	}
+	return img
+}
+
+// faintText draws a few lines of light pencil handwriting, 2 pixels thick
+func faintText(img *image.Gray) {
+	for line := 0; line < 6; line++ {
+		y0 := 300 + line*60
+		for x := 200; x < 1300; x++ {
+			y := y0 + int(8*float64(x%37)/37)
+			img.SetGray(x, y, color.Gray{Y: 200})
+			img.SetGray(x, y+1, color.Gray{Y: 200})
+		}
+	}
+}
+
+// punchedHoles draws the shadows of three 6 mm punch holes along the left edge
+func punchedHoles(img *image.Gray) {
+	const radius = 24
+	for _, cy := range []int{500, 1100, 1700} {
+		for y := cy - radius; y <= cy+radius; y++ {
+			for x := 80 - radius; x <= 80+radius; x++ {
+				if (x-80)*(x-80)+(y-cy)*(y-cy) <= radius*radius {
+					img.SetGray(x, y, color.Gray{Y: 60})
+				}
+			}
+		}
+	}
+}
+
+func TestBlankScore(t *testing.T) {
+	defaults := defaultOptions()
+	for _, tc := range []struct {
+		name  string
+		img   image.Image
+		blank bool
+	}{
+		{"blank", scanPage(1, nil), true},
+		{"faint text", scanPage(2, faintText), false},
+		{"punched holes", scanPage(3, punchedHoles), true},
+		{"white", solidImage(400, 300, colorWhite), true},
+		{"photo", photoImage(400, 300, 1), false},
+	} {
+		score := blankScore(tc.img, defaults.BlankTolerance)
+		if blank := score >= defaults.BlankThreshold; blank != tc.blank {
+			t.Errorf("%s: score %.2f%%, blank %v, want %v", tc.name, score, blank, tc.blank)
+		}
+	}
+}
+
+// TestSkipBlank drops the blank pages of a scan batch and keeps the faint one
+func TestSkipBlank(t *testing.T) {
+	dir := t.TempDir()
+	writeJPEG(t, filepath.Join(dir, "1.jpg"), scanPage(1, nil), 85)
+	writeJPEG(t, filepath.Join(dir, "2.jpg"), scanPage(2, faintText), 85)
+	writeJPEG(t, filepath.Join(dir, "3.jpg"), scanPage(3, punchedHoles), 85)
+
+	pdf := convertForTest(t, Options{Inputs: []string{dir}, SkipBlank: true})
+	if pages, ok := pdfPageCount(pdf); !ok || pages != 1 {
+		t.Errorf("got %d page(s), want only the faint page", pages)
+	}
+}
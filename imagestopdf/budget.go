@@ -0,0 +1,219 @@
+package imagestopdf
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// budgetModeValues are the accepted --budget-mode values
+var budgetModeValues = []string{"per-image", "global"}
+
+const (
+	// minBudgetQuality and maxBudgetQuality bound the JPEG quality the size budget may pick
+	minBudgetQuality = 20
+	maxBudgetQuality = 95
+	// budgetProbeStep is how much lower the second encode of an image in global mode is
+	budgetProbeStep = 20
+	// budgetRounds and budgetTolerance limit how often and how closely global mode fits the budget
+	budgetRounds    = 3
+	budgetTolerance = 0.03
+	// pdfPageOverhead estimates the bytes a page adds beside its image: page, content and image objects
+	pdfPageOverhead = 1024
+)
+
+// validateBudgetMode checks --budget-mode and its combination with --max-size and --quality
+func validateBudgetMode(o Options) error {
+	if o.MaxSize < 0 {
+		return fmt.Errorf("invalid maximum size %d, must not be negative", o.MaxSize)
+	}
+	switch o.BudgetMode {
+	case "per-image":
+		return nil
+	case "global":
+		if o.MaxSize == 0 {
+			return fmt.Errorf("--budget-mode global needs --max-size")
+		}
+		if o.Quality > 0 {
+			return fmt.Errorf("--budget-mode global picks the quality per image, it can't be combined with --quality")
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid budget mode %q, valid values are: per-image, global", o.BudgetMode)
+}
+
+// isJPEGStrategy reports whether the strategy re-encodes the image as JPEG, whose size the budget controls
+func isJPEGStrategy(strategy string) bool {
+	return strategy == "optimize_jpeg" || strategy == "convert_png_to_jpeg" || strategy == "convert_avif_to_jpeg"
+}
+
+// jpegEncoder returns a function writing the image again at another quality, the way the strategy
+// did the first time including the ICC profile, and returning the new file size
+func jpegEncoder(strategy string, img image.Image, outputPath string, totalPixels int, iccProfile []byte, opts Options) func(quality int) (int64, error) {
+	return func(quality int) (int64, error) {
+		var err error
+		if strategy == "optimize_jpeg" {
+			err = compressToOptimalJPEG(img, outputPath, totalPixels, quality)
+		} else {
+			err = convertPNGToOptimalJPEG(img, outputPath, totalPixels, quality, opts.Background)
+		}
+		if err == nil && iccProfile != nil {
+			err = embedICCProfile(outputPath, iccProfile)
+		}
+		if err != nil {
+			return 0, err
+		}
+		info, err := os.Stat(longPath(outputPath))
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+}
+
+// qualityProbe models how an image's JPEG size depends on the quality, from two encodes:
+// the logarithm of the size grows by slope for every quality step
+type qualityProbe struct {
+	quality int
+	size    int64
+	slope   float64
+}
+
+// probeJPEGQuality encodes the image a second time, budgetProbeStep below the quality it was
+// written with, and fits the size model to both. The file is left at the lower quality.
+func probeJPEGQuality(encode func(quality int) (int64, error), quality int, size int64) (*qualityProbe, int64, int, error) {
+	lower := max(quality-budgetProbeStep, minBudgetQuality)
+	if lower >= quality {
+		return &qualityProbe{quality: quality, size: size, slope: 0.02}, size, quality, nil
+	}
+	lowerSize, err := encode(lower)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	// Sizes barely change for tiny or flat images, a small positive slope keeps the model usable
+	slope := math.Log(float64(size)/float64(lowerSize)) / float64(quality-lower)
+	if math.IsNaN(slope) || slope < 0.001 {
+		slope = 0.001
+	}
+	return &qualityProbe{quality: quality, size: size, slope: slope}, lowerSize, lower, nil
+}
+
+// qualityFor is the quality at which the image is expected to take scale times its probed size
+func (p qualityProbe) qualityFor(scale float64) int {
+	quality := p.quality + int(math.Round(math.Log(scale)/p.slope))
+	return min(max(quality, minBudgetQuality), maxBudgetQuality)
+}
+
+// refit moves the model to a new encode at another quality, with the slope between the two
+func (p qualityProbe) refit(quality int, size int64) *qualityProbe {
+	if quality == p.quality || size <= 0 {
+		return &p
+	}
+	slope := math.Log(float64(size)/float64(p.size)) / float64(quality-p.quality)
+	if math.IsNaN(slope) || slope < 0.001 {
+		slope = 0.001
+	}
+	return &qualityProbe{quality: quality, size: size, slope: slope}
+}
+
+// sizeAt is the expected size of the image encoded at quality
+func (p qualityProbe) sizeAt(quality int) float64 {
+	return float64(p.size) * math.Exp(p.slope*float64(quality-p.quality))
+}
+
+// allocateGlobalBudget spreads --max-size over the re-encoded JPEGs for --budget-mode global.
+// Every probed image is scaled by the same factor relative to its probed size, so complex pages
+// keep proportionally more bytes than simple ones. The factor is found by bisection on the size
+// models, then each image whose quality changes is converted once more at its new quality. The
+// models extrapolate poorly far from the probes, so when the result misses the budget by more than
+// budgetTolerance they are refitted to the new sizes and the spread is repeated, up to budgetRounds times.
+// Kept originals, PNGs and the PDF structure count against the budget as they are.
+func allocateGlobalBudget(ctx context.Context, images []optimizedImage, rotations map[string]int, budget *memoryBudget, retry *retrier, opts Options) ([]optimizedImage, error) {
+	fixed := int64(len(images)) * pdfPageOverhead
+	probes := map[int]*qualityProbe{}
+	for i, img := range images {
+		if img.probe != nil {
+			probes[i] = img.probe
+		} else {
+			fixed += img.size
+		}
+	}
+	if len(probes) == 0 {
+		logger.Info("no re-encoded JPEGs to fit into --max-size, the budget is left as it is")
+		return images, nil
+	}
+	probed := make([]int, 0, len(probes))
+	for i := range probes {
+		probed = append(probed, i)
+	}
+	sort.Ints(probed)
+
+	available := float64(opts.MaxSize - fixed)
+	total := func(logScale float64) (sum float64) {
+		for _, i := range probed {
+			sum += probes[i].sizeAt(probes[i].qualityFor(math.Exp(logScale)))
+		}
+		return sum
+	}
+
+	// The second pass converts with a fixed quality and no budget of its own
+	passOpts := opts
+	passOpts.BudgetMode, passOpts.MaxSize, passOpts.imageBudget = "per-image", 0, 0
+	for round := 1; ; round++ {
+		low, high := -10.0, 10.0
+		if total(low) > available {
+			logger.Warn("--max-size is too small for these images, using the lowest quality", "max_size", opts.MaxSize, "fixed_bytes", fixed)
+			high = low
+		}
+		for step := 0; step < 60 && high-low > 1e-6; step++ {
+			if middle := (low + high) / 2; total(middle) > available {
+				high = middle
+			} else {
+				low = middle
+			}
+		}
+
+		var changed []int
+		lowest, highest := maxBudgetQuality, minBudgetQuality
+		for _, i := range probed {
+			quality := probes[i].qualityFor(math.Exp(low))
+			lowest, highest = min(lowest, quality), max(highest, quality)
+			if quality != images[i].quality {
+				changed = append(changed, i)
+			}
+		}
+		fmt.Fprintf(opts.status(), "Spreading %.2f MB over %d JPEG image(s): quality %d to %d, %.2f MB expected\n",
+			megabytes(int64(available)), len(probed), lowest, highest, megabytes(int64(total(low))))
+
+		for n, i := range changed {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			img := images[i]
+			passOpts.Quality = probes[i].qualityFor(math.Exp(low))
+			fmt.Fprintf(opts.status(), "Re-encoding %d/%d at quality %d: %s\n", n+1, len(changed), passOpts.Quality, filepath.Base(img.sourcePath))
+			converted, err := convertToEfficientCompression(ctx, img.sourcePath, filepath.Dir(img.path), rotations[img.sourcePath], budget, retry, passOpts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to re-encode %s: %w", img.sourcePath, err)
+			}
+			converted.inputDir = img.inputDir
+			converted.quality = passOpts.Quality
+			images[i] = converted
+			probes[i] = probes[i].refit(converted.quality, converted.size)
+		}
+
+		var actual int64
+		for _, i := range probed {
+			actual += images[i].size
+		}
+		if len(changed) == 0 || round == budgetRounds || math.Abs(float64(actual)-available) <= available*budgetTolerance {
+			return images, nil
+		}
+		logger.Debug("budget missed, refitting size models", "round", round, "expected_bytes", int64(available), "actual_bytes", actual)
+	}
+}
@@ -0,0 +1,50 @@
+package imagestopdf
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// TestGlobalBudget fits a mixed set of photos, a PNG and a small kept JPEG into --max-size and
+// checks the PDF lands within 5% of it
+func TestGlobalBudget(t *testing.T) {
+	dir := t.TempDir()
+	writeJPEG(t, filepath.Join(dir, "1.jpg"), photoImage(1600, 1200, 1), 95)
+	writeJPEG(t, filepath.Join(dir, "2.jpg"), photoImage(1200, 1600, 2), 90)
+	writeJPEG(t, filepath.Join(dir, "3.jpg"), gradientImage(1600, 1000), 95)
+	writePNG(t, filepath.Join(dir, "4.png"), photoImage(1000, 800, 3))
+	writeJPEG(t, filepath.Join(dir, "5.jpg"), photoImage(300, 200, 4), 80)
+
+	unbudgeted := len(convertForTest(t, Options{Inputs: []string{dir}}))
+	for _, share := range []float64{0.4, 0.7} {
+		maxSize := int64(float64(unbudgeted) * share)
+		pdf := convertForTest(t, Options{Inputs: []string{dir}, BudgetMode: "global", MaxSize: maxSize})
+		if miss := math.Abs(float64(len(pdf))-float64(maxSize)) / float64(maxSize); miss > 0.05 {
+			t.Errorf("budget %d bytes (%.0f%% of %d): got %d bytes, %.1f%% off", maxSize, share*100, unbudgeted, len(pdf), miss*100)
+		}
+	}
+}
+
+func TestQualityProbe(t *testing.T) {
+	// Sizes double every 10 quality steps
+	encode := func(quality int) (int64, error) {
+		return int64(100000 * math.Exp2(float64(quality-80)/10)), nil
+	}
+	probe, size, quality, err := probeJPEGQuality(encode, 80, 100000)
+	if err != nil || quality != 80-budgetProbeStep || size != 25000 {
+		t.Fatalf("probe left quality %d, %d bytes, %v", quality, size, err)
+	}
+	if math.Abs(probe.slope-math.Ln2/10) > 1e-9 {
+		t.Errorf("slope %g, want %g", probe.slope, math.Ln2/10)
+	}
+	if q := probe.qualityFor(0.5); q != 70 {
+		t.Errorf("half the size at quality %d, want 70", q)
+	}
+	if q := probe.qualityFor(1e-6); q != minBudgetQuality {
+		t.Errorf("tiny scale gave quality %d, want the minimum", q)
+	}
+	if q := probe.qualityFor(1e6); q != maxBudgetQuality {
+		t.Errorf("huge scale gave quality %d, want the maximum", q)
+	}
+}
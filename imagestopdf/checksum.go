@@ -0,0 +1,117 @@
+package imagestopdf
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// checksumSuffix is appended to the PDF's path to name its --checksum sidecar
+const checksumSuffix = ".sha256"
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify <pdf>...",
+	Short: "Check PDFs against the .sha256 files written by --checksum",
+	Long: `Recomputes the SHA-256 of each PDF and compares it with the <pdf>.sha256 sidecar next to it.
+Exits with a non-zero status if a sidecar is missing or any hash doesn't match.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var failed error
+		for _, path := range args {
+			if err := verifyChecksum(path); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				failed = err
+				continue
+			}
+			fmt.Printf("%s: OK\n", path)
+		}
+		if failed != nil {
+			os.Exit(exitCode(failed))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
+
+// writeFileWithChecksum writes data to path and returns its SHA-256 in hex, hashed as the bytes are
+// written so a large document is never read back
+func writeFileWithChecksum(path string, data []byte) (string, error) {
+	file, err := os.Create(longPath(path))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.MultiWriter(file, hash).Write(data); err != nil {
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeChecksumFile writes the sidecar in the "hash  filename" format of sha256sum, so
+// `sha256sum -c` can check it from the PDF's directory as well
+func writeChecksumFile(outputPath, sum string) (string, error) {
+	path := outputPath + checksumSuffix
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(outputPath))
+	return path, os.WriteFile(longPath(path), []byte(line), 0644)
+}
+
+// readChecksumFile returns the hash recorded in a sidecar, accepting sha256sum's binary mode marker too
+func readChecksumFile(path string) (string, error) {
+	file, err := os.Open(longPath(path))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("%w: %s", ErrChecksumMissing, path)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	sum, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+	if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+		return "", fmt.Errorf("%s does not start with a SHA-256 hash", path)
+	}
+	return strings.ToLower(sum), nil
+}
+
+// verifyChecksum recomputes the PDF's SHA-256 and compares it with its sidecar
+func verifyChecksum(pdfPath string) error {
+	want, err := readChecksumFile(pdfPath + checksumSuffix)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(longPath(pdfPath))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, want, got)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package imagestopdf
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/johnfercher/maroto/v2/pkg/props"
+)
+
+var (
+	colorWhite = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	colorBlack = color.RGBA{A: 255}
+)
+
+// parseColor accepts #RRGGBB or the keywords white and black
+func parseColor(s string) (color.RGBA, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+	switch value {
+	case "white":
+		return colorWhite, nil
+	case "black":
+		return colorBlack, nil
+	}
+
+	hex, ok := strings.CutPrefix(value, "#")
+	if !ok || len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q, expected #RRGGBB, white or black", s)
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q, expected #RRGGBB, white or black", s)
+	}
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}, nil
+}
+
+// formatColor renders a color the way parseColor reads it
+func formatColor(c color.RGBA) string {
+	switch c {
+	case colorWhite:
+		return "white"
+	case colorBlack:
+		return "black"
+	}
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// textColor is black, or white on a dark background where black text wouldn't be readable
+func textColor(background color.RGBA) color.RGBA {
+	if 299*int(background.R)+587*int(background.G)+114*int(background.B) < 128*1000 {
+		return colorWhite
+	}
+	return colorBlack
+}
+
+// pdfColor converts a color for use in maroto styles
+func pdfColor(c color.RGBA) *props.Color {
+	return &props.Color{Red: int(c.R), Green: int(c.G), Blue: int(c.B)}
+}
+
+// colorValue is a flag value for a color.RGBA, validated when the flag is parsed
+type colorValue color.RGBA
+
+func (c *colorValue) String() string {
+	return formatColor(color.RGBA(*c))
+}
+
+func (c *colorValue) Set(s string) error {
+	parsed, err := parseColor(s)
+	if err != nil {
+		return err
+	}
+	*c = colorValue(parsed)
+	return nil
+}
+
+func (c *colorValue) Type() string {
+	return "color"
+}
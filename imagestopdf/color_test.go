@@ -0,0 +1,109 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want color.RGBA
+	}{
+		{"white", colorWhite},
+		{" Black ", colorBlack},
+		{"#000000", colorBlack},
+		{"#1E90ff", color.RGBA{R: 0x1e, G: 0x90, B: 0xff, A: 255}},
+	} {
+		got, err := parseColor(tc.in)
+		if err != nil || got != tc.want {
+			t.Errorf("parseColor(%q) = %v, %v; want %v", tc.in, got, err, tc.want)
+		}
+	}
+
+	for _, in := range []string{"", "red", "000000", "#000", "#0000000", "#gg0000", "#-12345"} {
+		if got, err := parseColor(in); err == nil {
+			t.Errorf("parseColor(%q) = %v, want an error", in, got)
+		}
+	}
+
+	var flag colorValue
+	if err := flag.Set("#12345z"); err == nil {
+		t.Error("--background accepted an invalid color")
+	}
+}
+
+// TestBlackBackground converts transparent PNGs with a black --background: a photo whose transparent
+// border is white underneath, which is re-encoded as JPEG, and a small image kept with its alpha.
+// The border has to come out black in the JPEG, and the area around the kept image on an A4 page,
+// which shows through its transparent parts, has to be filled black.
+func TestBlackBackground(t *testing.T) {
+	const width, height, border = 1200, 900, 100
+	photo := photoImage(width, height, 3)
+	framed := image.NewNRGBA(photo.Bounds())
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA{R: 255, G: 255, B: 255}
+			if x >= border && x < width-border && y >= border && y < height-border {
+				c = color.NRGBAModel.Convert(photo.At(x, y)).(color.NRGBA)
+			}
+			framed.SetNRGBA(x, y, c)
+		}
+	}
+
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "1-framed.png"), framed)
+	writePNG(t, filepath.Join(dir, "2-translucent.png"), translucentImage(400, 300))
+
+	var buf bytes.Buffer
+	result, err := Convert(context.Background(), &buf, Options{Inputs: []string{dir}, PageSize: "a4", Background: colorBlack})
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if len(result.Images) != 2 || result.Images[0].Strategy != "convert_png_to_jpeg" || result.Images[1].Strategy != "keep_original" {
+		t.Fatalf("got %+v, want the photo converted to JPEG and the small image kept", result.Images)
+	}
+	pdf := buf.Bytes()
+
+	images := pdfImages(t, pdf)
+	embedded, err := jpeg.Decode(bytes.NewReader(readAll(t, images[0][0])))
+	if err != nil {
+		t.Fatalf("decoding the embedded photo: %v", err)
+	}
+	bounds := embedded.Bounds()
+	scale := float64(bounds.Dx()) / width
+	edge := int(border*scale) - 4 // JPEG blocks bleed into the border
+	for _, p := range []image.Point{{0, 0}, {bounds.Dx() - 1, 0}, {edge, bounds.Dy() / 2}, {bounds.Dx() / 2, bounds.Dy() - 1}} {
+		r, g, b, _ := embedded.At(p.X, p.Y).RGBA()
+		if r>>8 > 16 || g>>8 > 16 || b>>8 > 16 {
+			t.Errorf("transparent border at %v came out %d,%d,%d, want black", p, r>>8, g>>8, b>>8)
+		}
+	}
+	if r, g, b, _ := embedded.At(bounds.Dx()/2, bounds.Dy()/2).RGBA(); r+g+b < 3*0x4000 {
+		t.Errorf("the photo itself came out dark: %d,%d,%d", r>>8, g>>8, b>>8)
+	}
+
+	for i, content := range pdfPageContents(t, pdf) {
+		placed := imagePlacements(content)
+		fills := grayFills(content, 0)
+		if len(placed) != 1 || len(fills) == 0 {
+			t.Errorf("page %d: %d image(s) and %d black fill(s), want one image on a black fill", i+1, len(placed), len(fills))
+			continue
+		}
+		// The fill is drawn first and covers the letterbox the 4:3 image leaves on the portrait page
+		if !fills[0].contains(placed[0]) || fills[0].height < placed[0].height*1.5 {
+			t.Errorf("page %d: black fill %+v doesn't letterbox the image at %+v", i+1, fills[0], placed[0])
+		}
+		if bytes.Index([]byte(content), []byte(" re f")) > bytes.Index([]byte(content), []byte(" Do ")) {
+			t.Errorf("page %d: the background is drawn over the image", i+1)
+		}
+	}
+	if kept := pdfImagesDecoded(t, pdf)[1][0]; !kept.HasSMask {
+		t.Error("the kept image lost its alpha, nothing shows through it")
+	}
+}
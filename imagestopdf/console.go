@@ -0,0 +1,69 @@
+package imagestopdf
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// asciiMarkers stand in for the status symbols on consoles that can't display them
+var asciiMarkers = strings.NewReplacer(
+	"✅ ", "[OK] ",
+	"⚠️  ", "[!] ",
+	"•", "*",
+	"→", "->",
+	"←", "<-",
+)
+
+// status returns where the run's progress and status lines go, stdout unless Options.Status is set
+func (o Options) status() io.Writer {
+	if o.Status == nil {
+		return statusWriter(os.Stdout)
+	}
+	return statusWriter(o.Status)
+}
+
+// statusWriter replaces the status symbols written to a console that can't display them, see
+// unicodeConsole. Other writers get them as they are.
+func statusWriter(w io.Writer) io.Writer {
+	if _, isFile := w.(*os.File); isFile && !unicodeConsole() {
+		return asciiWriter{w}
+	}
+	return w
+}
+
+// ANSI escape sequences of the colors used for status output
+const (
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiReset  = "\033[0m"
+)
+
+// colorize wraps text in an ANSI color when w is a terminal that shows them. NO_COLOR and
+// TERM=dumb turn colors off, and consoles that can't display the status symbols get none either.
+func colorize(w io.Writer, color, text string) string {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || !unicodeConsole() {
+		return text
+	}
+	file, ok := w.(*os.File)
+	if !ok {
+		return text
+	}
+	if info, err := file.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return text
+	}
+	return color + text + ansiReset
+}
+
+// asciiWriter replaces the status symbols before writing
+type asciiWriter struct {
+	w io.Writer
+}
+
+func (a asciiWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(a.w, asciiMarkers.Replace(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
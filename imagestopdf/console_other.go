@@ -0,0 +1,8 @@
+//go:build !windows
+
+package imagestopdf
+
+// unicodeConsole reports whether status output may use symbols, terminals outside Windows handle UTF-8
+func unicodeConsole() bool {
+	return true
+}
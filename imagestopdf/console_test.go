@@ -0,0 +1,43 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestStatusWriter converts with Options.Status set: the status lines go there and nothing reaches
+// stdout, which --output - leaves to the PDF
+func TestStatusWriter(t *testing.T) {
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "a.png"), gradientImage(64, 48))
+
+	read, write, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = write
+	var status, pdf bytes.Buffer
+	_, err = Convert(context.Background(), &pdf, Options{Inputs: []string{dir}, Status: &status})
+	os.Stdout = stdout
+	write.Close()
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	leaked, _ := io.ReadAll(read)
+
+	if len(leaked) > 0 {
+		t.Errorf("status output reached stdout: %q", leaked)
+	}
+	if !strings.Contains(status.String(), "Processing image 1/1: a.png") {
+		t.Errorf("status output is missing the progress lines:\n%s", status.String())
+	}
+	if !bytes.HasPrefix(pdf.Bytes(), []byte("%PDF-")) {
+		t.Error("no PDF was written")
+	}
+}
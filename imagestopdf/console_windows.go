@@ -0,0 +1,23 @@
+//go:build windows
+
+package imagestopdf
+
+import (
+	"os"
+	"syscall"
+)
+
+// utf8CodePage is the Windows code page number of UTF-8
+const utf8CodePage = 65001
+
+var procGetConsoleOutputCP = syscall.NewLazyDLL("kernel32.dll").NewProc("GetConsoleOutputCP")
+
+// unicodeConsole reports whether status output may use symbols. Windows Terminal and ConEmu render
+// them, the legacy console only does when switched to the UTF-8 code page (chcp 65001).
+func unicodeConsole() bool {
+	if os.Getenv("WT_SESSION") != "" || os.Getenv("ConEmuANSI") == "ON" {
+		return true
+	}
+	cp, _, _ := procGetConsoleOutputCP.Call()
+	return cp == utf8CodePage
+}
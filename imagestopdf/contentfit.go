@@ -0,0 +1,117 @@
+package imagestopdf
+
+import (
+	"fmt"
+	"image"
+	"io"
+)
+
+const (
+	// contentGridColumns is the width of the grid content is located on, finer than blankGridColumns
+	// because the crop follows the cell edges
+	contentGridColumns = 256
+	// contentCellShare is the share of a cell's pixels that must differ from the paper for it to hold content
+	contentCellShare = 0.02
+	// contentFitMinArea is the share of the image below which a crop is warned about
+	contentFitMinArea = 0.10
+)
+
+// contentBox returns the bounding box of everything on the image that isn't paper, or false when
+// the image is blank. The image is divided into a grid, and a cell holds content when enough of
+// its pixels differ in brightness from the dominant (paper) brightness by more than tolerance.
+// Cells without a content neighbour are dust and are ignored. The box covers every content cell,
+// so two receipts on one scan give the box around both.
+func contentBox(img image.Image, tolerance int) (image.Rectangle, bool) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return image.Rectangle{}, false
+	}
+
+	cell := max(1, width/contentGridColumns)
+	cols := (width + cell - 1) / cell
+	rows := (height + cell - 1) / cell
+
+	sample := rgbaSampler(img)
+	var px [4]byte
+	luma := func(x, y int) int {
+		sample(x, y, px[:])
+		return (299*int(px[0]) + 587*int(px[1]) + 114*int(px[2])) / 1000
+	}
+	paper := dominantLuma(bounds, cell, luma)
+
+	counts := make([]int, cols*rows)
+	for y := 0; y < height; y++ {
+		rowCells := counts[(y/cell)*cols:]
+		for x := 0; x < width; x++ {
+			if d := luma(bounds.Min.X+x, bounds.Min.Y+y) - paper; d > tolerance || d < -tolerance {
+				rowCells[x/cell]++
+			}
+		}
+	}
+
+	threshold := max(1, int(float64(cell*cell)*contentCellShare))
+	content := func(cx, cy int) bool {
+		return cx >= 0 && cx < cols && cy >= 0 && cy < rows && counts[cy*cols+cx] >= threshold
+	}
+	var box image.Rectangle
+	found := false
+	for cy := 0; cy < rows; cy++ {
+		for cx := 0; cx < cols; cx++ {
+			if !content(cx, cy) {
+				continue
+			}
+			isolated := true
+			for ny := cy - 1; ny <= cy+1 && isolated; ny++ {
+				for nx := cx - 1; nx <= cx+1; nx++ {
+					if (nx != cx || ny != cy) && content(nx, ny) {
+						isolated = false
+						break
+					}
+				}
+			}
+			if isolated {
+				continue
+			}
+			box = box.Union(image.Rect(cx*cell, cy*cell, min((cx+1)*cell, width), min((cy+1)*cell, height)))
+			found = true
+		}
+	}
+	return box.Add(bounds.Min), found
+}
+
+// fitToContent crops the image to its content box grown by padding pixels for --content-fit, and
+// reports whether it was cropped. Blank images and content reaching every edge are left as they are.
+func fitToContent(status io.Writer, img image.Image, imagePath string, tolerance, padding int) (image.Image, bool) {
+	bounds := img.Bounds()
+	box, ok := contentBox(img, tolerance)
+	if !ok {
+		logger.Info("no content found, not cropping", "path", imagePath)
+		return img, false
+	}
+	box = box.Inset(-padding).Intersect(bounds)
+	if box == bounds {
+		return img, false
+	}
+
+	share := float64(box.Dx()*box.Dy()) / float64(bounds.Dx()*bounds.Dy())
+	fmt.Fprintf(status, "    → cropped to content: %dx%d at %d,%d (%.0f%% of the image)\n",
+		box.Dx(), box.Dy(), box.Min.X-bounds.Min.X, box.Min.Y-bounds.Min.Y, share*100)
+	logger.Debug("content box", "path", imagePath, "x", box.Min.X-bounds.Min.X, "y", box.Min.Y-bounds.Min.Y,
+		"width", box.Dx(), "height", box.Dy(), "padding", padding)
+	if share < contentFitMinArea {
+		logger.Warn("content covers only a small part of the image, check the crop", "path", imagePath,
+			"percent", fmt.Sprintf("%.1f", share*100))
+	}
+
+	// Copied rather than sliced so everything below keeps working on an image at the origin
+	cropped := image.NewRGBA(image.Rect(0, 0, box.Dx(), box.Dy()))
+	sample := rgbaSampler(img)
+	for y := 0; y < box.Dy(); y++ {
+		row := cropped.Pix[y*cropped.Stride:]
+		for x := 0; x < box.Dx(); x++ {
+			sample(box.Min.X+x, box.Min.Y+y, row[x*4:x*4+4])
+		}
+	}
+	return cropped, true
+}
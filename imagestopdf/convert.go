@@ -0,0 +1,1750 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/johnfercher/maroto/v2/pkg/config"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/spf13/cobra"
+)
+
+// cliOptions holds the values of the root command's flags
+var cliOptions = defaultOptions()
+
+// losslessFlag is the --lossless shorthand for --strategy lossless
+var losslessFlag bool
+
+var rootCmd = &cobra.Command{
+	Use:   "images-to-pdf",
+	Short: "Convert images from a folder to a single PDF document",
+	Long: `A CLI tool that reads all image files from an input folder,
+sorts them by name, and combines them into a single PDF file with each image on its own page.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return configureLogging(logLevel, logFormat)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if losslessFlag {
+			if cmd.Flags().Changed("strategy") && cliOptions.Strategy != "lossless" {
+				fmt.Fprintf(os.Stderr, "Error: --lossless conflicts with --strategy %s\n", cliOptions.Strategy)
+				os.Exit(1)
+			}
+			cliOptions.Strategy = "lossless"
+		}
+		if !cmd.Flags().Changed("name") {
+			cliOptions.Name = defaultOutputName(cliOptions.Inputs)
+		}
+		// With --output - nothing but the PDF may reach stdout
+		if cliOptions.OutputDir == "-" {
+			cliOptions.Status = os.Stderr
+		}
+
+		stopProfiling, err := startProfiling()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		ctx, stop := notifyInterrupt()
+		err = convertImagesToPDF(ctx, cliOptions)
+		interrupted := ctx.Err() != nil
+		stop()
+		stopProfiling()
+
+		// Declining to go on with a PDF over its size target isn't a failure
+		if errors.Is(err, errCancelled) {
+			if cliOptions.BatchSize > 0 || cliOptions.OnePerImage {
+				fmt.Fprintln(cliOptions.status(), "Cancelled, the PDFs written before were kept")
+			} else {
+				fmt.Fprintln(cliOptions.status(), "Cancelled, no PDF was written")
+			}
+			return
+		}
+
+		if err != nil && interrupted && cliOptions.BatchSize > 0 {
+			fmt.Fprintln(os.Stderr, "Interrupted, finished batches were kept, run again with --resume to continue")
+			os.Exit(exitCodeInterrupted)
+		}
+		if err != nil && interrupted {
+			fmt.Fprintln(os.Stderr, "Interrupted, no PDF was written")
+			os.Exit(exitCodeInterrupted)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func init() {
+	persistent := rootCmd.PersistentFlags()
+	persistent.StringVar(&logLevel, "log-level", "info", "Minimum level of diagnostics written to stderr: debug, info, warn, or error")
+	persistent.StringVar(&logFormat, "log-format", "text", "Format of diagnostics written to stderr: text or json")
+
+	flags := rootCmd.Flags()
+	flags.StringArrayVarP(&cliOptions.Inputs, "input", "i", nil, "Input directory or image file, repeat to combine several (required unless --stdin-tar)")
+	flags.BoolVar(&cliOptions.StdinTar, "stdin-tar", false, "Read the images from a tar stream on standard input, optionally gzip-compressed")
+	flags.StringVar(&cliOptions.TarOrder, "tar-order", cliOptions.TarOrder, "Page order of --stdin-tar images: sorted (the normal sort) or archive (entry order)")
+	flags.StringVar(&cliOptions.InputDir2, "input2", "", "Second input directory whose pages are interleaved with --input, e.g. the backs of a duplex scan")
+	flags.StringVar(&cliOptions.Interleave, "interleave", cliOptions.Interleave, "Order in which --input2 pages are interleaved: reverse (scanned last page first) or forward")
+	flags.BoolVar(&cliOptions.Strict, "strict", false, "Fail instead of padding with blank pages when the inputs don't line up, skipping images over the decode limits, clamping a degenerate page size, or writing a PDF whose page count is off")
+	flags.StringVarP(&cliOptions.OutputDir, "output", "o", cliOptions.OutputDir, "Output directory for the PDF file, or - to write the PDF to standard output (default: current directory)")
+	flags.BoolVar(&cliOptions.NoOverwrite, "no-overwrite", false, "Fail instead of replacing an existing output file")
+	flags.StringVarP(&cliOptions.Name, "name", "n", cliOptions.Name, "Name of the output PDF file, may use {date}, {time}, {dir}, {count} and {n} placeholders (default: images.pdf, or the image's name for a single file input)")
+	flags.Float64Var(&cliOptions.DPI, "dpi", cliOptions.DPI, "Resolution used to convert image pixels to page size")
+	flags.IntVar(&cliOptions.Quality, "quality", 0, "JPEG quality (1-100) for re-encoded images, 0 picks it per image")
+	flags.StringVar(&cliOptions.Strategy, "strategy", cliOptions.Strategy, "Encoding for re-encoded images: auto (PNG for line art, JPEG otherwise), jpeg, lossless, or quantize (indexed PNG)")
+	flags.IntVar(&cliOptions.QuantizeColors, "quantize-colors", cliOptions.QuantizeColors, "Palette size (2-256) for images embedded as indexed PNG")
+	flags.BoolVar(&cliOptions.QuantizeDither, "quantize-dither", false, "Use Floyd-Steinberg dithering when reducing images to a palette, smoother gradients at some size cost")
+	flags.BoolVar(&losslessFlag, "lossless", false, "Embed re-encoded images losslessly as PNG, same as --strategy lossless")
+	flags.Var((*colorValue)(&cliOptions.Background), "background", "Color behind transparent areas and around images that don't fill the page: #RRGGBB, white or black")
+	flags.BoolVar(&cliOptions.Dither, "dither", false, "Use ordered dithering when reducing 16-bit images to 8 bits, avoids banding in smooth gradients")
+	flags.BoolVar(&cliOptions.ConvertSRGB, "convert-srgb", cliOptions.ConvertSRGB, "Convert images with an embedded RGB ICC profile to sRGB, which viewers that ignore the profile assume; by default the profile is passed through")
+	flags.BoolVar(&cliOptions.StripMetadata, "strip-metadata", cliOptions.StripMetadata, "Remove EXIF, GPS, XMP and IPTC metadata from embedded JPEG images")
+	flags.Var((*byteSize)(&cliOptions.MaxSize), "max-size", "Size budget of the PDF (e.g. 20MB); re-encoded JPEGs are lowered in quality to fit it")
+	flags.StringVar(&cliOptions.BudgetMode, "budget-mode", cliOptions.BudgetMode, "How --max-size is shared: per-image (equal share per image) or global (by image complexity, two passes)")
+	flags.Int64Var(&cliOptions.MaxDecodePixels, "max-decode-pixels", cliOptions.MaxDecodePixels, "Largest image decoded in full, in pixels; larger JPEGs use their embedded thumbnail, others are skipped (0 for no limit)")
+	flags.DurationVar(&cliOptions.DecodeTimeout, "decode-timeout", cliOptions.DecodeTimeout, "Skip an image whose decoding takes longer than this (0 to wait indefinitely)")
+	flags.Var((*byteSize)(&cliOptions.MaxMemory), "max-memory", "Memory budget for decoded images (e.g. 512MB); larger images are decoded one at a time")
+	flags.BoolVar(&cliOptions.Sharpen, "sharpen", false, "Apply a light unsharp mask to downscaled images to keep scanned text legible")
+	flags.Float64Var(&cliOptions.SharpenAmount, "sharpen-amount", cliOptions.SharpenAmount, "Strength of --sharpen, the fraction of the edge contrast added back")
+	flags.StringVar(&cpuProfilePath, "cpuprofile", "", "Write a CPU profile of the run to this file, for go tool pprof")
+	flags.StringVar(&memProfilePath, "memprofile", "", "Write a heap profile at the end of the run to this file, for go tool pprof")
+	flags.IntVar(&cliOptions.Retries, "retries", cliOptions.Retries, "Times to retry reading a file after a transient I/O error, e.g. on a flaky network share")
+	flags.BoolVar(&cliOptions.Deterministic, "deterministic", false, "Produce byte-identical output for identical input: fixed document dates and a stable object order")
+	flags.StringVar(&cliOptions.Date, "date", "", "Creation date stamped by --deterministic, RFC 3339 or YYYY-MM-DD (default: SOURCE_DATE_EPOCH, or 1970-01-01)")
+	flags.IntVar(&cliOptions.BatchSize, "batch-size", 0, "Convert the sorted images in chunks of this many, writing one numbered PDF per chunk (name_part001.pdf, ...)")
+	flags.BoolVar(&cliOptions.Diff, "diff", false, "List the source images added, removed or modified since the previous run, from its manifest; keeps a manifest for the next run")
+	flags.BoolVar(&cliOptions.SkipUnchanged, "skip-unchanged", false, "Don't rebuild the PDF when no source image changed since the previous run (compares like --diff)")
+	flags.BoolVar(&cliOptions.Interactive, "interactive", false, "Print the page order, input size and page size, then ask before converting; needs a terminal")
+	flags.BoolVar(&cliOptions.OnePerImage, "one-per-image", false, "Write each image to its own single-page PDF named after it, sized to the image, instead of combining them")
+	flags.BoolVar(&cliOptions.Resume, "resume", false, "With --batch-size, skip chunks whose PDF already exists and is newer than all of its images")
+	flags.BoolVar(&cliOptions.Linearize, "linearize", false, "Optimize the PDF for fast web view: deduplicate identical images and linearize with qpdf when it is installed")
+	flags.BoolVar(&cliOptions.Tagged, "tagged", false, "Write a tagged PDF for screen readers, each image a Figure with alternate text from --alt-file, its EXIF ImageDescription or its file name")
+	flags.StringVar(&cliOptions.AltFile, "alt-file", "", "File with the alternate text of images for --tagged, one \"<file><TAB><text>\" per line")
+	flags.StringVar(&cliOptions.Lang, "lang", cliOptions.Lang, "Document language of --tagged PDFs, a BCP-47 tag such as en or de-CH")
+	flags.BoolVar(&cliOptions.SkipBlank, "skip-blank", false, "Drop pages that are almost entirely background, e.g. blank backs from a sheet-fed scanner")
+	flags.Float64Var(&cliOptions.BlankThreshold, "blank-threshold", cliOptions.BlankThreshold, "Percentage of a page that must be background for --skip-blank to drop it")
+	flags.IntVar(&cliOptions.BlankTolerance, "blank-tolerance", cliOptions.BlankTolerance, "Brightness difference (0-255) from the paper color still counted as background by --skip-blank and --content-fit")
+	flags.BoolVar(&cliOptions.ContentFit, "content-fit", false, "Crop each image to the box around its content, so a receipt on a letter-size scan fills its page")
+	flags.Var((*length)(&cliOptions.ContentPadding), "content-padding", "Paper kept around the content by --content-fit, e.g. 5mm or 0.25in, at the image's DPI")
+	flags.BoolVar(&cliOptions.DateStamp, "date-stamp", false, "Stamp each page with the photo's capture date from EXIF, or the file's modification time")
+	flags.StringVar(&cliOptions.DateStampPosition, "date-stamp-position", cliOptions.DateStampPosition, "Image corner of the --date-stamp: top-left, top-right, bottom-left, or bottom-right")
+	flags.StringVar(&cliOptions.DateStampFormat, "date-stamp-format", cliOptions.DateStampFormat, "Layout of the --date-stamp in Go reference time, e.g. \"02.01.2006 15:04\"")
+	flags.BoolVar(&cliOptions.BlankAfterOdd, "blank-after-odd", false, "Pad each directory's pages to an even count with a blank page for duplex printing")
+	flags.StringVar(&cliOptions.InsertBlankFile, "insert-blank", "", "File listing source image names (one per line) to insert a blank page after")
+	flags.BoolVar(&cliOptions.Checksum, "checksum", false, "Write the PDF's SHA-256 to <output>.sha256 in sha256sum format, check it later with the verify command")
+	flags.StringVar(&cliOptions.ManifestPath, "manifest", "", "Write a page manifest (JSON, or CSV for a .csv path) mapping pages to source files")
+	flags.Lookup("manifest").NoOptDefVal = defaultManifestPath
+	flags.StringVar(&cliOptions.ReportPath, "report", "", "Write an HTML report with a thumbnail, sizes and strategy per page (defaults to <output>.report.html)")
+	flags.Lookup("report").NoOptDefVal = defaultReportPath
+	flags.BoolVar(&cliOptions.Sections, "sections", false, "Group pages by directory, each group starting with a divider page, and add bookmarks per directory and image")
+	flags.BoolVar(&cliOptions.NoDividerPages, "no-divider-pages", false, "Leave out the divider pages of --sections, keeping the bookmarks")
+	flags.BoolVar(&cliOptions.NoIgnoreFiles, "no-ignore-files", false, "Include images excluded by .pdfignore files in the input directories")
+	flags.StringVar(&cliOptions.Sort, "sort", cliOptions.Sort, "Order of file names: name (byte order of the path) or natural (numbers compared by value, page2 before page10)")
+	flags.BoolVar(&cliOptions.SortCaseInsensitive, "sort-case-insensitive", false, "Ignore letter case when sorting file names")
+	flags.StringVar(&cliOptions.CollateLocale, "collate", "", "Sort file names using the collation rules of a BCP-47 locale (e.g. de, ja)")
+	flags.StringVar(&cliOptions.PageSize, "page-size", cliOptions.PageSize, "Output page size: auto (from the images), a3, a4, a5, letter, or legal; with --booklet the sheet size")
+	flags.Var((*length)(&cliOptions.Margin), "margin", "Blank margin around each sheet (e.g. 5mm, 12pt); a number alone is in millimeters")
+	flags.BoolVar(&cliOptions.Border, "border", false, "Draw a border around each image")
+	flags.Var((*length)(&cliOptions.BorderWidth), "border-width", "Width of the --border line (e.g. 1pt, 0.5mm)")
+	flags.Var((*colorValue)(&cliOptions.BorderColor), "border-color", "Color of the --border line: #RRGGBB, white or black")
+	flags.BoolVar(&cliOptions.Booklet, "booklet", false, "Impose pages two per landscape sheet in saddle-stitch order for printing and folding into a booklet")
+	flags.BoolVar(&cliOptions.UseSourceDPI, "use-source-dpi", false, "Size each image from the DPI it declares (JFIF, EXIF or PNG pHYs), falling back to --dpi")
+	flags.StringVar(&cliOptions.PageBasis, "page-basis", cliOptions.PageBasis, "Statistic of the image sizes used for the page size: mean, median, max, or first")
+	flags.StringVar(&cliOptions.OrderFile, "order-file", "", "File listing images (names relative to the input directory) to put first, in that order; the rest follow sorted")
+	flags.StringVar(&cliOptions.EmitOrder, "emit-order", "", "Write the computed page order to this file, to edit and pass back with --order-file")
+	flags.StringVar(&cliOptions.RotateFile, "rotate-file", "", "File with per-image clockwise rotations (\"IMG_0042.jpg 90\"), defaults to .images-to-pdf-rotate in the input directory")
+	flags.IntVar(&cliOptions.Rotate, "rotate", 0, "Rotate every image clockwise by 90, 180 or 270 degrees")
+	rootCmd.MarkFlagsOneRequired("input", "stdin-tar")
+}
+
+// Execute runs the images-to-pdf command with the program's arguments and exits with a non-zero
+// status when it fails
+func Execute() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func convertImagesToPDF(ctx context.Context, opts Options) error {
+	outputDir := opts.OutputDir
+
+	// Reject unknown output name placeholders before doing any work
+	if err := validateNameTemplate(opts.Name); err != nil {
+		return err
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	// A prompt nobody can answer would hang a script, it should fail instead
+	if opts.Interactive && !stdinIsTerminal() {
+		return fmt.Errorf("--interactive needs a terminal on standard input")
+	}
+
+	// Piped images are extracted to a temp directory that then stands in for --input
+	if opts.StdinTar {
+		runDir, err := os.MkdirTemp("", "images-to-pdf-stdin-")
+		if err != nil {
+			return fmt.Errorf("failed to create temp directory: %v", err)
+		}
+		defer os.RemoveAll(runDir)
+		defer removeOnForcedExit(runDir)()
+		if opts, err = readStdinTar(os.Stdin, runDir, opts); err != nil {
+			return err
+		}
+	}
+
+	// Compare with the previous run before any work, an unchanged folder may not need any
+	var changes *sourceDiff
+	if opts.Diff || opts.SkipUnchanged {
+		var upToDate bool
+		var err error
+		if changes, upToDate, err = checkChanges(ctx, opts); err != nil {
+			return err
+		}
+		if upToDate {
+			fmt.Fprintf(opts.status(), "No source image changed, %s is up to date\n", filepath.Join(outputDir, opts.Name))
+			return nil
+		}
+		// The next run compares with this run's manifest
+		if opts.ManifestPath == "" {
+			opts.ManifestPath = defaultManifestPath
+		}
+	}
+
+	// Declining leaves the output directory untouched
+	if opts.Interactive {
+		if err := printPlan(ctx, opts); err != nil {
+			return err
+		}
+		if !confirm(os.Stdin, opts.status(), "Proceed?") {
+			fmt.Fprintf(opts.status(), "Cancelled, nothing was written\n")
+			return nil
+		}
+	}
+
+	if outputDir == "-" {
+		return writePDFToStdout(ctx, opts)
+	}
+
+	// Without placeholders the output path is known up front, so an existing file fails before any work
+	if opts.NoOverwrite && opts.BatchSize == 0 && !opts.OnePerImage && !strings.Contains(opts.Name, "{") {
+		if _, err := os.Stat(filepath.Join(outputDir, opts.Name)); err == nil {
+			return fmt.Errorf("%w: %s", ErrOutputExists, filepath.Join(outputDir, opts.Name))
+		}
+	}
+
+	// Create output directory if it doesn't exist
+	if err := os.MkdirAll(longPath(outputDir), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	if opts.BatchSize > 0 {
+		return convertInBatches(ctx, opts)
+	}
+	if opts.OnePerImage {
+		return convertOnePerImage(ctx, opts)
+	}
+	if err := writePDF(ctx, opts); err != nil {
+		return err
+	}
+	if changes != nil {
+		fmt.Fprintf(opts.status(), "Changes since the last run: %s\n", changes.summary())
+	}
+	return nil
+}
+
+// writePDF converts the images and saves the PDF as opts.Name in opts.OutputDir,
+// followed by the manifest and report if requested
+func writePDF(ctx context.Context, opts Options) error {
+	outputDir := opts.OutputDir
+	tempDir := filepath.Join(outputDir, "temp_optimized_images")
+	defer cleanupConvertedImages(opts.status(), tempDir)
+	defer removeOnForcedExit(tempDir)()
+	logger.Debug("optimizing images", "temp_dir", tempDir)
+	result, err := buildPDF(ctx, opts, tempDir)
+	if err != nil {
+		return err
+	}
+
+	// Generate output filename, placeholders like {count} are only known now
+	outputPath := filepath.Join(outputDir, expandNameTemplate(opts.Name, primaryInputDir(opts.Inputs), outputDir, result.pageCount, opts.now()))
+
+	if opts.NoOverwrite {
+		if _, err := os.Stat(outputPath); err == nil {
+			return fmt.Errorf("%w: %s", ErrOutputExists, outputPath)
+		}
+	}
+
+	// Save to a .partial file and rename it into place only on success, so a full disk or an
+	// interrupted run never leaves a truncated PDF where a good one used to be
+	tmpPath := outputPath + ".partial"
+	defer removeOnForcedExit(tmpPath)()
+	defer os.Remove(tmpPath) // no-op once renamed
+	sum, err := writeFileWithChecksum(tmpPath, result.data)
+	if err != nil {
+		return fmt.Errorf("%w to %s: %v", ErrSaveFailed, outputPath, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.Rename(longPath(tmpPath), longPath(outputPath)); err != nil {
+		return fmt.Errorf("%w to %s: %v", ErrSaveFailed, outputPath, err)
+	}
+
+	// SHA-256 sidecar for archives, checked with the verify subcommand
+	if opts.Checksum {
+		path, err := writeChecksumFile(outputPath, sum)
+		if err != nil {
+			return fmt.Errorf("failed to write checksum: %v", err)
+		}
+		fmt.Fprintf(opts.status(), "Wrote SHA-256 checksum: %s\n", path)
+	}
+
+	if err := writeManifestAndReport(opts, outputPath, sum, result.pages); err != nil {
+		return err
+	}
+
+	// Check file size and provide feedback
+	if err := checkAndReportFileSize(opts.status(), outputPath, opts.MaxSize, result.projected); err != nil {
+		return fmt.Errorf("failed to check file size: %v", err)
+	}
+
+	fmt.Fprintf(opts.status(), "Successfully created PDF: %s\n", outputPath)
+	return nil
+}
+
+// writeManifestAndReport writes the --manifest and --report files of a saved PDF
+func writeManifestAndReport(opts Options, outputPath, sum string, pages []manifestPage) error {
+	// Record which source file became which page
+	if opts.ManifestPath != "" {
+		path := opts.ManifestPath
+		if path == defaultManifestPath {
+			path = outputPath + ".manifest.json"
+		}
+		if err := writeManifest(path, outputPath, sum, pages); err != nil {
+			return fmt.Errorf("failed to write manifest: %v", err)
+		}
+		fmt.Fprintf(opts.status(), "Wrote page manifest: %s\n", path)
+	}
+
+	// Overview of every page with thumbnails and sizes
+	if opts.ReportPath != "" {
+		path := opts.ReportPath
+		if path == defaultReportPath {
+			path = strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".report.html"
+		}
+		if err := writeReport(path, outputPath, pages, opts.now()); err != nil {
+			return fmt.Errorf("failed to write report: %v", err)
+		}
+		fmt.Fprintf(opts.status(), "Wrote conversion report: %s\n", path)
+	}
+	return nil
+}
+
+// pdfResult is a generated document together with what went into it
+type pdfResult struct {
+	data      []byte
+	pageCount int
+	pages     []manifestPage
+	projected int64 // size estimated before generating, see projectedPDFSize
+}
+
+// buildPDF finds, optimizes and lays out the images in opts.Inputs, using tempDir for the optimized copies
+func buildPDF(ctx context.Context, opts Options, tempDir string) (*pdfResult, error) {
+	// Rotation and list entries are relative to the first input
+	inputDir := primaryInputDir(opts.Inputs)
+
+	// Load the blank page list up front so a bad file fails before any heavy work
+	insertBlankAfter, err := loadBlankPageList(opts.InsertBlankFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blank page list: %v", err)
+	}
+
+	// Per-image rotations, invalid angles are reported before any heavy work
+	rotations, err := loadRotations(opts.status(), opts.RotateFile, inputDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rotations: %v", err)
+	}
+
+	// Alternate texts for --tagged, read before any heavy work like the rotations
+	altTexts, err := loadAltTexts(opts.AltFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alt texts: %v", err)
+	}
+
+	// Reads from network shares occasionally fail transiently, those are repeated
+	retry := newRetrier(opts.Retries)
+
+	// Find and sort the fronts and the duplex backs on their own
+	imageFiles := opts.batch
+	if imageFiles == nil {
+		if imageFiles, err = discoverImages(ctx, opts.Inputs, retry, opts); err != nil {
+			return nil, err
+		}
+		if imageFiles, err = pageOrder(imageFiles, opts); err != nil {
+			return nil, err
+		}
+	}
+	var backFiles []string
+	if opts.InputDir2 != "" {
+		if backFiles, err = discoverImages(ctx, []string{opts.InputDir2}, retry, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	allFiles := append(slices.Clip(imageFiles), backFiles...)
+	for i, imagePath := range allFiles {
+		if err := opts.report(StageDiscovery, i+1, len(allFiles), imagePath); err != nil {
+			return nil, err
+		}
+	}
+
+	fmt.Fprintf(opts.status(), "Found %d image files, converting to PDF...\n", len(allFiles))
+
+	// Optimized copies and the PDF go next to the temp directory, check there is room before the heavy work
+	if err := checkDiskSpace(filepath.Dir(tempDir), estimateDiskSpace(allFiles), opts.Strict); err != nil {
+		return nil, err
+	}
+
+	rotations.warnUnmatched(allFiles, inputDir)
+	altTexts.warnUnmatched(allFiles, inputDir)
+	imageRotations := map[string]int{}
+	for _, imagePath := range allFiles {
+		if degrees := rotations.rotationFor(imagePath, inputDir, opts.Rotate); degrees != 0 {
+			imageRotations[imagePath] = degrees
+		}
+	}
+
+	// Without the global allocator every image gets the same share of --max-size
+	if opts.MaxSize > 0 && opts.BudgetMode == "per-image" {
+		opts.imageBudget = max(opts.MaxSize/int64(len(allFiles))-pdfPageOverhead, 1)
+	}
+
+	// Step 0: Convert images to optimized JPEG
+	budget := newMemoryBudget(opts.MaxMemory)
+	convertedImageFiles, err := convertImagesToOptimizedJPEG(ctx, imageFiles, imageRotations, tempDir, budget, retry, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert images to optimized JPEG: %w", err)
+	}
+	setInputDir(convertedImageFiles, opts.Inputs)
+
+	// Duplex backs are optimized separately, their file names usually repeat the fronts'
+	if opts.InputDir2 != "" {
+		backImages, err := convertImagesToOptimizedJPEG(ctx, backFiles, imageRotations, filepath.Join(tempDir, "input2"), budget, retry, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert images to optimized JPEG: %w", err)
+		}
+		setInputDir(backImages, []string{opts.InputDir2})
+
+		convertedImageFiles, err = interleavePages(convertedImageFiles, backImages, opts.Interleave, opts.Strict)
+		if err != nil {
+			return nil, err
+		}
+	}
+	convertedImageFiles = dropBlankPages(convertedImageFiles)
+
+	if len(optimizedPaths(convertedImageFiles)) == 0 {
+		return nil, fmt.Errorf("%w (%d tried)", ErrAllImagesFailed, len(allFiles))
+	}
+
+	// Second pass of --budget-mode global, once every image's size model is known
+	if opts.BudgetMode == "global" && opts.MaxSize > 0 {
+		if convertedImageFiles, err = allocateGlobalBudget(ctx, convertedImageFiles, imageRotations, budget, retry, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	// Known before the layout, so a PDF that will be too big is pointed out before the slow part
+	convertedImageFiles, projected, err := projectSize(ctx, convertedImageFiles, imageRotations, budget, retry, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	reportMixedDPI(convertedImageFiles, opts)
+
+	// Step 1: Calculate page dimensions from the image sizes, or their physical sizes with --use-source-dpi
+	var basisWidth, basisHeight float64
+	if opts.UseSourceDPI {
+		basisWidth, basisHeight, err = physicalPageBasis(convertedImageFiles, opts.PageBasis, opts)
+	} else {
+		basisWidth, basisHeight, err = calculatePageBasisSize(ctx, opts.status(), optimizedPaths(convertedImageFiles), opts.PageBasis, retry)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate page size: %v", err)
+	}
+
+	fmt.Fprintf(opts.status(), "Page size basis (%s): %.1fx%.1f pixels\n", opts.PageBasis, basisWidth, basisHeight)
+
+	dpiValue := opts.DPI
+	// Step 2: Create PDF document with DPI value and enhanced compression
+	imageWidthPoints := basisWidth * 72 / dpiValue // Convert from given DPI to points
+	imageHeightPoints := basisHeight * 72 / dpiValue
+	if opts.PageSize == "auto" {
+		if imageWidthPoints, imageHeightPoints, err = guardPageSize(imageWidthPoints, imageHeightPoints, convertedImageFiles, opts); err != nil {
+			return nil, err
+		}
+	}
+	sheetWidth, sheetHeight, pageWidthPoints, pageHeightPoints := sheetSize(opts.PageSize, opts.Booklet, imageWidthPoints, imageHeightPoints, opts.Margin)
+
+	// Enhanced PDF compression settings
+	cfg := config.NewBuilder().
+		WithDimensions(sheetWidth, sheetHeight).
+		WithLeftMargin(opts.Margin).
+		WithTopMargin(opts.Margin).
+		WithRightMargin(opts.Margin).
+		WithBottomMargin(opts.Margin).
+		WithCompression(true) // Enable PDF compression
+	m, err := newDocument(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case opts.PageSize == "auto":
+		fmt.Fprintf(opts.status(), "%f DPI quality with 100%% page size (%.1fx%.1f points)\n", dpiValue, pageWidthPoints, pageHeightPoints)
+	case opts.Booklet:
+		fmt.Fprintf(opts.status(), "Landscape %s sheets, %.1fx%.1f mm per page, images scaled to fit\n", strings.ToUpper(opts.PageSize), pageWidthPoints, pageHeightPoints)
+	default:
+		fmt.Fprintf(opts.status(), "%s pages (%.1fx%.1f mm), images scaled to fit\n", strings.ToUpper(opts.PageSize), pageWidthPoints, pageHeightPoints)
+	}
+	if opts.Margin > 0 {
+		fmt.Fprintf(opts.status(), "%.1f mm margin around each sheet (%.1fx%.1f mm)\n", opts.Margin, sheetWidth, sheetHeight)
+	}
+
+	// Pages are collected first so booklets can reorder them.
+	// Blank pages keep the document page size and count towards page numbering
+	var pages []page
+	var blankPages []string
+	var manifestPages []manifestPage
+	pageCount := 0
+	groupPages := 0
+
+	// With --sections every directory gets a top-level bookmark holding one per image
+	var bookmarks []pdfcpu.Bookmark
+	var section, sectionDir string
+
+	addBlankPage := func(where string) {
+		pages = append(pages, page{})
+		pageCount++
+		groupPages++
+		blankPages = append(blankPages, fmt.Sprintf("page %d, %s", pageCount, where))
+		manifestPages = append(manifestPages, manifestPage{Page: pageCount, Blank: true, Section: section})
+	}
+
+	// Step 3: Add each converted image to fit full page
+	for i, converted := range convertedImageFiles {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// Interleaving pads a missing front or back with a blank page
+		if converted.path == "" {
+			addBlankPage("the other side of " + filepath.Base(converted.counterpart) + ", which has no counterpart in the other input")
+			continue
+		}
+
+		if err := opts.report(StageAssemble, i+1, len(convertedImageFiles), converted.sourcePath); err != nil {
+			return nil, err
+		}
+
+		imagePath := converted.path
+		fmt.Fprintf(opts.status(), "Processing image %d/%d: %s\n", i+1, len(convertedImageFiles), filepath.Base(imagePath))
+
+		if dir := filepath.Dir(converted.sourcePath); opts.Sections && (len(bookmarks) == 0 || dir != sectionDir) {
+			section, sectionDir = sectionName(converted.sourcePath, opts.Inputs), dir
+			bookmarks = append(bookmarks, pdfcpu.Bookmark{PageFrom: pageCount + 1, Title: section})
+			if !opts.NoDividerPages {
+				pages = append(pages, page{title: section})
+				pageCount++
+				groupPages++
+				manifestPages = append(manifestPages, manifestPage{Page: pageCount, Divider: true, Section: section})
+			}
+		}
+
+		// Each image fits a full page
+		pages = append(pages, page{imagePath: imagePath, width: converted.width, height: converted.height})
+		if opts.DateStamp && !converted.captured.IsZero() {
+			pages[len(pages)-1].stamp = converted.captured.Format(opts.DateStampFormat)
+		}
+		if opts.Tagged {
+			pages[len(pages)-1].alt = altTexts.altFor(converted, inputDir)
+		}
+		if opts.UseSourceDPI {
+			pages[len(pages)-1].percent = physicalPercent(converted, pageWidthPoints, pageHeightPoints, opts)
+		}
+		pageCount++
+		groupPages++
+		entry := newManifestPage(pageCount, converted)
+		entry.Section = section
+		manifestPages = append(manifestPages, entry)
+		if opts.Sections {
+			current := &bookmarks[len(bookmarks)-1]
+			current.Kids = append(current.Kids, pdfcpu.Bookmark{PageFrom: pageCount, Title: filepath.Base(converted.sourcePath)})
+		}
+
+		if insertBlankAfter.matches(converted.sourcePath, inputDir) {
+			addBlankPage("after " + filepath.Base(converted.sourcePath))
+		}
+
+		// Pad each run of pages from the same directory to an even count so the next one starts on a right-hand page
+		// Interleaved pages alternate between directories, so they form a single run
+		lastInGroup := i == len(convertedImageFiles)-1 ||
+			(opts.InputDir2 == "" && filepath.Dir(convertedImageFiles[i+1].sourcePath) != filepath.Dir(converted.sourcePath))
+		if lastInGroup {
+			if opts.BlankAfterOdd && groupPages%2 == 1 {
+				addBlankPage("after the last page of " + filepath.Dir(converted.sourcePath))
+			}
+			groupPages = 0
+		}
+	}
+
+	// A folded booklet needs a multiple of 4 pages, the padding goes at the end
+	if opts.Booklet {
+		for pageCount%4 != 0 {
+			addBlankPage("after the last page, to fill the booklet")
+		}
+	}
+
+	placePages(manifestPages, opts.Booklet)
+
+	if len(blankPages) > 0 {
+		fmt.Fprintf(opts.status(), "Inserted %d blank page(s):\n", len(blankPages))
+		for _, where := range blankPages {
+			fmt.Fprintf(opts.status(), "  • %s\n", where)
+		}
+	}
+
+	rows := layoutRows(pages, pageHeightPoints, opts)
+	m.AddRows(rows...)
+	if opts.Booklet {
+		fmt.Fprintf(opts.status(), "Imposed %d pages as a booklet on %d sheets (print double-sided, flip on short edge)\n", pageCount, len(rows)/2)
+	}
+
+	document, err := m.Generate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate PDF: %v", err)
+	}
+
+	// Checked before the bookmarks and linearization, which may pack the page objects out of sight
+	data := document.GetBytes()
+	converted := len(optimizedPaths(convertedImageFiles))
+	detail := fmt.Sprintf("%d of %d image(s) converted, %d blank or divider page(s) added", converted, len(allFiles), pageCount-converted)
+	if opts.Booklet {
+		detail += ", imposed two per sheet side"
+	}
+	if err := checkPageCount(opts.status(), data, len(rows), detail, opts.Strict); err != nil {
+		return nil, err
+	}
+	if opts.Sections {
+		if opts.Booklet {
+			logger.Warn("section bookmarks are left out of booklets, their pages are imposed out of reading order")
+		} else {
+			data = addBookmarks(opts.status(), data, bookmarks)
+		}
+	}
+	if opts.Tagged {
+		if data, err = tagPDF(opts.status(), data, pages, opts.Lang); err != nil {
+			return nil, fmt.Errorf("failed to tag PDF: %v", err)
+		}
+	}
+	if opts.Deterministic {
+		date, err := opts.documentDate()
+		if err != nil {
+			return nil, err
+		}
+		if data, err = normalizeDocument(data, date); err != nil {
+			return nil, fmt.Errorf("failed to normalize PDF for deterministic output: %v", err)
+		}
+	}
+	if opts.Linearize {
+		data = optimizeForWeb(ctx, opts.status(), data, opts.Deterministic)
+	}
+
+	if n := retry.retries(); n > 0 {
+		fmt.Fprintf(opts.status(), "Retried %d read(s) after transient I/O errors, the filesystem may be unreliable\n", n)
+	}
+
+	return &pdfResult{
+		data:      data,
+		pageCount: len(rows),
+		pages:     manifestPages,
+		projected: projected,
+	}, nil
+}
+
+// supportedExts are the file extensions picked up as images
+var supportedExts = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".bmp":  true,
+	".tiff": true,
+	".tif":  true,
+	".webp": true,
+	".avif": true,
+}
+
+func findImageFiles(status io.Writer, dir string, useIgnoreFiles bool) ([]string, error) {
+	var imageFiles []string
+
+	// Rules of the .pdfignore files in each directory and its parents, and the ignore file that
+	// excluded a directory, its images are still counted towards that file's summary
+	rules := map[string][]ignoreRule{}
+	ignoredDirs := map[string]*ignoreFile{}
+	var ignoreFiles []*ignoreFile
+
+	// Deeply nested folders are walked in their extended-length form on Windows, but reported as given
+	root := longPath(dir)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		parent := filepath.Dir(path)
+		excludedBy := ignoredDirs[parent]
+		if useIgnoreFiles && excludedBy == nil && path != root {
+			rel, _ := filepath.Rel(root, path)
+			excludedBy = ignoredBy(rules[parent], filepath.ToSlash(rel), info.IsDir())
+		}
+
+		if info.IsDir() {
+			if excludedBy != nil {
+				ignoredDirs[path] = excludedBy
+				return nil
+			}
+			rules[path] = rules[parent]
+			if useIgnoreFiles {
+				rel, _ := filepath.Rel(root, path)
+				if rel == "." {
+					rel = ""
+				}
+				loaded, own, err := loadIgnoreFile(path, filepath.ToSlash(rel))
+				if err != nil {
+					return err
+				}
+				if loaded != nil {
+					loaded.path = dir + strings.TrimPrefix(loaded.path, root)
+					ignoreFiles = append(ignoreFiles, loaded)
+					rules[path] = append(slices.Clip(rules[parent]), own...)
+				}
+			}
+			return nil
+		}
+
+		ext := strings.ToLower(filepath.Ext(info.Name()))
+		if ext == ".avif" && !avifSupported {
+			logger.Warn("skipping AVIF image, support requires building with -tags avif (cgo and libavif)", "path", path)
+			return nil
+		}
+
+		if supportedExts[ext] && excludedBy != nil {
+			excludedBy.excluded++
+		} else if supportedExts[ext] {
+			if root != dir {
+				path = dir + strings.TrimPrefix(path, root)
+			}
+			imageFiles = append(imageFiles, path)
+		}
+
+		return nil
+	})
+	if err == nil {
+		reportIgnoreFiles(status, ignoreFiles)
+	}
+
+	return imageFiles, err
+}
+
+// sourceNameKeys returns the names a list file may use to refer to a source image:
+// its base name and its slash-separated path relative to the input directory
+func sourceNameKeys(sourcePath, inputDir string) []string {
+	keys := []string{filepath.Base(sourcePath)}
+	if rel, err := filepath.Rel(inputDir, sourcePath); err == nil {
+		keys = append(keys, filepath.ToSlash(rel))
+	}
+	return keys
+}
+
+// pageBasisValues are the statistics --page-basis can derive the page size from
+var pageBasisValues = []string{"mean", "median", "max", "first"}
+
+// validatePageBasis checks the --page-basis value
+func validatePageBasis(basis string) error {
+	for _, value := range pageBasisValues {
+		if basis == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid page basis %q, valid values are: %s", basis, strings.Join(pageBasisValues, ", "))
+}
+
+// calculatePageBasisSize gathers the dimensions of all images and returns the width and
+// height given by the chosen statistic (mean, median, max, or first), computed per axis
+func calculatePageBasisSize(ctx context.Context, status io.Writer, imageFiles []string, basis string, retry *retrier) (float64, float64, error) {
+	if len(imageFiles) == 0 {
+		return 0, 0, fmt.Errorf("no image files provided")
+	}
+
+	var widths, heights []float64
+
+	for _, imagePath := range imageFiles {
+		var imgConfig image.Config
+		err := retry.do(ctx, imagePath, func() error {
+			file, err := os.Open(longPath(imagePath))
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			imgConfig, _, err = image.DecodeConfig(file)
+			return err
+		})
+		if err != nil {
+			logger.Warn("could not read image size", "path", imagePath, "error", err)
+			continue
+		}
+
+		widths = append(widths, float64(imgConfig.Width))
+		heights = append(heights, float64(imgConfig.Height))
+	}
+
+	return pageBasis(status, widths, heights, basis, "pixels")
+}
+
+// pageBasis prints a summary of the image sizes and returns the one the basis statistic picks
+func pageBasis(status io.Writer, widths, heights []float64, basis, unit string) (float64, float64, error) {
+	if len(widths) == 0 {
+		return 0, 0, fmt.Errorf("no valid images found")
+	}
+
+	widthStats := summarizeDimensions(widths)
+	heightStats := summarizeDimensions(heights)
+	fmt.Fprintf(status, "Image dimensions: min %.0fx%.0f, median %.1fx%.1f, mean %.1fx%.1f, max %.0fx%.0f %s\n",
+		widthStats.min, heightStats.min, widthStats.median, heightStats.median,
+		widthStats.mean, heightStats.mean, widthStats.max, heightStats.max, unit)
+
+	switch basis {
+	case "median":
+		return widthStats.median, heightStats.median, nil
+	case "max":
+		return widthStats.max, heightStats.max, nil
+	case "first":
+		return widths[0], heights[0], nil
+	default:
+		return widthStats.mean, heightStats.mean, nil
+	}
+}
+
+// dimensionStats summarizes the sizes observed along one axis
+type dimensionStats struct {
+	min, median, mean, max float64
+}
+
+// summarizeDimensions computes min, median, mean and max of a non-empty list of sizes
+func summarizeDimensions(values []float64) dimensionStats {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	var total float64
+	for _, v := range sorted {
+		total += v
+	}
+
+	n := len(sorted)
+	median := sorted[n/2]
+	if n%2 == 0 {
+		median = (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+
+	return dimensionStats{
+		min:    sorted[0],
+		median: median,
+		mean:   total / float64(n),
+		max:    sorted[n-1],
+	}
+}
+
+// checkAndReportFileSize checks the PDF file size against --max-size, or 3 MB without it, and provides feedback
+func checkAndReportFileSize(status io.Writer, filePath string, maxSize, projected int64) error {
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	reportFileSize(status, fileInfo.Size(), maxSize, projected)
+	return nil
+}
+
+// reportFileSize prints the PDF size next to its projection and suggestions when it is over
+// --max-size or the default target
+func reportFileSize(status io.Writer, fileSizeBytes, maxSize, projected int64) {
+	fileSizeMB := float64(fileSizeBytes) / (1024 * 1024)
+
+	fmt.Fprintf(status, "PDF file size: %.2f MB\n", fileSizeMB)
+	// Shows how far off the overhead model is
+	if projected > 0 {
+		fmt.Fprintf(status, "Projected %.2f MB, actual %.2f MB (%+.1f%%)\n", megabytes(projected), fileSizeMB,
+			float64(fileSizeBytes-projected)/float64(projected)*100)
+	}
+
+	targetSizeMB := megabytes(sizeTarget(maxSize))
+	if fileSizeMB > targetSizeMB {
+		fmt.Fprintf(status, "⚠️  Warning: PDF size (%.2f MB) exceeds target of %.1f MB\n", fileSizeMB, targetSizeMB)
+		fmt.Fprintf(status, "Suggestions to reduce size:\n")
+		fmt.Fprintf(status, "  • Use JPEG images instead of PNG for photos\n")
+		fmt.Fprintf(status, "  • Reduce image resolution before processing\n")
+		fmt.Fprintf(status, "  • Consider processing fewer images per PDF\n")
+	} else {
+		fmt.Fprintf(status, "✅ PDF size is within the %.1f MB target\n", targetSizeMB)
+	}
+}
+
+// convertToJPEG converts a single image to JPEG format with adaptive quality compression
+func convertToJPEG(status io.Writer, imagePath, outputDir string) (string, error) {
+	// Open and decode the source image
+	srcFile, err := os.Open(longPath(imagePath))
+	if err != nil {
+		return "", err
+	}
+	defer srcFile.Close()
+
+	img, _, err := image.Decode(srcFile)
+	if err != nil {
+		return "", err
+	}
+
+	// Scale image to 800px width with proportional height
+	img = scaleImageToWidth(img, optimizedWidth)
+
+	// Generate output filename
+	baseName := strings.TrimSuffix(filepath.Base(imagePath), filepath.Ext(imagePath))
+	outputPath := filepath.Join(outputDir, baseName+".jpg")
+
+	// Get image dimensions for adaptive quality
+	bounds := img.Bounds()
+	width := bounds.Max.X - bounds.Min.X
+	height := bounds.Max.Y - bounds.Min.Y
+	totalPixels := width * height
+
+	// Calculate adaptive quality based on image size
+	quality := calculateAdaptiveQuality(totalPixels)
+
+	// Try compression with iterative quality reduction if needed
+	encode := func(quality int) (int64, error) {
+		outFile, err := os.Create(longPath(outputPath))
+		if err != nil {
+			return 0, err
+		}
+		err = jpeg.Encode(outFile, img, &jpeg.Options{Quality: quality})
+		outFile.Close()
+		if err != nil {
+			return 0, err
+		}
+		fileInfo, err := os.Stat(outputPath)
+		if err != nil {
+			return 0, err
+		}
+		return fileInfo.Size(), nil
+	}
+	if _, _, err := compressImageWithTargetSize(status, encode, quality, 500*1024); err != nil { // 500KB per image target
+		return "", err
+	}
+
+	return outputPath, nil
+}
+
+// isJPEGFile checks if the file is already a JPEG
+func isJPEGFile(filePath string) bool {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	return ext == ".jpg" || ext == ".jpeg"
+}
+
+// isEmbeddableFile reports whether the PDF can embed the file as it is: maroto only takes JPEG and
+// PNG, other formats come out as an empty page unless they are re-encoded
+func isEmbeddableFile(filePath string) bool {
+	return isJPEGFile(filePath) || strings.ToLower(filepath.Ext(filePath)) == ".png"
+}
+
+// copyFile copies a file from source to destination
+func copyFile(src, dst string) error {
+	srcFile, err := os.Open(longPath(src))
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.Create(longPath(dst))
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	_, err = dstFile.ReadFrom(srcFile)
+	return err
+}
+
+// cleanupConvertedImages removes the temporary directory holding the converted image files
+func cleanupConvertedImages(status io.Writer, tempDir string) {
+	if _, err := os.Stat(tempDir); os.IsNotExist(err) {
+		return
+	}
+
+	// Remove the entire temp directory
+	if err := os.RemoveAll(tempDir); err != nil {
+		logger.Warn("failed to clean up temp directory", "path", tempDir, "error", err)
+	} else {
+		fmt.Fprintf(status, "Cleaned up temporary converted images\n")
+	}
+}
+
+// calculateAdaptiveQuality determines optimal JPEG quality based on image characteristics
+func calculateAdaptiveQuality(totalPixels int) int {
+	baseQuality := 85 // Start with high quality
+
+	// Adjust quality based on image size
+	if totalPixels > 4000000 { // Very large images (4MP+)
+		baseQuality = 75 // More compression for large images
+	} else if totalPixels > 2000000 { // Large images (2MP+)
+		baseQuality = 80
+	} else if totalPixels > 1000000 { // Medium images (1MP+)
+		baseQuality = 85
+	} else { // Small images
+		baseQuality = 90 // Less compression to preserve detail
+	}
+
+	// Ensure quality is within valid range
+	if baseQuality > 95 {
+		baseQuality = 95
+	} else if baseQuality < 60 {
+		baseQuality = 60
+	}
+
+	return baseQuality
+}
+
+// compressImageWithTargetSize compresses image with iterative quality adjustment: encode writes the
+// image at a quality and returns the file size, returned are the size and quality of the last attempt
+func compressImageWithTargetSize(status io.Writer, encode func(quality int) (int64, error), startQuality int, maxFileSize int64) (int64, int, error) {
+	quality := startQuality
+	var fileSize int64
+
+	for attempts := 0; attempts < 4; attempts++ {
+		// Encode with current quality and check file size
+		var err error
+		if fileSize, err = encode(quality); err != nil {
+			return 0, 0, err
+		}
+
+		// If size is acceptable or quality is already very low, accept it
+		if fileSize <= maxFileSize || quality <= 50 {
+			if attempts > 0 {
+				fmt.Fprintf(status, "    → Compressed to %d KB (quality: %d)\n", fileSize/1024, quality)
+			}
+			return fileSize, quality, nil
+		}
+
+		// Reduce quality for next attempt
+		quality -= 15
+		if quality < 50 {
+			quality = 50
+		}
+	}
+
+	return fileSize, quality, nil
+}
+
+// optimizedImage links an optimized temporary image back to the source file it was made from
+type optimizedImage struct {
+	sourcePath     string
+	inputDir       string
+	path           string
+	sourceSHA256   string
+	strategy       string
+	originalWidth  int
+	originalHeight int
+	width          int
+	height         int
+	originalSize   int64
+	size           int64
+	sourceWidth    int           // width after orientation and rotation, before downscaling
+	sourceDPI      float64       // declared density of the source, 0 if it has none
+	captured       time.Time     // EXIF capture or modification time, zero when unknown
+	description    string        // EXIF ImageDescription, the default alternate text of --tagged
+	quality        int           // JPEG quality of re-encoded JPEGs, 0 otherwise
+	probe          *qualityProbe // size model for --budget-mode global
+	thumbnail      []byte        // only made for --report
+	counterpart    string        // source of the other side of the sheet, for the blank placeholders of interleaving
+	blank          bool          // dropped by --skip-blank, kept in the list until the pages are paired
+}
+
+// optimizedPaths returns the temporary file paths of the optimized images, skipping blank placeholders
+func optimizedPaths(images []optimizedImage) []string {
+	paths := make([]string, 0, len(images))
+	for _, img := range images {
+		if img.path != "" {
+			paths = append(paths, img.path)
+		}
+	}
+	return paths
+}
+
+// setInputDir records which of the inputs the images were read from
+func setInputDir(images []optimizedImage, inputs []string) {
+	for i := range images {
+		images[i].inputDir = inputFor(images[i].sourcePath, inputs)
+	}
+}
+
+// discoverImages collects the images of all inputs into one list and sorts it
+func discoverImages(ctx context.Context, inputs []string, retry *retrier, opts Options) ([]string, error) {
+	imageFiles, err := collectInputs(ctx, opts.status(), inputs, retry, !opts.NoIgnoreFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(imageFiles) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoImages, strings.Join(inputs, ", "))
+	}
+
+	// Sort files by name
+	if err := sortImageFiles(imageFiles, opts.Sort == "natural", opts.SortCaseInsensitive, opts.CollateLocale); err != nil {
+		return nil, err
+	}
+	return imageFiles, nil
+}
+
+// convertImagesToOptimizedJPEG applies efficient compression while maintaining PDF readability
+func convertImagesToOptimizedJPEG(ctx context.Context, imageFiles []string, rotations map[string]int, tempDir string, budget *memoryBudget, retry *retrier, opts Options) ([]optimizedImage, error) {
+	var convertedFiles []optimizedImage
+	var skippedBlank, skippedLimit []string
+
+	// Create temporary directory for converted images
+	if err := os.MkdirAll(longPath(tempDir), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+
+	fmt.Fprintf(opts.status(), "Applying efficient compression while maintaining PDF readability...\n")
+
+	for i, imagePath := range imageFiles {
+		// Stop scheduling new work once cancelled, the image in flight has already finished
+		if err := ctx.Err(); err != nil {
+			return convertedFiles, err
+		}
+		if err := opts.report(StageOptimize, i+1, len(imageFiles), imagePath); err != nil {
+			return convertedFiles, err
+		}
+
+		fmt.Fprintf(opts.status(), "Optimizing %d/%d: %s\n", i+1, len(imageFiles), filepath.Base(imagePath))
+
+		converted, err := convertToEfficientCompression(ctx, imagePath, tempDir, rotations[imagePath], budget, retry, opts)
+		var blank *blankPageError
+		if errors.As(err, &blank) {
+			fmt.Fprintf(opts.status(), "    → skipped, %.2f%% blank\n", blank.score)
+			skippedBlank = append(skippedBlank, fmt.Sprintf("%s (%.2f%% blank)", filepath.Base(imagePath), blank.score))
+			// Kept in place until interleaving has paired the fronts and backs, see dropBlankPages
+			convertedFiles = append(convertedFiles, optimizedImage{sourcePath: imagePath, blank: true})
+			continue
+		}
+		var limit *decodeLimitError
+		if errors.As(err, &limit) {
+			if opts.Strict {
+				return convertedFiles, fmt.Errorf("%s: %w", imagePath, err)
+			}
+			fmt.Fprintf(opts.status(), "    → skipped, %s\n", limit.reason)
+			skippedLimit = append(skippedLimit, fmt.Sprintf("%s (%s)", filepath.Base(imagePath), limit.reason))
+			continue
+		}
+		if err != nil {
+			logger.Warn("skipping image that failed to optimize", "path", imagePath, "error", err)
+			continue
+		}
+		convertedFiles = append(convertedFiles, converted)
+	}
+
+	fmt.Fprintf(opts.status(), "Successfully optimized %d images for PDF readability\n", len(convertedFiles)-len(skippedBlank))
+	if len(skippedBlank) > 0 {
+		fmt.Fprintf(opts.status(), "Skipped %d blank page(s):\n", len(skippedBlank))
+		for _, page := range skippedBlank {
+			fmt.Fprintf(opts.status(), "  • %s\n", page)
+		}
+	}
+	if len(skippedLimit) > 0 {
+		fmt.Fprintf(opts.status(), "⚠️  Skipped %d image(s) that exceed the decode limits:\n", len(skippedLimit))
+		for _, skipped := range skippedLimit {
+			fmt.Fprintf(opts.status(), "  • %s\n", skipped)
+		}
+	}
+	return convertedFiles, nil
+}
+
+// optimizedWidth is the width in pixels re-encoded images are scaled down to
+const optimizedWidth = 800
+
+// scaleImageToWidth scales an image to a specific width while maintaining aspect ratio
+func scaleImageToWidth(img image.Image, targetWidth int) image.Image {
+	bounds := img.Bounds()
+	srcWidth := bounds.Max.X - bounds.Min.X
+	srcHeight := bounds.Max.Y - bounds.Min.Y
+
+	// If image is already smaller than target width, keep original size
+	if srcWidth <= targetWidth {
+		return img
+	}
+
+	// Calculate proportional height
+	scale := float64(targetWidth) / float64(srcWidth)
+	targetHeight := int(float64(srcHeight) * scale)
+
+	// Create new scaled image
+	scaled := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+	sample := rgbaSampler(img)
+
+	// Simple scaling using nearest neighbor, the source column of each target column is the same on every row
+	srcXs := make([]int, targetWidth)
+	for x := range srcXs {
+		srcX := int(float64(x) / scale)
+		// Ensure we don't go out of bounds
+		if srcX >= srcWidth {
+			srcX = srcWidth - 1
+		}
+		srcXs[x] = bounds.Min.X + srcX
+	}
+
+	for y := 0; y < targetHeight; y++ {
+		srcY := int(float64(y) / scale)
+		if srcY >= srcHeight {
+			srcY = srcHeight - 1
+		}
+
+		dstRow := scaled.Pix[y*scaled.Stride:]
+		for x, srcX := range srcXs {
+			sample(srcX, bounds.Min.Y+srcY, dstRow[x*4:x*4+4])
+		}
+	}
+
+	return scaled
+}
+
+// rgbaSampler returns a function writing the premultiplied 8-bit RGBA value of a pixel into dst,
+// reading the pixel buffer directly for the types decoders commonly return
+func rgbaSampler(img image.Image) func(x, y int, dst []byte) {
+	switch src := img.(type) {
+	case *image.RGBA:
+		return func(x, y int, dst []byte) {
+			i := src.PixOffset(x, y)
+			copy(dst, src.Pix[i:i+4])
+		}
+	case *image.YCbCr:
+		return func(x, y int, dst []byte) {
+			yi, ci := src.YOffset(x, y), src.COffset(x, y)
+			dst[0], dst[1], dst[2] = color.YCbCrToRGB(src.Y[yi], src.Cb[ci], src.Cr[ci])
+			dst[3] = 0xff
+		}
+	case *image.Gray:
+		return func(x, y int, dst []byte) {
+			v := src.Pix[src.PixOffset(x, y)]
+			dst[0], dst[1], dst[2], dst[3] = v, v, v, 0xff
+		}
+	}
+
+	return func(x, y int, dst []byte) {
+		r, g, b, a := img.At(x, y).RGBA()
+		dst[0], dst[1], dst[2], dst[3] = uint8(r>>8), uint8(g>>8), uint8(b>>8), uint8(a>>8)
+	}
+}
+
+// convertToEfficientCompression applies the most efficient compression for PDF readability
+func convertToEfficientCompression(ctx context.Context, imagePath, outputDir string, rotation int, budget *memoryBudget, retry *retrier, opts Options) (optimizedImage, error) {
+	// Read the source once so the ICC profile and pixel data come from the same bytes.
+	// The file info is only kept for the modification time --date-stamp falls back to.
+	var data []byte
+	var info os.FileInfo
+	err := retry.do(ctx, imagePath, func() (err error) {
+		if data, err = os.ReadFile(longPath(imagePath)); err != nil {
+			return err
+		}
+		info, err = os.Stat(longPath(imagePath))
+		return err
+	})
+	if err != nil {
+		return optimizedImage{}, err
+	}
+
+	imgConfig, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return optimizedImage{}, err
+	}
+	if imgConfig.Width == 0 || imgConfig.Height == 0 {
+		return optimizedImage{}, fmt.Errorf("empty %dx%d image", imgConfig.Width, imgConfig.Height)
+	}
+	sourceConfig, density := imgConfig, sourceDensity(data)
+
+	// Images over --max-decode-pixels fall back to their embedded EXIF thumbnail, or are skipped
+	pixelData := data
+	if pixels := int64(imgConfig.Width) * int64(imgConfig.Height); opts.MaxDecodePixels > 0 && pixels > opts.MaxDecodePixels {
+		pixelData = exifThumbnail(data)
+		if pixelData == nil {
+			return optimizedImage{}, &decodeLimitError{reason: fmt.Sprintf("%s is over --max-decode-pixels %s and there is no embedded thumbnail", megapixels(pixels), megapixels(opts.MaxDecodePixels))}
+		}
+		if imgConfig, _, err = image.DecodeConfig(bytes.NewReader(pixelData)); err != nil {
+			return optimizedImage{}, err
+		}
+		density *= float64(imgConfig.Width) / float64(sourceConfig.Width)
+		fmt.Fprintf(opts.status(), "    → %s is over the decode limit, using the embedded %dx%d thumbnail\n", megapixels(pixels), imgConfig.Width, imgConfig.Height)
+	}
+
+	// Reserve the decoded size until the optimized file is written, or until an abandoned decode finishes
+	footprint := decodedSize(imgConfig)
+	if footprint > budget.size {
+		logger.Info("image exceeds --max-memory, decoding it alone", "path", imagePath, "bytes", footprint)
+	}
+	reserved, err := budget.acquire(ctx, footprint)
+	if err != nil {
+		return optimizedImage{}, err
+	}
+	handedOff := false
+	defer func() {
+		if !handedOff {
+			budget.release(reserved)
+		}
+	}()
+
+	img, err := decodeWithTimeout(pixelData, opts.DecodeTimeout, func() { budget.release(reserved) })
+	var limit *decodeLimitError
+	if errors.As(err, &limit) && limit.timeout {
+		handedOff = true
+	}
+	if err != nil {
+		return optimizedImage{}, err
+	}
+	originalBounds := img.Bounds()
+	if originalBounds.Dx() == 0 || originalBounds.Dy() == 0 {
+		return optimizedImage{}, fmt.Errorf("decoded to an empty %dx%d image", originalBounds.Dx(), originalBounds.Dy())
+	}
+
+	// Scanners emit images for blank backs, drop them before any further work
+	if opts.SkipBlank {
+		if score := blankScore(img, opts.BlankTolerance); score >= opts.BlankThreshold {
+			return optimizedImage{}, &blankPageError{score: score}
+		}
+	}
+
+	// Everything below works on 8-bit pixels, so reduce 16-bit sources first with proper rounding
+	if isHighBitDepth(img) {
+		img = reduceBitDepth(img, opts.Dither)
+		if opts.Dither {
+			fmt.Fprintf(opts.status(), "    → reduced 16-bit image to 8 bits with ordered dithering\n")
+		} else {
+			fmt.Fprintf(opts.status(), "    → reduced 16-bit image to 8 bits\n")
+		}
+	}
+
+	// Apply EXIF orientation now, stripping metadata or re-encoding would otherwise leave the image sideways
+	orientation := exifOrientation(data)
+	img = applyOrientation(img, orientation)
+
+	// Manual rotation for scans fed sideways, applied before scaling so dimensions are final
+	img = rotateImage(img, rotation)
+
+	// Receipts and other small originals on a large scan are cut out, so they fill their page
+	cropped := false
+	if opts.ContentFit {
+		dpi := density
+		if dpi == 0 {
+			dpi = opts.DPI
+		}
+		img, cropped = fitToContent(opts.status(), img, imagePath, opts.BlankTolerance, int(opts.ContentPadding/25.4*dpi+0.5))
+	}
+
+	// RGB profiles are passed through, and re-encoded images get them back. --convert-srgb converts
+	// the pixels of other RGB profiles to sRGB instead, for viewers that show embedded JPEGs as sRGB
+	// whatever profile they carry. Profiles of other color spaces don't describe the decoded RGB
+	// pixels and are dropped.
+	iccProfile := extractICCProfile(data)
+	convertedToSRGB := false
+	if space := iccColorSpace(iccProfile); iccProfile != nil && space != "RGB" {
+		logger.Warn("ignoring ICC profile that isn't RGB", "path", imagePath, "color_space", space)
+		iccProfile = nil
+	}
+	if iccProfile != nil && opts.ConvertSRGB {
+		if profile, parseErr := parseICCMatrixProfile(iccProfile); parseErr == nil && profile.isSRGB() {
+			iccProfile = nil
+		} else if converted, convErr := convertToSRGB(img, iccProfile); convErr != nil {
+			logger.Warn("could not convert to sRGB, colors may be off", "path", imagePath, "error", convErr)
+		} else {
+			img = converted
+			iccProfile = nil
+			convertedToSRGB = true
+		}
+	}
+
+	// Scale image to 800px width with proportional height
+	srcWidth := img.Bounds().Dx()
+	img = scaleImageToWidth(img, optimizedWidth)
+
+	// Restore the edges downscaling softened, images kept at their size are left alone
+	if scaled, ok := img.(*image.RGBA); ok && opts.Sharpen && scaled.Bounds().Dx() < srcWidth {
+		unsharpMask(scaled, opts.SharpenAmount)
+		fmt.Fprintf(opts.status(), "    → sharpened after downscaling (amount %g)\n", opts.SharpenAmount)
+	}
+
+	// The report preview comes from the scaled pixels, the source is not decoded again
+	var thumbnail []byte
+	if opts.ReportPath != "" {
+		thumbnail = reportThumbnail(img, opts.Background)
+	}
+
+	// Analyze image characteristics
+	bounds := img.Bounds()
+	width := bounds.Max.X - bounds.Min.X
+	height := bounds.Max.Y - bounds.Min.Y
+	totalPixels := width * height
+
+	originalSize := int64(len(data))
+
+	// Determine optimal compression strategy. The original file still carries the source profile and
+	// PDF viewers ignore EXIF orientation, so converted, rotated or cropped pixels, a thumbnail standing
+	// in for a source too large to embed and formats the PDF can't hold must be re-encoded.
+	analysis := imageAnalysis{
+		ext:          strings.ToLower(filepath.Ext(imagePath)),
+		originalSize: originalSize,
+		totalPixels:  totalPixels,
+		lineArt:      analyzeLineArt(img),
+		reencode:     convertedToSRGB || len(pixelData) != len(data) || orientation > 1 || rotation != 0 || cropped || !isEmbeddableFile(imagePath),
+		strategy:     opts.Strategy,
+		paletteSize:  opts.QuantizeColors,
+	}
+	strategy := determineCompressionStrategy(analysis)
+	if stats := analysis.lineArt; opts.Strategy == "auto" && strategy != "keep_original" && stats.isLineArt() {
+		fmt.Fprintf(opts.status(), "    → line art detected (%d colors, %.0f%% flat, %.1f%% hard edges)\n",
+			stats.colors, stats.flat*100, stats.sharp*100)
+	}
+
+	// PNG output carries no color profile, so convert the pixels instead
+	if (strategy == "lossless_png" || strategy == "quantize_png" || strategy == "quantize_or_jpeg") && iccProfile != nil {
+		if converted, convErr := convertToSRGB(img, iccProfile); convErr != nil {
+			logger.Warn("could not convert to sRGB, colors may be off", "path", imagePath, "error", convErr)
+		} else {
+			img = converted
+		}
+		iccProfile = nil
+	}
+
+	logger.Debug("compression strategy", "path", imagePath, "strategy", strategy,
+		"width", width, "height", height, "bytes", originalSize, "orientation", orientation, "rotation", rotation)
+
+	baseName := strings.TrimSuffix(filepath.Base(imagePath), filepath.Ext(imagePath))
+	var outputPath string
+	var finalSize int64
+
+	switch strategy {
+	case "keep_original":
+		// Keep original if it's already optimal
+		outputPath = filepath.Join(outputDir, filepath.Base(imagePath))
+		if opts.StripMetadata && isJPEGFile(imagePath) {
+			// The copy is embedded verbatim, so drop EXIF/GPS and other metadata first
+			stripped := stripJPEGMetadata(data)
+			err = os.WriteFile(longPath(outputPath), stripped, 0644)
+			finalSize = int64(len(stripped))
+		} else {
+			err = copyFile(imagePath, outputPath)
+			finalSize = originalSize
+		}
+
+	case "optimize_jpeg":
+		// Convert to optimized JPEG for better PDF compression
+		outputPath = filepath.Join(outputDir, baseName+".jpg")
+		err = compressToOptimalJPEG(img, outputPath, totalPixels, opts.Quality)
+		if fileInfo, statErr := os.Stat(outputPath); statErr == nil {
+			finalSize = fileInfo.Size()
+		}
+
+	case "convert_png_to_jpeg", "convert_avif_to_jpeg":
+		// Convert PNG photos and AVIF images to JPEG (better for PDF), flattening alpha
+		outputPath = filepath.Join(outputDir, baseName+".jpg")
+		err = convertPNGToOptimalJPEG(img, outputPath, totalPixels, opts.Quality, opts.Background)
+		if fileInfo, statErr := os.Stat(outputPath); statErr == nil {
+			finalSize = fileInfo.Size()
+		}
+
+	case "lossless_png":
+		// Keep every pixel for line art, maroto embeds PNG as-is
+		outputPath = filepath.Join(outputDir, baseName+".png")
+		err = encodeLosslessPNG(img, outputPath)
+		if fileInfo, statErr := os.Stat(outputPath); statErr == nil {
+			finalSize = fileInfo.Size()
+		}
+
+	case "quantize_png":
+		// Reduce to a palette, flat UI colors survive exactly and the PNG stays small
+		outputPath = filepath.Join(outputDir, baseName+".png")
+		finalSize, err = writeQuantizedPNG(img, outputPath, opts)
+
+	case "quantize_or_jpeg":
+		// Line art with few colors: whichever of the indexed PNG and a JPEG is smaller wins
+		strategy, outputPath, finalSize, err = smallerOfQuantizedAndJPEG(img, filepath.Join(outputDir, baseName), totalPixels, opts)
+
+	default:
+		// Fallback to original
+		outputPath = filepath.Join(outputDir, filepath.Base(imagePath))
+		err = copyFile(imagePath, outputPath)
+		finalSize = originalSize
+	}
+
+	if err != nil {
+		return optimizedImage{}, err
+	}
+
+	// Re-encoding drops the ICC profile, so put it back to keep viewers from assuming sRGB
+	if iccProfile != nil && strategy != "keep_original" {
+		if err := embedICCProfile(outputPath, iccProfile); err != nil {
+			return optimizedImage{}, fmt.Errorf("failed to embed ICC profile: %v", err)
+		}
+		if fileInfo, statErr := os.Stat(outputPath); statErr == nil {
+			finalSize = fileInfo.Size()
+		}
+	}
+
+	// Re-encoded JPEGs are fitted to --max-size by lowering their quality, see budget.go
+	var probe *qualityProbe
+	quality := 0
+	if isJPEGStrategy(strategy) {
+		quality = pngJPEGQuality(totalPixels, opts.Quality)
+		if strategy == "optimize_jpeg" {
+			quality = optimalJPEGQuality(totalPixels, opts.Quality)
+		}
+		encode := jpegEncoder(strategy, img, outputPath, totalPixels, iccProfile, opts)
+		switch {
+		case opts.BudgetMode == "global" && opts.MaxSize > 0:
+			probe, finalSize, quality, err = probeJPEGQuality(encode, quality, finalSize)
+		case opts.imageBudget > 0 && finalSize > opts.imageBudget:
+			finalSize, quality, err = compressImageWithTargetSize(opts.status(), encode, max(quality-15, 50), opts.imageBudget)
+		}
+		if err != nil {
+			return optimizedImage{}, fmt.Errorf("failed to fit image into the size budget: %v", err)
+		}
+	}
+
+	// Report compression results
+	compressionRatio := float64(originalSize-finalSize) / float64(originalSize) * 100
+	if compressionRatio > 0 {
+		fmt.Fprintf(opts.status(), "    → %s: %d KB → %d KB (%.1f%% reduction)\n",
+			strategy, originalSize/1024, finalSize/1024, compressionRatio)
+	} else if strategy == "keep_original" {
+		fmt.Fprintf(opts.status(), "    → %s: %d KB (kept original)\n", strategy, originalSize/1024)
+	} else {
+		fmt.Fprintf(opts.status(), "    → %s: %d KB → %d KB (%.1f%% larger)\n",
+			strategy, originalSize/1024, finalSize/1024, -compressionRatio)
+	}
+
+	// keep_original embeds the source file unscaled
+	if strategy == "keep_original" {
+		width, height = originalBounds.Dx(), originalBounds.Dy()
+	}
+
+	return optimizedImage{
+		sourcePath:     imagePath,
+		path:           outputPath,
+		sourceSHA256:   fmt.Sprintf("%x", sha256.Sum256(data)),
+		strategy:       strategy,
+		originalWidth:  sourceConfig.Width,
+		originalHeight: sourceConfig.Height,
+		sourceWidth:    srcWidth,
+		sourceDPI:      density,
+		captured:       captureTime(data, info),
+		description:    exifDescription(data),
+		width:          width,
+		height:         height,
+		originalSize:   originalSize,
+		size:           finalSize,
+		quality:        quality,
+		probe:          probe,
+		thumbnail:      thumbnail,
+	}, nil
+}
+
+// imageAnalysis is what determineCompressionStrategy decides on: the source file and the sampled
+// pixels of the decoded image
+type imageAnalysis struct {
+	ext          string // lowercase extension of the source file
+	originalSize int64  // source file size in bytes
+	totalPixels  int    // after scaling
+	lineArt      lineArtStats
+	reencode     bool   // the source file can't be embedded as-is
+	strategy     string // --strategy
+	paletteSize  int    // --quantize-colors
+}
+
+// determineCompressionStrategy analyzes image and determines best compression approach
+func determineCompressionStrategy(a imageAnalysis) string {
+	strategy := sizeStrategy(a.ext, a.originalSize, a.totalPixels)
+	if strategy == "keep_original" {
+		if !a.reencode {
+			return strategy
+		}
+		strategy = reencodeStrategy(a.ext)
+	}
+
+	switch a.strategy {
+	case "jpeg":
+		return strategy
+	case "lossless":
+		return "lossless_png"
+	case "quantize":
+		return "quantize_png"
+	}
+
+	// Screenshots and diagrams get ringing artifacts from JPEG, embed them as PNG instead.
+	// A PNG source compressed well losslessly already, so it stays PNG: indexed when its colors fit
+	// a palette exactly. Other line art with few colors keeps the smaller of the indexed PNG and a JPEG.
+	if !a.lineArt.isLineArt() {
+		return strategy
+	}
+	if a.ext == ".png" {
+		if a.lineArt.colors <= a.paletteSize {
+			return "quantize_png"
+		}
+		return "lossless_png"
+	}
+	if a.lineArt.colors <= quantizeMaxSourceColors {
+		return "quantize_or_jpeg"
+	}
+	return "lossless_png"
+}
+
+// sizeStrategy is the choice by file type and size alone, before the pixels are considered
+func sizeStrategy(ext string, originalSize int64, totalPixels int) string {
+	// AVIF can't be embedded in the PDF directly, so it always goes through the PNG flatten path
+	if ext == ".avif" {
+		return "convert_avif_to_jpeg"
+	}
+
+	// For very small files, keep original
+	if originalSize < 50*1024 { // Less than 50KB
+		return "keep_original"
+	}
+
+	// For already small JPEG files, keep them
+	if (ext == ".jpg" || ext == ".jpeg") && originalSize < 200*1024 {
+		return "keep_original"
+	}
+
+	// For PNG files that are likely photos (large with many pixels), convert to JPEG
+	if ext == ".png" && totalPixels > 100000 && originalSize > 500*1024 {
+		return "convert_png_to_jpeg"
+	}
+
+	// For large JPEG files, optimize them
+	if (ext == ".jpg" || ext == ".jpeg") && originalSize > 300*1024 {
+		return "optimize_jpeg"
+	}
+
+	// For other large files, convert to optimized JPEG
+	if originalSize > 400*1024 {
+		return reencodeStrategy(ext)
+	}
+
+	// Default: keep original for small/medium files
+	return "keep_original"
+}
+
+// reencodeStrategy is the JPEG strategy for a source of this type. Only JPEG sources are known to
+// be opaque, PNG, GIF and the rest may carry alpha and go through the path flattening it.
+func reencodeStrategy(ext string) string {
+	if ext == ".jpg" || ext == ".jpeg" {
+		return "optimize_jpeg"
+	}
+	return "convert_png_to_jpeg"
+}
+
+// compressToOptimalJPEG compresses image to JPEG with optimal settings for PDF readability
+// A positive qualityOverride replaces the size-based choice.
+func compressToOptimalJPEG(img image.Image, outputPath string, totalPixels int, qualityOverride int) error {
+	quality := optimalJPEGQuality(totalPixels, qualityOverride)
+
+	// Create output file
+	outFile, err := os.Create(longPath(outputPath))
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+
+	// Encode with optimal settings
+	options := &jpeg.Options{Quality: quality}
+	return jpeg.Encode(outFile, img, options)
+}
+
+// optimalJPEGQuality is the quality compressToOptimalJPEG encodes an image of this size with
+func optimalJPEGQuality(totalPixels int, qualityOverride int) int {
+	if qualityOverride > 0 {
+		return qualityOverride
+	}
+
+	// Adjust quality based on image size for optimal PDF readability
+	if totalPixels > 2000000 { // Very large images (2MP+)
+		return 65 // Acceptable compression for large images
+	} else if totalPixels > 1000000 { // Large images (1MP+)
+		return 70 // Good balance
+	} else if totalPixels < 300000 { // Small images
+		return 80 // Preserve quality for small images
+	}
+	return 75 // High quality for readability
+}
+
+// convertPNGToOptimalJPEG converts PNG to JPEG with optimal settings for PDF
+// Transparent areas are flattened onto background. A positive qualityOverride replaces the size-based choice.
+func convertPNGToOptimalJPEG(img image.Image, outputPath string, totalPixels int, qualityOverride int, background color.RGBA) error {
+	// JPEG has no alpha channel
+	rgbImg := flattenImage(img, background)
+
+	quality := pngJPEGQuality(totalPixels, qualityOverride)
+
+	// Create output file
+	outFile, err := os.Create(longPath(outputPath))
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+
+	// Encode with optimal settings
+	options := &jpeg.Options{Quality: quality}
+	return jpeg.Encode(outFile, rgbImg, options)
+}
+
+// flattenImage returns an opaque copy of img, alpha blended over the background color
+func flattenImage(img image.Image, background color.RGBA) *image.RGBA {
+	bounds := img.Bounds()
+	flat := image.NewRGBA(bounds)
+	draw.Draw(flat, bounds, image.NewUniform(background), image.Point{}, draw.Src)
+	draw.Draw(flat, bounds, img, bounds.Min, draw.Over)
+	return flat
+}
+
+// pngJPEGQuality is the quality convertPNGToOptimalJPEG encodes an image of this size with,
+// higher than for photos to keep the text of screenshots readable
+func pngJPEGQuality(totalPixels int, qualityOverride int) int {
+	if qualityOverride > 0 {
+		return qualityOverride
+	}
+	if totalPixels > 2000000 {
+		return 82
+	}
+	return 88
+}
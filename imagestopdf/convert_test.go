@@ -0,0 +1,189 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"testing"
+)
+
+func TestPageBasis(t *testing.T) {
+	// One panorama among phone photos pulls the mean far from the typical page
+	skewedWidths := []float64{3000, 3000, 3000, 3000, 12000}
+	skewedHeights := []float64{4000, 4000, 4000, 4000, 2000}
+
+	for _, tc := range []struct {
+		name                  string
+		widths, heights       []float64
+		basis                 string
+		wantWidth, wantHeight float64
+	}{
+		{"mean skewed", skewedWidths, skewedHeights, "mean", 4800, 3600},
+		{"median skewed", skewedWidths, skewedHeights, "median", 3000, 4000},
+		{"max skewed", skewedWidths, skewedHeights, "max", 12000, 4000},
+		{"first skewed", skewedWidths, skewedHeights, "first", 3000, 4000},
+		{"median of an even count", []float64{100, 200, 300, 1000}, []float64{10, 20, 30, 40}, "median", 250, 25},
+		{"max per axis", []float64{100, 900}, []float64{800, 50}, "max", 900, 800},
+		{"single image", []float64{640}, []float64{480}, "median", 640, 480},
+		{"unknown basis falls back to the mean", []float64{100, 300}, []float64{100, 300}, "", 200, 200},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			width, height, err := pageBasis(io.Discard, tc.widths, tc.heights, tc.basis, "pixels")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if width != tc.wantWidth || height != tc.wantHeight {
+				t.Errorf("got %gx%g, want %gx%g", width, height, tc.wantWidth, tc.wantHeight)
+			}
+		})
+	}
+
+	if _, _, err := pageBasis(io.Discard, nil, nil, "mean", "pixels"); err == nil {
+		t.Error("no images should be an error")
+	}
+}
+
+func TestSummarizeDimensions(t *testing.T) {
+	got := summarizeDimensions([]float64{5, 1, 9, 3})
+	want := dimensionStats{min: 1, median: 4, mean: 4.5, max: 9}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+// posterize reduces every channel of img to levels steps, leaving bands without hard edges
+func posterize(img *image.RGBA, levels int) *image.RGBA {
+	step := 256 / levels
+	for i := range img.Pix {
+		img.Pix[i] = uint8(int(img.Pix[i]) / step * step)
+	}
+	return img
+}
+
+func TestDetermineCompressionStrategy(t *testing.T) {
+	const kb = 1024
+	screenshot := screenshotImage(1200, 800, false)
+	for _, tc := range []struct {
+		name     string
+		ext      string
+		size     int64
+		img      image.Image
+		reencode bool
+		strategy string
+		want     string
+	}{
+		{"small screenshot PNG", ".png", 90 * kb, screenshot, false, "auto", "keep_original"},
+		{"screenshot PNG fits a palette", ".png", 450 * kb, screenshot, false, "auto", "quantize_png"},
+		{"screenshot PNG with many colors", ".png", 600 * kb, screenshotImage(1200, 800, true), false, "auto", "lossless_png"},
+		{"large screenshot BMP", ".bmp", 900 * kb, screenshot, true, "auto", "quantize_or_jpeg"},
+		{"screenshot PNG with --strategy jpeg", ".png", 600 * kb, screenshot, false, "jpeg", "convert_png_to_jpeg"},
+		{"large JPEG photo", ".jpg", 900 * kb, photoImage(1600, 1200, 1), false, "auto", "optimize_jpeg"},
+		{"small JPEG photo", ".jpeg", 150 * kb, photoImage(1600, 1200, 1), false, "auto", "keep_original"},
+		{"PNG photo", ".png", 2048 * kb, photoImage(1600, 1200, 1), false, "auto", "convert_png_to_jpeg"},
+		{"PNG photo with --strategy lossless", ".png", 2048 * kb, photoImage(1600, 1200, 1), false, "lossless", "lossless_png"},
+		{"TIFF photo with --strategy quantize", ".tiff", 2048 * kb, photoImage(1600, 1200, 1), true, "quantize", "quantize_png"},
+		{"smooth gradient", ".png", 600 * kb, gradientImage(1600, 1200), false, "auto", "convert_png_to_jpeg"},
+		{"posterized gradient", ".png", 600 * kb, posterize(gradientImage(1600, 1200), 8), false, "auto", "convert_png_to_jpeg"},
+		{"small icon", ".png", 8 * kb, iconImage(128), false, "auto", "keep_original"},
+		{"BMP icon", ".bmp", 192 * kb, iconImage(256), true, "auto", "quantize_or_jpeg"},
+		{"small WebP icon", ".webp", 8 * kb, iconImage(128), true, "auto", "quantize_or_jpeg"},
+		{"small WebP photo", ".webp", 40 * kb, photoImage(400, 300, 2), true, "auto", "convert_png_to_jpeg"},
+		{"AVIF", ".avif", 40 * kb, photoImage(400, 300, 2), true, "auto", "convert_avif_to_jpeg"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			bounds := tc.img.Bounds()
+			got := determineCompressionStrategy(imageAnalysis{
+				ext:          tc.ext,
+				originalSize: tc.size,
+				totalPixels:  bounds.Dx() * bounds.Dy(),
+				lineArt:      analyzeLineArt(tc.img),
+				reencode:     tc.reencode,
+				strategy:     tc.strategy,
+				paletteSize:  256,
+			})
+			if got != tc.want {
+				t.Errorf("got %s, want %s (%+v)", got, tc.want, analyzeLineArt(tc.img))
+			}
+		})
+	}
+}
+
+// TestFlattenImageMatchesPerPixelBlend compares the draw-based flattening with blending each pixel
+// through At, the way it was done before
+func TestFlattenImageMatchesPerPixelBlend(t *testing.T) {
+	background := color.RGBA{30, 30, 30, 255}
+	for name, img := range map[string]image.Image{
+		"opaque":      photoImage(64, 48, 1),
+		"translucent": translucentImage(256, 32),
+	} {
+		flat := flattenImage(img, background)
+		bounds := img.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, g, b, a := img.At(x, y).RGBA()
+				blend := func(c uint32, bg uint8) uint32 { return (c + uint32(bg)*0x101*(0xffff-a)/0xffff) >> 8 }
+				want := [3]uint32{blend(r, background.R), blend(g, background.G), blend(b, background.B)}
+				got := flat.RGBAAt(x, y)
+				if absDiff(uint32(got.R), want[0]) > 1 || absDiff(uint32(got.G), want[1]) > 1 || absDiff(uint32(got.B), want[2]) > 1 || got.A != 255 {
+					t.Fatalf("%s: pixel %d,%d is %v, want %v", name, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+// TestScaleImageToWidthMatchesAt compares the buffer sampling of scaleImageToWidth with reading
+// every source pixel through At for the image types decoders return
+func TestScaleImageToWidthMatchesAt(t *testing.T) {
+	photo := photoImage(300, 200, 1)
+	decoded, err := jpeg.Decode(bytes.NewReader(encodeJPEG(t, photo, 90)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gray := image.NewGray(photo.Bounds())
+	draw.Draw(gray, gray.Bounds(), photo, image.Point{}, draw.Src)
+	paletted := image.NewPaletted(photo.Bounds(), palette.WebSafe)
+	draw.Draw(paletted, paletted.Bounds(), photo, image.Point{}, draw.Src)
+
+	for name, img := range map[string]image.Image{
+		"RGBA":        photo,
+		"NRGBA":       translucentImage(300, 200),
+		"YCbCr":       decoded,
+		"Gray":        gray,
+		"Paletted":    paletted,
+		"offset RGBA": photo.SubImage(image.Rect(50, 20, 300, 200)),
+	} {
+		scaled := scaleImageToWidth(img, 120).(*image.RGBA)
+		bounds := img.Bounds()
+		scale := 120 / float64(bounds.Dx())
+		for y := 0; y < scaled.Bounds().Dy(); y++ {
+			for x := 0; x < 120; x++ {
+				srcX, srcY := min(int(float64(x)/scale), bounds.Dx()-1), min(int(float64(y)/scale), bounds.Dy()-1)
+				want := color.RGBAModel.Convert(img.At(bounds.Min.X+srcX, bounds.Min.Y+srcY)).(color.RGBA)
+				got := scaled.RGBAAt(x, y)
+				if absDiff(uint32(got.R), uint32(want.R)) > 1 || absDiff(uint32(got.G), uint32(want.G)) > 1 ||
+					absDiff(uint32(got.B), uint32(want.B)) > 1 || got.A != want.A {
+					t.Fatalf("%s: pixel %d,%d is %v, want %v", name, x, y, got, want)
+				}
+			}
+		}
+	}
+
+	if small := gradientImage(100, 50); scaleImageToWidth(small, 120) != image.Image(small) {
+		t.Error("an image narrower than the target should be returned as is")
+	}
+}
+
+// BenchmarkFlatten12MP flattens a translucent 12 megapixel PNG-sized image, the case the
+// draw-based compositing was written for
+func BenchmarkFlatten12MP(b *testing.B) {
+	img := translucentImage(4000, 3000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		flattenImage(img, colorWhite)
+	}
+}
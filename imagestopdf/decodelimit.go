@@ -0,0 +1,114 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"sync"
+	"time"
+)
+
+// decodeLimitError reports an image skipped because it exceeds --max-decode-pixels or --decode-timeout
+type decodeLimitError struct {
+	reason  string
+	timeout bool // the decode is still running in the background
+}
+
+func (e *decodeLimitError) Error() string {
+	return "exceeds decode limit: " + e.reason
+}
+
+// megapixels formats a pixel count for messages about the decode limit
+func megapixels(pixels int64) string {
+	return fmt.Sprintf("%.1f MP", float64(pixels)/1e6)
+}
+
+// exifThumbnail returns the JPEG thumbnail stored in the second IFD of a JPEG's EXIF data, or nil.
+// Cameras and most stitching tools embed one of about 160x120 pixels.
+func exifThumbnail(data []byte) []byte {
+	tiff, order, entries := exifIFD0(data)
+	if len(entries) == 0 {
+		return nil
+	}
+
+	// The offset of the next IFD follows the last entry of the first
+	ifd0 := int(order.Uint32(tiff[4:]))
+	next := ifd0 + 2 + int(order.Uint16(tiff[ifd0:]))*12
+	if next+4 > len(tiff) {
+		return nil
+	}
+	ifd1 := int(order.Uint32(tiff[next:]))
+	if ifd1 < 8 || ifd1+2 > len(tiff) {
+		return nil
+	}
+
+	var offset, length int
+	for i := 0; i < int(order.Uint16(tiff[ifd1:])); i++ {
+		entry := ifd1 + 2 + i*12
+		if entry+12 > len(tiff) {
+			return nil
+		}
+		switch order.Uint16(tiff[entry:]) {
+		case 0x0201: // JPEGInterchangeFormat
+			offset = int(order.Uint32(tiff[entry+8:]))
+		case 0x0202: // JPEGInterchangeFormatLength
+			length = int(order.Uint32(tiff[entry+8:]))
+		}
+	}
+	if offset <= 0 || length <= 0 || offset+length > len(tiff) {
+		return nil
+	}
+	thumbnail := tiff[offset : offset+length]
+	if _, format, err := image.DecodeConfig(bytes.NewReader(thumbnail)); err != nil || format != "jpeg" {
+		return nil
+	}
+	return thumbnail
+}
+
+// decodeWithTimeout decodes the image, giving up after timeout with a decodeLimitError. Go can't
+// interrupt a decode, so an abandoned one keeps running in the background and calls abandoned once
+// it finishes, the caller keeps its memory reserved until then. A zero timeout waits indefinitely.
+func decodeWithTimeout(data []byte, timeout time.Duration, abandoned func()) (image.Image, error) {
+	if timeout <= 0 {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		return img, err
+	}
+
+	type result struct {
+		img image.Image
+		err error
+	}
+	done := make(chan result, 1)
+	var mu sync.Mutex
+	finished, gaveUp := false, false
+	go func() {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		mu.Lock()
+		if gaveUp {
+			mu.Unlock()
+			abandoned()
+			return
+		}
+		finished = true
+		mu.Unlock()
+		done <- result{img, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.img, r.err
+	case <-timer.C:
+	}
+
+	// The decode may have finished right as the timer fired
+	mu.Lock()
+	defer mu.Unlock()
+	if finished {
+		r := <-done
+		return r.img, r.err
+	}
+	gaveUp = true
+	return nil, &decodeLimitError{reason: fmt.Sprintf("decoding took longer than %s", timeout), timeout: true}
+}
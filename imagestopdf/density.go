@@ -0,0 +1,194 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"path/filepath"
+)
+
+const (
+	// minSourceDPI and maxSourceDPI bound the densities taken from files, others are treated as missing.
+	// Some writers store 1x1 as a placeholder.
+	minSourceDPI = 20
+	maxSourceDPI = 10000
+	// jfifHeader starts the APP0 segment of JFIF files
+	jfifHeader = "JFIF\x00"
+)
+
+// sourceDensity returns the pixel density a JPEG or PNG file declares, in dots per inch, or 0
+// when it declares none: the JFIF density or EXIF XResolution of a JPEG, the pHYs chunk of a PNG.
+// Only the horizontal density is read, scanners write the same value for both axes.
+func sourceDensity(data []byte) float64 {
+	var dpi float64
+	switch {
+	case len(data) > 2 && data[0] == 0xFF && data[1] == 0xD8:
+		dpi = jpegDensity(data)
+	case bytes.HasPrefix(data, pngSignature):
+		dpi = pngDensity(data)
+	}
+	if dpi < minSourceDPI || dpi > maxSourceDPI {
+		return 0
+	}
+	return dpi
+}
+
+// jpegDensity reads the JFIF density, or EXIF XResolution when the JFIF header has only an aspect ratio
+func jpegDensity(data []byte) float64 {
+	for _, seg := range jpegSegments(data) {
+		if seg.marker != 0xE0 || !bytes.HasPrefix(seg.payload, []byte(jfifHeader)) || len(seg.payload) < 12 {
+			continue
+		}
+		density := float64(binary.BigEndian.Uint16(seg.payload[8:]))
+		switch seg.payload[7] {
+		case 1: // dots per inch
+			return density
+		case 2: // dots per centimeter
+			return density * 2.54
+		}
+	}
+
+	tiff, order, entries := exifIFD0(data)
+	var resolution float64
+	unit := uint16(2) // inches unless ResolutionUnit says otherwise
+	for _, entry := range entries {
+		switch order.Uint16(entry) {
+		case 0x011A: // XResolution, a rational stored at an offset
+			offset := int(order.Uint32(entry[8:]))
+			if offset < 0 || offset+8 > len(tiff) {
+				return 0
+			}
+			if denominator := order.Uint32(tiff[offset+4:]); denominator != 0 {
+				resolution = float64(order.Uint32(tiff[offset:])) / float64(denominator)
+			}
+		case 0x0128: // ResolutionUnit
+			unit = order.Uint16(entry[8:])
+		}
+	}
+	switch unit {
+	case 2:
+		return resolution
+	case 3:
+		return resolution * 2.54
+	}
+	return 0
+}
+
+// pngDensity reads the pHYs chunk, whose pixels per meter are only physical when its unit is 1
+func pngDensity(data []byte) float64 {
+	pos := len(pngSignature)
+	for pos+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		chunkType := string(data[pos+4 : pos+8])
+		start := pos + 8
+		end := start + length
+		if length < 0 || end+4 > len(data) {
+			return 0
+		}
+
+		switch chunkType {
+		case "pHYs":
+			if length < 9 || data[start+8] != 1 {
+				return 0
+			}
+			return float64(binary.BigEndian.Uint32(data[start:])) * 0.0254
+		case "IDAT", "IEND":
+			return 0 // pHYs must come before the image data
+		}
+		pos = end + 4 // skip CRC
+	}
+	return 0
+}
+
+// physicalSize returns the size an image is printed at from its declared density, falling back to
+// --dpi, in the document's units: points for the auto page size, millimeters on named paper sizes.
+// The width before downscaling is used, so optimizing the image doesn't change its physical size.
+func physicalSize(img optimizedImage, opts Options) (float64, float64) {
+	dpi := img.sourceDPI
+	if dpi == 0 {
+		dpi = opts.DPI
+	}
+	unitsPerInch := 72.0
+	if opts.PageSize != "auto" {
+		unitsPerInch = 25.4
+	}
+	width := float64(img.sourceWidth) / dpi * unitsPerInch
+	return width, width * float64(img.height) / float64(img.width)
+}
+
+// physicalPageBasis picks the page size basis from the images' physical sizes for --use-source-dpi,
+// as pixel sizes at --dpi so the page size math stays the same
+func physicalPageBasis(images []optimizedImage, basis string, opts Options) (float64, float64, error) {
+	var widths, heights []float64
+	for _, img := range images {
+		if img.path == "" {
+			continue
+		}
+		dpi := img.sourceDPI
+		if dpi == 0 {
+			dpi = opts.DPI
+		}
+		width := float64(img.sourceWidth) * opts.DPI / dpi
+		widths = append(widths, width)
+		heights = append(heights, width*float64(img.height)/float64(img.width))
+	}
+	return pageBasis(opts.status(), widths, heights, basis, fmt.Sprintf("pixels at %g DPI", opts.DPI))
+}
+
+// physicalPercent is the share of its cell an image takes when placed at its physical size, at
+// most 100: maroto scales images to fit the cell and then applies this percentage
+func physicalPercent(img optimizedImage, cellWidth, cellHeight float64, opts Options) float64 {
+	width, _ := physicalSize(img, opts)
+	fitWidth := cellWidth
+	if ratio := float64(img.height) / float64(img.width); ratio > cellHeight/cellWidth {
+		fitWidth = cellHeight / ratio
+	}
+	return min(100, 100*width/fitWidth)
+}
+
+// reportMixedDPI warns about images whose declared density differs from the most common one.
+// Without --use-source-dpi every image fills its page whatever its density, so a 150 DPI scan of
+// a receipt comes out as large as a 300 DPI scan of a letter.
+func reportMixedDPI(images []optimizedImage, opts Options) {
+	counts := map[int]int{}
+	undeclared := 0
+	for _, img := range images {
+		if img.path == "" {
+			continue
+		}
+		if img.sourceDPI == 0 {
+			undeclared++
+			continue
+		}
+		counts[int(math.Round(img.sourceDPI))]++
+	}
+
+	if opts.UseSourceDPI {
+		if undeclared > 0 {
+			fmt.Fprintf(opts.status(), "%d image(s) declare no DPI, sized at --dpi %g\n", undeclared, opts.DPI)
+		}
+		return
+	}
+	if len(counts) < 2 {
+		return
+	}
+
+	majority := 0
+	for dpi, count := range counts {
+		if count > counts[majority] || count == counts[majority] && dpi < majority {
+			majority = dpi
+		}
+	}
+	var differing []string
+	for _, img := range images {
+		if dpi := int(math.Round(img.sourceDPI)); img.path != "" && dpi != 0 && dpi != majority {
+			differing = append(differing, fmt.Sprintf("%s: %d DPI", filepath.Base(img.sourcePath), dpi))
+		}
+	}
+	fmt.Fprintf(opts.status(), "⚠️  Warning: %d image(s) declare a different DPI than most images (%d DPI), their physical size is not kept:\n", len(differing), majority)
+	for _, line := range differing {
+		fmt.Fprintf(opts.status(), "  • %s\n", line)
+	}
+	fmt.Fprintf(opts.status(), "Use --use-source-dpi to size each page from its own DPI\n")
+}
@@ -0,0 +1,205 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	v2 "github.com/johnfercher/maroto/v2"
+	"github.com/johnfercher/maroto/v2/pkg/config"
+	"github.com/johnfercher/maroto/v2/pkg/core"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// documentDate is the creation and modification date written into --deterministic output:
+// --date when given, else SOURCE_DATE_EPOCH as set by reproducible build tooling, else the Unix epoch
+func (o Options) documentDate() (time.Time, error) {
+	if o.Date != "" {
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if date, err := time.Parse(layout, o.Date); err == nil {
+				return date.UTC(), nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid date %q, use RFC 3339 (2024-05-01T12:00:00Z) or YYYY-MM-DD", o.Date)
+	}
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q, must be seconds since the Unix epoch", epoch)
+		}
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	return time.Unix(0, 0).UTC(), nil
+}
+
+// now is the time stamped on the run's outputs, fixed with --deterministic
+func (o Options) now() time.Time {
+	if o.Deterministic {
+		if date, err := o.documentDate(); err == nil {
+			return date
+		}
+	}
+	return time.Now()
+}
+
+// newDocument creates the maroto document. With --deterministic the creation date is fixed, the
+// rest of the output is made reproducible afterwards by normalizeDocument.
+func newDocument(builder config.Builder, opts Options) (core.Maroto, error) {
+	builder = builder.WithSequentialLowMemoryMode(8) // More aggressive memory optimization
+	if opts.Deterministic {
+		date, err := opts.documentDate()
+		if err != nil {
+			return nil, err
+		}
+		builder = builder.WithCreationDate(date)
+	}
+	return v2.New(builder.Build()), nil
+}
+
+// normalizeDocument rewrites a PDF so identical content gives identical bytes. gofpdf numbers
+// images and fonts in map order, and pdfcpu, which merges the low-memory chunks and adds the
+// bookmarks, stamps the current time into the info dictionary and file ID. The document is read
+// into pdfcpu's model and written back with the objects numbered in the order they are reached
+// from the catalog, the dates set to date and a file ID hashed from the content.
+func normalizeDocument(data []byte, date time.Time) ([]byte, error) {
+	ctx, err := api.ReadContext(bytes.NewReader(data), model.NewDefaultConfiguration())
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Encrypt != nil {
+		return nil, errors.New("encrypted documents can't be rewritten")
+	}
+
+	// Objects get their new numbers depth first, dictionary entries in the order of their keys
+	renumbered := map[int]int{}
+	var order []types.Object
+	var visit func(o types.Object) error
+	visit = func(o types.Object) error {
+		switch o := o.(type) {
+		case types.IndirectRef:
+			if _, ok := renumbered[o.ObjectNumber.Value()]; ok {
+				return nil
+			}
+			target, err := ctx.Dereference(o)
+			if err != nil {
+				return fmt.Errorf("object %d: %v", o.ObjectNumber.Value(), err)
+			}
+			renumbered[o.ObjectNumber.Value()] = len(order) + 1
+			order = append(order, target)
+			return visit(target)
+		case types.Dict:
+			return visitDict(o, visit)
+		case types.StreamDict:
+			// The length is written directly, an indirect one is left behind
+			return visitDict(o.Dict, func(v types.Object) error {
+				if ir, ok := v.(types.IndirectRef); ok && o.StreamLengthObjNr != nil && ir.ObjectNumber.Value() == *o.StreamLengthObjNr {
+					return nil
+				}
+				return visit(v)
+			})
+		case types.Array:
+			for _, v := range o {
+				if err := visit(v); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if ctx.Root == nil {
+		return nil, errors.New("no document catalog")
+	}
+	if err := visit(*ctx.Root); err != nil {
+		return nil, err
+	}
+	if ctx.Info != nil {
+		info, err := ctx.DereferenceDict(*ctx.Info)
+		if err != nil {
+			return nil, fmt.Errorf("info dictionary: %v", err)
+		}
+		stamp := types.StringLiteral(types.DateString(date))
+		info.Update("CreationDate", stamp)
+		info.Update("ModDate", stamp)
+		if err := visit(*ctx.Info); err != nil {
+			return nil, err
+		}
+	}
+
+	var remap func(o types.Object) types.Object
+	remap = func(o types.Object) types.Object {
+		switch o := o.(type) {
+		case types.IndirectRef:
+			return *types.NewIndirectRef(renumbered[o.ObjectNumber.Value()], 0)
+		case types.Dict:
+			d := types.NewDict()
+			for k, v := range o {
+				d[k] = remap(v)
+			}
+			return d
+		case types.Array:
+			a := make(types.Array, len(o))
+			for i, v := range o {
+				a[i] = remap(v)
+			}
+			return a
+		}
+		return o
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-" + ctx.Version().String() + "\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(order))
+	for i, o := range order {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n", i+1)
+		if sd, ok := o.(types.StreamDict); ok {
+			d := remap(sd.Dict).(types.Dict)
+			d.Update("Length", types.Integer(len(sd.Raw)))
+			out.WriteString(d.PDFString())
+			out.WriteString("\nstream\n")
+			out.Write(sd.Raw)
+			out.WriteString("\nendstream")
+		} else if o == nil {
+			out.WriteString("null")
+		} else {
+			out.WriteString(remap(o).PDFString())
+		}
+		out.WriteString("\nendobj\n")
+	}
+
+	id := types.HexLiteral(fmt.Sprintf("%x", md5.Sum(out.Bytes())))
+	trailer := types.Dict{
+		"Size": types.Integer(len(order) + 1),
+		"Root": remap(*ctx.Root),
+		"ID":   types.Array{id, id},
+	}
+	if ctx.Info != nil {
+		trailer["Info"] = remap(*ctx.Info)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(order)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n%s\nstartxref\n%d\n%%%%EOF\n", trailer.PDFString(), xref)
+	return out.Bytes(), nil
+}
+
+// visitDict calls visit for the entries of d in the order of their keys
+func visitDict(d types.Dict, visit func(types.Object) error) error {
+	for _, k := range slices.Sorted(maps.Keys(d)) {
+		if err := visit(d[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
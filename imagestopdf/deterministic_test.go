@@ -0,0 +1,79 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// deterministicFixtures writes images taking the copy, re-encode and PNG paths, one in a subdirectory
+func deterministicFixtures(t *testing.T) string {
+	dir := t.TempDir()
+	writeJPEG(t, filepath.Join(dir, "photo.jpg"), photoImage(1600, 1200, 1), 95)
+	writeJPEG(t, filepath.Join(dir, "small.jpg"), photoImage(300, 200, 2), 80)
+	writePNG(t, filepath.Join(dir, "translucent.png"), translucentImage(256, 128))
+	writePNG(t, filepath.Join(dir, "chapter2", "gradient.png"), gradientImage(640, 480))
+	return dir
+}
+
+// runToFile converts like the command does and returns the PDF written
+func runToFile(t *testing.T, opts Options) []byte {
+	t.Helper()
+	opts.OutputDir = t.TempDir()
+	if err := convertImagesToPDF(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(opts.OutputDir, opts.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// TestDeterministicRunsAreByteIdentical runs the full conversion twice over the same images
+func TestDeterministicRunsAreByteIdentical(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	opts := defaultOptions()
+	opts.Inputs = []string{deterministicFixtures(t)}
+	opts.Deterministic = true
+	opts.Sections = true
+	opts.Date = "2024-05-01"
+
+	first := runToFile(t, opts)
+	second := runToFile(t, opts)
+	if !bytes.Equal(first, second) {
+		t.Fatalf("two --deterministic runs differ (%d and %d bytes)", len(first), len(second))
+	}
+	if !bytes.Contains(first, []byte("D:20240501")) {
+		t.Error("the PDF doesn't carry the --date")
+	}
+	if bookmarks, err := api.Bookmarks(bytes.NewReader(first), nil); err != nil || len(bookmarks) != 2 {
+		t.Errorf("got %d section bookmark(s), %v; want one per directory", len(bookmarks), err)
+	}
+
+	opts.Date = "2024-05-02"
+	if bytes.Equal(first, runToFile(t, opts)) {
+		t.Error("a different --date gave the same PDF")
+	}
+}
+
+func TestDocumentDate(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	if date, err := (Options{}).documentDate(); err != nil || date.Unix() != 1700000000 {
+		t.Errorf("SOURCE_DATE_EPOCH: got %v, %v", date, err)
+	}
+	if date, err := (Options{Date: "2024-05-01T12:00:00+02:00"}).documentDate(); err != nil || date.Hour() != 10 {
+		t.Errorf("--date wins over SOURCE_DATE_EPOCH and is in UTC: got %v, %v", date, err)
+	}
+	if _, err := (Options{Date: "May 1st"}).documentDate(); err == nil {
+		t.Error("an unparsable --date should fail")
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	if date, _ := (Options{}).documentDate(); date.Unix() != 0 {
+		t.Errorf("without a date: got %v, want the epoch", date)
+	}
+}
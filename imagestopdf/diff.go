@@ -0,0 +1,257 @@
+package imagestopdf
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// validateDiff rejects --diff and --skip-unchanged where there is no single PDF and manifest to compare with
+func validateDiff(o Options) error {
+	if !o.Diff && !o.SkipUnchanged {
+		return nil
+	}
+	flag := "--diff"
+	if !o.Diff {
+		flag = "--skip-unchanged"
+	}
+	switch {
+	case o.BatchSize > 0:
+		return fmt.Errorf("%s compares with a single PDF's manifest, it can't be combined with --batch-size", flag)
+	case o.OnePerImage:
+		return fmt.Errorf("%s compares with a single PDF's manifest, it can't be combined with --one-per-image", flag)
+	case o.StdinTar:
+		return fmt.Errorf("%s needs images that stay in place between runs, it can't be combined with --stdin-tar", flag)
+	case o.OutputDir == "-":
+		return fmt.Errorf("%s needs the previous PDF on disk, it can't be combined with --output -", flag)
+	}
+	return nil
+}
+
+// sourceDiff is how the source images differ from those in the previous run's manifest
+type sourceDiff struct {
+	added, removed, modified []string
+	unchanged                int
+}
+
+// changed reports whether any source image was added, removed or modified
+func (d sourceDiff) changed() bool {
+	return len(d.added)+len(d.removed)+len(d.modified) > 0
+}
+
+// summary counts the changes for the end of the run
+func (d sourceDiff) summary() string {
+	return fmt.Sprintf("%d added, %d removed, %d modified, %d unchanged", len(d.added), len(d.removed), len(d.modified), d.unchanged)
+}
+
+// sourceKey identifies a source image across runs by its path relative to the input it was found
+// in, so the comparison doesn't depend on the directory the tool is run from
+func sourceKey(input, source string) string {
+	if input == "" {
+		return filepath.ToSlash(filepath.Clean(source))
+	}
+	rel, err := filepath.Rel(input, source)
+	if err != nil {
+		return filepath.ToSlash(filepath.Clean(source))
+	}
+	if rel == "." {
+		// The input was the image itself
+		return filepath.Base(source)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// previousManifestPath is where the last run left its manifest: the --manifest path, or the
+// default one next to the PDF. Output names with placeholders change between runs, those have none.
+func previousManifestPath(opts Options) (string, bool) {
+	if opts.ManifestPath != "" && opts.ManifestPath != defaultManifestPath {
+		return opts.ManifestPath, true
+	}
+	if strings.Contains(opts.Name, "{") {
+		return "", false
+	}
+	return filepath.Join(opts.OutputDir, opts.Name) + ".manifest.json", true
+}
+
+// readManifestSources returns the source hashes recorded in a JSON or CSV manifest by sourceKey,
+// and the PDF the manifest describes, empty for CSV manifests
+func readManifestSources(path string) (map[string]string, string, error) {
+	data, err := os.ReadFile(longPath(path))
+	if err != nil {
+		return nil, "", err
+	}
+
+	var pages []manifestPage
+	var output string
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		records, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+		if err != nil {
+			return nil, "", err
+		}
+		if len(records) == 0 {
+			return nil, "", fmt.Errorf("empty manifest")
+		}
+		columns := map[string]int{}
+		for i, name := range records[0] {
+			columns[name] = i
+		}
+		inputColumn, okInput := columns["input"]
+		sourceColumn, okSource := columns["source"]
+		hashColumn, okHash := columns["source_sha256"]
+		if !okInput || !okSource || !okHash {
+			return nil, "", fmt.Errorf("missing input, source or source_sha256 column")
+		}
+		for _, record := range records[1:] {
+			pages = append(pages, manifestPage{Input: record[inputColumn], Source: record[sourceColumn], SourceSHA256: record[hashColumn]})
+		}
+	} else {
+		var previous manifest
+		if err := json.Unmarshal(data, &previous); err != nil {
+			return nil, "", err
+		}
+		pages, output = previous.Pages, previous.Output
+	}
+
+	sources := map[string]string{}
+	for _, page := range pages {
+		if page.Source != "" {
+			sources[sourceKey(page.Input, page.Source)] = page.SourceSHA256
+		}
+	}
+	return sources, output, nil
+}
+
+// currentSources discovers the images of this run and hashes them like the manifest does
+func currentSources(ctx context.Context, opts Options) (map[string]string, error) {
+	retry := newRetrier(opts.Retries)
+	groups := [][]string{opts.Inputs}
+	if opts.InputDir2 != "" {
+		groups = append(groups, []string{opts.InputDir2})
+	}
+
+	sources := map[string]string{}
+	for _, inputs := range groups {
+		images, err := discoverImages(ctx, inputs, retry, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, imagePath := range images {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			sum, err := fileSHA256(ctx, imagePath, retry)
+			if err != nil {
+				return nil, fmt.Errorf("failed to hash %s: %v", imagePath, err)
+			}
+			sources[sourceKey(inputFor(imagePath, inputs), imagePath)] = sum
+		}
+	}
+	return sources, nil
+}
+
+// fileSHA256 returns the SHA-256 of a file in hex, read as a stream
+func fileSHA256(ctx context.Context, path string, retry *retrier) (string, error) {
+	var sum string
+	err := retry.do(ctx, path, func() error {
+		file, err := os.Open(longPath(path))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		hash := sha256.New()
+		if _, err := io.Copy(hash, file); err != nil {
+			return err
+		}
+		sum = fmt.Sprintf("%x", hash.Sum(nil))
+		return nil
+	})
+	return sum, err
+}
+
+// diffSources compares the current source hashes with the previous ones
+func diffSources(previous, current map[string]string) sourceDiff {
+	var d sourceDiff
+	for key, sum := range current {
+		previousSum, ok := previous[key]
+		switch {
+		case !ok:
+			d.added = append(d.added, key)
+		case previousSum != sum:
+			d.modified = append(d.modified, key)
+		default:
+			d.unchanged++
+		}
+	}
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			d.removed = append(d.removed, key)
+		}
+	}
+	for _, keys := range [][]string{d.added, d.removed, d.modified} {
+		sort.Slice(keys, func(i, j int) bool { return naturalComparePaths(keys[i], keys[j]) < 0 })
+	}
+	return d
+}
+
+// printDiff lists the changed source images in diff style, colored on terminals
+func printDiff(status io.Writer, d sourceDiff, manifestPath string) {
+	fmt.Fprintf(status, "Changes since the last run (%s): %s\n", manifestPath, d.summary())
+	for _, change := range []struct {
+		marker, color string
+		keys          []string
+	}{
+		{"+", ansiGreen, d.added},
+		{"-", ansiRed, d.removed},
+		{"~", ansiYellow, d.modified},
+	} {
+		for _, key := range change.keys {
+			fmt.Fprintln(status, colorize(status, change.color, change.marker+" "+key))
+		}
+	}
+}
+
+// checkChanges compares the images with the previous run's manifest for --diff and
+// --skip-unchanged. It returns the differences, or nil when there is nothing usable to compare
+// with, which is only noted. upToDate is true when --skip-unchanged finds nothing to rebuild.
+func checkChanges(ctx context.Context, opts Options) (d *sourceDiff, upToDate bool, err error) {
+	manifestPath, ok := previousManifestPath(opts)
+	if !ok {
+		fmt.Fprintf(opts.status(), "Note: the output name has placeholders, there is no previous manifest to compare with, converting everything\n")
+		return nil, false, nil
+	}
+	previous, previousOutput, err := readManifestSources(manifestPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Fprintf(opts.status(), "Note: no manifest from a previous run at %s, converting everything\n", manifestPath)
+		} else {
+			fmt.Fprintf(opts.status(), "Note: the manifest at %s can't be read (%v), converting everything\n", manifestPath, err)
+		}
+		return nil, false, nil
+	}
+
+	current, err := currentSources(ctx, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	changes := diffSources(previous, current)
+	printDiff(opts.status(), changes, manifestPath)
+
+	if !opts.SkipUnchanged || changes.changed() {
+		return &changes, false, nil
+	}
+	if previousOutput == "" {
+		previousOutput = filepath.Join(opts.OutputDir, opts.Name)
+	}
+	if _, err := os.Stat(longPath(previousOutput)); err != nil {
+		fmt.Fprintf(opts.status(), "Note: %s is missing, converting again\n", previousOutput)
+		return &changes, false, nil
+	}
+	return &changes, true, nil
+}
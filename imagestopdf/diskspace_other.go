@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package imagestopdf
+
+import "errors"
+
+// freeDiskSpace is not available on this platform
+func freeDiskSpace(path string) (int64, error) {
+	return 0, errors.New("free disk space is not available on this platform")
+}
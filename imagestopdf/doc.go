@@ -0,0 +1,9 @@
+// Package imagestopdf converts folders of images into PDF documents. It is the engine of the
+// images_to_pdf command and can be used on its own:
+//
+//	opts := imagestopdf.Options{Inputs: []string{"./scans"}, StripMetadata: true, ConvertSRGB: true}
+//	result, err := imagestopdf.Convert(ctx, w, opts)
+//
+// Convert writes the PDF to any io.Writer and reports its progress through Options.Progress.
+// Execute runs the command line itself.
+package imagestopdf
@@ -0,0 +1,47 @@
+package imagestopdf
+
+import "errors"
+
+// Failure classes callers can tell apart with errors.Is, the CLI maps each to its own exit code
+var (
+	ErrNoImages          = errors.New("no image files found in directory")
+	ErrInputNotFound     = errors.New("input does not exist")
+	ErrAllImagesFailed   = errors.New("none of the images could be converted")
+	ErrOutputExists      = errors.New("output file already exists")
+	ErrSaveFailed        = errors.New("failed to save PDF")
+	ErrInsufficientSpace = errors.New("not enough free disk space")
+	ErrChecksumMissing   = errors.New("no checksum file")
+	ErrChecksumMismatch  = errors.New("checksum mismatch")
+)
+
+// Exit codes of the CLI, 1 is used for anything not listed here
+const (
+	exitCodeNoImages        = 2
+	exitCodeInputNotFound   = 3
+	exitCodeAllImagesFailed = 4
+	exitCodeOutputExists    = 5
+	exitCodeSaveFailed      = 6
+	exitCodeNoSpace         = 7
+	exitCodeChecksum        = 8
+)
+
+// exitCode returns the process exit code for a failed run
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, ErrNoImages):
+		return exitCodeNoImages
+	case errors.Is(err, ErrInputNotFound):
+		return exitCodeInputNotFound
+	case errors.Is(err, ErrAllImagesFailed):
+		return exitCodeAllImagesFailed
+	case errors.Is(err, ErrOutputExists):
+		return exitCodeOutputExists
+	case errors.Is(err, ErrSaveFailed):
+		return exitCodeSaveFailed
+	case errors.Is(err, ErrInsufficientSpace):
+		return exitCodeNoSpace
+	case errors.Is(err, ErrChecksumMissing), errors.Is(err, ErrChecksumMismatch):
+		return exitCodeChecksum
+	}
+	return 1
+}
@@ -0,0 +1,126 @@
+package imagestopdf
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// commandEnv makes the test binary run the command with the arguments after "--" instead of the tests
+const commandEnv = "IMAGES_TO_PDF_RUN_COMMAND"
+
+// TestRunCommand is not a test of its own, runCommand starts it in a child process
+func TestRunCommand(t *testing.T) {
+	if os.Getenv(commandEnv) != "1" {
+		return
+	}
+	args := os.Args[slices.Index(os.Args, "--")+1:]
+	rootCmd.SetArgs(args)
+	Execute()
+	os.Exit(0)
+}
+
+// runCommand runs images-to-pdf with args in a child process and returns its exit code
+func runCommand(t *testing.T, args ...string) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], append([]string{"-test.run=^TestRunCommand$", "--"}, args...)...)
+	cmd.Env = append(os.Environ(), commandEnv+"=1")
+	output, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		t.Logf("output:\n%s", output)
+		return exitErr.ExitCode()
+	}
+	t.Fatalf("running the command: %v", err)
+	return -1
+}
+
+// TestExitCodes runs the command against prepared inputs and checks the exit code of each failure
+// class, and that a failed run leaves no PDF or partial file behind
+func TestExitCodes(t *testing.T) {
+	photo := encodeJPEG(t, photoImage(300, 200, 1), 85)
+
+	for _, tc := range []struct {
+		name    string
+		prepare func(t *testing.T, input, output string) []string // extra arguments
+		want    int
+		pdf     bool // a PDF is expected in the output directory afterwards
+	}{
+		{"success", func(t *testing.T, input, output string) []string {
+			writeFile(t, filepath.Join(input, "a.jpg"), photo)
+			return nil
+		}, 0, true},
+		{"no images", func(t *testing.T, input, output string) []string {
+			writeFile(t, filepath.Join(input, "notes.txt"), []byte("not an image"))
+			return nil
+		}, exitCodeNoImages, false},
+		{"input not found", func(t *testing.T, input, output string) []string {
+			return []string{"-i", filepath.Join(input, "missing")}
+		}, exitCodeInputNotFound, false},
+		{"all images failed", func(t *testing.T, input, output string) []string {
+			writeFile(t, filepath.Join(input, "a.jpg"), photo[:200])
+			writeFile(t, filepath.Join(input, "b.png"), []byte("\x89PNG\r\n\x1a\ncorrupt"))
+			return nil
+		}, exitCodeAllImagesFailed, false},
+		{"output exists", func(t *testing.T, input, output string) []string {
+			writeFile(t, filepath.Join(input, "a.jpg"), photo)
+			writeFile(t, filepath.Join(output, "images.pdf"), []byte("previous run"))
+			return []string{"--no-overwrite"}
+		}, exitCodeOutputExists, true},
+		{"save failed", func(t *testing.T, input, output string) []string {
+			writeFile(t, filepath.Join(input, "a.jpg"), photo)
+			// A directory in the way of the PDF makes the final rename fail
+			writeFile(t, filepath.Join(output, "images.pdf", "keep"), nil)
+			return nil
+		}, exitCodeSaveFailed, false},
+		{"invalid option", func(t *testing.T, input, output string) []string {
+			writeFile(t, filepath.Join(input, "a.jpg"), photo)
+			return []string{"--page-basis", "mode"}
+		}, 1, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			input, output := t.TempDir(), t.TempDir()
+			args := append([]string{"-i", input, "-o", output}, tc.prepare(t, input, output)...)
+
+			if got := runCommand(t, args...); got != tc.want {
+				t.Errorf("exit code %d, want %d", got, tc.want)
+			}
+
+			entries, err := os.ReadDir(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pdf := false
+			for _, entry := range entries {
+				switch name := entry.Name(); {
+				case filepath.Ext(name) == ".partial":
+					t.Errorf("partial output %s was left behind", name)
+				case name == "images.pdf" && !entry.IsDir():
+					pdf = true
+				}
+			}
+			if pdf != tc.pdf {
+				t.Errorf("PDF in the output directory: %v, want %v", pdf, tc.pdf)
+			}
+		})
+	}
+}
+
+func TestExitCodeWrapped(t *testing.T) {
+	for err, want := range map[error]int{
+		ErrNoImages:              exitCodeNoImages,
+		ErrChecksumMismatch:      exitCodeChecksum,
+		errors.New("unexpected"): 1,
+		errors.Join(ErrSaveFailed, os.ErrPermission): exitCodeSaveFailed,
+	} {
+		if got := exitCode(err); got != want {
+			t.Errorf("exitCode(%v) = %d, want %d", err, got, want)
+		}
+	}
+}
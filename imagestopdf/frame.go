@@ -0,0 +1,148 @@
+package imagestopdf
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/johnfercher/go-tree/node"
+	"github.com/johnfercher/maroto/v2/pkg/consts/linestyle"
+	"github.com/johnfercher/maroto/v2/pkg/consts/orientation"
+	"github.com/johnfercher/maroto/v2/pkg/core"
+	"github.com/johnfercher/maroto/v2/pkg/core/entity"
+	"github.com/johnfercher/maroto/v2/pkg/props"
+)
+
+// mmPerPoint converts typographic points to millimeters, the unit of the document
+const mmPerPoint = 25.4 / 72
+
+// length is a flag value holding a distance on the page in millimeters, written as e.g. "5mm", "12pt" or "0.5in"
+type length float64
+
+// lengthUnits maps length suffixes to millimeters, a bare number is in millimeters
+var lengthUnits = []struct {
+	suffix string
+	mm     float64
+}{
+	{"mm", 1}, {"cm", 10}, {"in", 25.4}, {"pt", mmPerPoint},
+}
+
+// parseLength parses a distance with an optional unit suffix into millimeters
+func parseLength(s string) (float64, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+	multiplier := 1.0
+	for _, unit := range lengthUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.mm
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid length %q, expected a number with an optional mm, cm, in or pt suffix", s)
+	}
+	return n * multiplier, nil
+}
+
+func (l *length) String() string {
+	return strconv.FormatFloat(math.Round(float64(*l)*100)/100, 'f', -1, 64) + "mm"
+}
+
+func (l *length) Set(s string) error {
+	mm, err := parseLength(s)
+	if err != nil {
+		return err
+	}
+	*l = length(mm)
+	return nil
+}
+
+func (l *length) Type() string {
+	return "length"
+}
+
+// imageFrame draws a border over the edges of an image placed centered in its cell,
+// the way pageCol places images. Maroto's cell borders would outline the whole cell instead, which
+// is wider or taller than the image whenever the aspect ratios differ.
+type imageFrame struct {
+	width, height int     // pixel size of the framed image, only its aspect ratio matters
+	percent       float64 // share of the cell the image fills, as in its props.Rect
+	thickness     float64
+	color         color.RGBA
+	config        *entity.Config
+}
+
+// newImageFrame returns the border component for an image of the given pixel size
+func newImageFrame(width, height int, percent, thickness float64, c color.RGBA) core.Component {
+	return &imageFrame{width: width, height: height, percent: percent, thickness: thickness, color: c}
+}
+
+// Render draws the four edges inside the image area, so a frame on a full-bleed page is never cut off
+func (f *imageFrame) Render(provider core.Provider, cell *entity.Cell) {
+	if f.width <= 0 || f.height <= 0 {
+		return
+	}
+	image := placedImage(cell, f.width, f.height, f.percent)
+	inset := f.thickness / 2
+	drawRectangle(provider, &entity.Cell{
+		X:      image.X + inset,
+		Y:      image.Y + inset,
+		Width:  image.Width - f.thickness,
+		Height: image.Height - f.thickness,
+	}, f.thickness, pdfColor(f.color))
+}
+
+// placedImage returns the area an image of the given pixel size takes when maroto centers it in
+// the cell and scales it to percent of the cell
+func placedImage(cell *entity.Cell, width, height int, percent float64) *entity.Cell {
+	scale := min(cell.Width/float64(width), cell.Height/float64(height)) * percent / 100
+	w, h := float64(width)*scale, float64(height)*scale
+	return &entity.Cell{X: cell.X + (cell.Width-w)/2, Y: cell.Y + (cell.Height-h)/2, Width: w, Height: h}
+}
+
+// drawRectangle outlines area with solid lines centered on its edges
+func drawRectangle(provider core.Provider, area *entity.Cell, thickness float64, c *props.Color) {
+	// The corners are squared off by extending each horizontal edge over the vertical ones
+	outer := &entity.Cell{X: area.X - thickness/2, Y: area.Y, Width: area.Width + thickness, Height: area.Height}
+	edges := []struct {
+		cell *entity.Cell
+		prop props.Line
+	}{
+		{outer, props.Line{Orientation: orientation.Horizontal, OffsetPercent: 0}},
+		{outer, props.Line{Orientation: orientation.Horizontal, OffsetPercent: 100}},
+		{area, props.Line{Orientation: orientation.Vertical, OffsetPercent: 0}},
+		{area, props.Line{Orientation: orientation.Vertical, OffsetPercent: 100}},
+	}
+	for _, edge := range edges {
+		edge.prop.Style = linestyle.Solid
+		edge.prop.Thickness = thickness
+		edge.prop.Color = c
+		edge.prop.SizePercent = 100
+		provider.AddLine(edge.cell, &edge.prop)
+	}
+}
+
+// GetHeight is the height of the frame, it always takes the height of its cell
+func (f *imageFrame) GetHeight(provider core.Provider, cell *entity.Cell) float64 {
+	return cell.Height
+}
+
+// SetConfig keeps the document configuration, the frame doesn't depend on it
+func (f *imageFrame) SetConfig(config *entity.Config) {
+	f.config = config
+}
+
+// GetStructure describes the frame for maroto's document structure
+func (f *imageFrame) GetStructure() *node.Node[core.Structure] {
+	return node.New(core.Structure{
+		Type: "image_frame",
+		Details: map[string]interface{}{
+			"thickness": f.thickness,
+			"color":     formatColor(f.color),
+		},
+	})
+}
@@ -0,0 +1,396 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Fixtures are generated by the tests so the repository carries no binary images
+
+// gradientImage returns an opaque image with a smooth color ramp across both axes
+func gradientImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, color.RGBA{
+				R: uint8(255 * x / max(width-1, 1)),
+				G: uint8(255 * y / max(height-1, 1)),
+				B: uint8(255 - 255*x/max(width-1, 1)),
+				A: 255,
+			})
+		}
+	}
+	return img
+}
+
+// photoImage returns a gradient with grain on top, which like a photograph neither compresses
+// well nor looks like line art
+func photoImage(width, height int, seed int64) *image.RGBA {
+	img := gradientImage(width, height)
+	rng := rand.New(rand.NewSource(seed))
+	for i := 0; i < len(img.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			img.Pix[i+c] = uint8(max(0, min(255, int(img.Pix[i+c])+rng.Intn(41)-20)))
+		}
+	}
+	return img
+}
+
+// solidImage returns an image filled with c
+func solidImage(width, height int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+// screenshotImage returns a window with a title bar and lines of text-like glyph runs. With
+// gradientBar the title bar is a color ramp, as antialiasing and shadows add thousands of colors.
+func screenshotImage(width, height int, gradientBar bool) *image.RGBA {
+	img := solidImage(width, height, colorWhite)
+	for y := 0; y < 40 && y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{40, 90, 200, 255}
+			if gradientBar {
+				c = color.RGBA{uint8(x), uint8(x >> 8), uint8(200 - y), 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	for line := 0; 70+line*24+12 < height; line++ {
+		top := 70 + line*24
+		for x := 20; x < width-20; x++ {
+			// Glyphs 2 to 6 pixels wide, a space every few of them
+			if x%7 < 2+line%5 && (x/35+line)%6 != 0 {
+				for y := top; y < top+12; y++ {
+					img.SetRGBA(x, y, color.RGBA{30, 30, 30, 255})
+				}
+			}
+		}
+	}
+	return img
+}
+
+// iconImage returns a few flat colors with hard edges: a framed disc on a light background
+func iconImage(size int) *image.RGBA {
+	img := solidImage(size, size, color.RGBA{240, 240, 240, 255})
+	center, radius := size/2, size*3/8
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			switch dx, dy := x-center, y-center; {
+			case x < size/16 || y < size/16 || x >= size-size/16 || y >= size-size/16:
+				img.SetRGBA(x, y, color.RGBA{20, 20, 80, 255})
+			case dx*dx+dy*dy <= radius*radius:
+				img.SetRGBA(x, y, color.RGBA{220, 40, 40, 255})
+			}
+		}
+	}
+	return img
+}
+
+// translucentImage returns an NRGBA gradient whose alpha runs from transparent to opaque
+func translucentImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: uint8(255 * x / max(width-1, 1))})
+		}
+	}
+	return img
+}
+
+// encodeJPEG returns img as a JPEG file
+func encodeJPEG(t testing.TB, img image.Image, quality int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeFile writes a fixture, creating its directory
+func writeFile(t testing.TB, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeJPEG writes img as a JPEG fixture
+func writeJPEG(t testing.TB, path string, img image.Image, quality int) {
+	t.Helper()
+	writeFile(t, path, encodeJPEG(t, img, quality))
+}
+
+// writePNG writes img as a PNG fixture
+func writePNG(t testing.TB, path string, img image.Image) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, buf.Bytes())
+}
+
+// withAPPSegment inserts a segment with the given marker and payload right after the SOI marker
+func withAPPSegment(jpegData []byte, marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	out := append([]byte{}, jpegData[:2]...)
+	out = append(out, segment...)
+	out = append(out, payload...)
+	return append(out, jpegData[2:]...)
+}
+
+// exifEntry is a tag of a little-endian EXIF IFD whose value fits the entry
+type exifEntry struct {
+	tag, kind uint16
+	count     uint32
+	value     uint32
+}
+
+// exifPayload builds an APP1 Exif payload with IFD0 holding entries, and a GPS IFD with a
+// latitude reference when gps is set
+func exifPayload(gps bool, entries ...exifEntry) []byte {
+	const ifd0 = 8
+	if gps {
+		gpsOffset := uint32(ifd0 + 2 + 12*(len(entries)+1) + 4)
+		entries = append(entries, exifEntry{tag: 0x8825, kind: 4, count: 1, value: gpsOffset})
+	}
+	le := binary.LittleEndian
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = le.AppendUint16(tiff, uint16(len(entries)))
+	for _, e := range entries {
+		tiff = le.AppendUint16(tiff, e.tag)
+		tiff = le.AppendUint16(tiff, e.kind)
+		tiff = le.AppendUint32(tiff, e.count)
+		tiff = le.AppendUint32(tiff, e.value)
+	}
+	tiff = le.AppendUint32(tiff, 0)
+	if gps {
+		tiff = le.AppendUint16(tiff, 1)
+		tiff = append(tiff, 0x01, 0x00, 0x02, 0x00, 0x02, 0x00, 0x00, 0x00, 'N', 0, 0, 0) // GPSLatitudeRef "N"
+		tiff = le.AppendUint32(tiff, 0)
+	}
+	return append([]byte(exifHeader), tiff...)
+}
+
+// convertForTest runs Convert over opts and returns the PDF
+func convertForTest(t testing.TB, opts Options) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := Convert(context.Background(), &buf, opts); err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// pdfImages returns the images embedded in each page of a PDF, in page order
+func pdfImages(t testing.TB, data []byte) [][]model.Image {
+	t.Helper()
+	ctx, _, _, _, err := api.ReadValidateAndOptimize(bytes.NewReader(data), model.NewDefaultConfiguration(), time.Now())
+	if err != nil {
+		t.Fatalf("reading PDF: %v", err)
+	}
+	// api.ExtractImagesRaw walks the pages in map order, extract them one by one instead
+	images := make([][]model.Image, ctx.PageCount)
+	for i := range images {
+		page, err := pdfcpu.ExtractPageImages(ctx, i+1, false)
+		if err != nil {
+			t.Fatalf("extracting images of page %d: %v", i+1, err)
+		}
+		for _, img := range page {
+			images[i] = append(images[i], img)
+		}
+	}
+	return images
+}
+
+// readAll returns the bytes of an extracted image stream
+func readAll(t testing.TB, img model.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// pdfPageContents returns the decoded content stream of each page of a PDF
+func pdfPageContents(t testing.TB, data []byte) []string {
+	t.Helper()
+	ctx, err := api.ReadContext(bytes.NewReader(data), model.NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("reading PDF: %v", err)
+	}
+	if err := ctx.EnsurePageCount(); err != nil {
+		t.Fatalf("reading PDF: %v", err)
+	}
+	contents := make([]string, ctx.PageCount)
+	for i := range contents {
+		d, _, _, err := ctx.PageDict(i+1, false)
+		if err != nil {
+			t.Fatalf("page %d: %v", i+1, err)
+		}
+		content, err := ctx.PageContent(d)
+		if err != nil {
+			t.Fatalf("page %d: %v", i+1, err)
+		}
+		contents[i] = string(content)
+	}
+	return contents
+}
+
+// pdfRect is a rectangle on a PDF page in points, from the bottom left corner
+type pdfRect struct{ x, y, width, height float64 }
+
+// contains reports whether r covers inner, give or take a hundredth of a point of rounding
+func (r pdfRect) contains(inner pdfRect) bool {
+	const e = 0.01
+	return inner.x >= r.x-e && inner.y >= r.y-e &&
+		inner.x+inner.width <= r.x+r.width+e && inner.y+inner.height <= r.y+r.height+e
+}
+
+var (
+	imagePlacementOp = regexp.MustCompile(`q ([\d.]+) 0 0 ([\d.]+) ([\d.]+) ([\d.]+) cm /I\w+ Do Q`)
+	grayFillOp       = regexp.MustCompile(`([\d.]+) g\n([\d.]+) ([\d.]+) ([\d.]+) (-?[\d.]+) re f`)
+)
+
+// imagePlacements returns where a page content stream draws its images
+func imagePlacements(content string) []pdfRect {
+	var rects []pdfRect
+	for _, m := range imagePlacementOp.FindAllStringSubmatch(content, -1) {
+		v := parseFloats(m[1:])
+		rects = append(rects, pdfRect{v[2], v[3], v[0], v[1]})
+	}
+	return rects
+}
+
+// grayFills returns the rectangles a page content stream fills with the given gray level, 0 to 1.
+// gofpdf writes rectangles from their top left corner with a negative height.
+func grayFills(content string, level float64) []pdfRect {
+	var rects []pdfRect
+	for _, m := range grayFillOp.FindAllStringSubmatch(content, -1) {
+		v := parseFloats(m[1:])
+		if v[0] != level {
+			continue
+		}
+		r := pdfRect{v[1], v[2], v[3], v[4]}
+		if r.height < 0 {
+			r.y, r.height = r.y+r.height, -r.height
+		}
+		rects = append(rects, r)
+	}
+	return rects
+}
+
+// parseFloats parses the numbers matched in a content stream
+func parseFloats(s []string) []float64 {
+	v := make([]float64, len(s))
+	for i, f := range s {
+		v[i], _ = strconv.ParseFloat(f, 64)
+	}
+	return v
+}
+
+// pdfPageDims returns the size of each page of a PDF in points
+func pdfPageDims(t testing.TB, data []byte) []types.Dim {
+	t.Helper()
+	dims, err := api.PageDims(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("reading page sizes: %v", err)
+	}
+	return dims
+}
+
+// cmykJPEG hand-writes a baseline JPEG with four components and an Adobe APP14 marker, the way
+// print workflows save CMYK, as image/jpeg only encodes YCbCr and gray. Every 8x8 block is filled
+// with the color blockColor returns for it, so only DC coefficients are coded. Width and height
+// must be multiples of 8.
+func cmykJPEG(width, height int, blockColor func(bx, by int) color.CMYK) []byte {
+	var out bytes.Buffer
+	segment := func(marker byte, payload ...byte) {
+		out.Write([]byte{0xff, marker, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)})
+		out.Write(payload)
+	}
+	out.Write([]byte{0xff, 0xd8})
+	// Adobe, version 100, no flags, transform 0: the components are CMYK, stored inverted
+	segment(0xee, 'A', 'd', 'o', 'b', 'e', 0, 100, 0, 0, 0, 0, 0)
+	segment(0xdb, append([]byte{0}, bytes.Repeat([]byte{1}, 64)...)...)
+	segment(0xc0, 8, byte(height>>8), byte(height), byte(width>>8), byte(width), 4,
+		1, 0x11, 0, 2, 0x11, 0, 3, 0x11, 0, 4, 0x11, 0)
+	// The standard luminance DC table, and an AC table holding only the end of block code "0"
+	segment(0xc4, append([]byte{0x00, 0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0},
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11)...)
+	segment(0xc4, 0x10, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	segment(0xda, 4, 1, 0x00, 2, 0x00, 3, 0x00, 4, 0x00, 0, 63, 0)
+
+	// Huffman codes of the DC categories in the standard table
+	dcCodes := [12]struct{ code, length uint32 }{
+		{0b00, 2}, {0b010, 3}, {0b011, 3}, {0b100, 3}, {0b101, 3}, {0b110, 3},
+		{0b1110, 4}, {0b11110, 5}, {0b111110, 6}, {0b1111110, 7}, {0b11111110, 8}, {0b111111110, 9},
+	}
+	var acc, bits uint32
+	put := func(code, length uint32) {
+		acc, bits = acc<<length|code&(1<<length-1), bits+length
+		for bits >= 8 {
+			b := byte(acc >> (bits - 8))
+			out.WriteByte(b)
+			if b == 0xff {
+				out.WriteByte(0)
+			}
+			bits -= 8
+		}
+	}
+	var previous [4]int
+	for by := 0; by < height/8; by++ {
+		for bx := 0; bx < width/8; bx++ {
+			c := blockColor(bx, by)
+			for i, v := range []uint8{c.C, c.M, c.Y, c.K} {
+				// The DC coefficient of a flat block is 8 times its level-shifted value
+				dc := 8 * (int(255-v) - 128)
+				diff := dc - previous[i]
+				previous[i] = dc
+				magnitude := diff
+				if magnitude < 0 {
+					magnitude = -magnitude
+				}
+				category := uint32(0)
+				for magnitude>>category > 0 {
+					category++
+				}
+				put(dcCodes[category].code, dcCodes[category].length)
+				if diff < 0 {
+					diff += 1<<category - 1
+				}
+				put(uint32(diff), category)
+				put(0, 1)
+			}
+		}
+	}
+	put(0x7f, (8-bits%8)%8)
+	out.Write([]byte{0xff, 0xd9})
+	return out.Bytes()
+}
@@ -0,0 +1,435 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+const (
+	iccJPEGMarker     = "ICC_PROFILE\x00"
+	iccJPEGHeaderSize = len(iccJPEGMarker) + 2 // marker + sequence number + chunk count
+	iccJPEGMaxChunk   = 65535 - 2 - iccJPEGHeaderSize
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// extractICCProfile returns the embedded ICC profile of a JPEG or PNG file, or nil if there is none
+func extractICCProfile(data []byte) []byte {
+	switch {
+	case len(data) > 2 && data[0] == 0xFF && data[1] == 0xD8:
+		return extractJPEGICCProfile(data)
+	case bytes.HasPrefix(data, pngSignature):
+		return extractPNGICCProfile(data)
+	}
+	return nil
+}
+
+// extractJPEGICCProfile reassembles the ICC profile from the APP2 segments of a JPEG
+func extractJPEGICCProfile(data []byte) []byte {
+	chunks := map[int][]byte{}
+	chunkCount := 0
+
+	for _, seg := range jpegSegments(data) {
+		if seg.marker != 0xE2 || !bytes.HasPrefix(seg.payload, []byte(iccJPEGMarker)) {
+			continue
+		}
+		if len(seg.payload) < iccJPEGHeaderSize {
+			continue
+		}
+		seq := int(seg.payload[len(iccJPEGMarker)])
+		chunkCount = int(seg.payload[len(iccJPEGMarker)+1])
+		chunks[seq] = seg.payload[iccJPEGHeaderSize:]
+	}
+
+	if chunkCount == 0 || len(chunks) != chunkCount {
+		return nil
+	}
+
+	var profile []byte
+	for seq := 1; seq <= chunkCount; seq++ {
+		chunk, ok := chunks[seq]
+		if !ok {
+			return nil
+		}
+		profile = append(profile, chunk...)
+	}
+	return profile
+}
+
+// extractPNGICCProfile decompresses the profile stored in a PNG iCCP chunk
+func extractPNGICCProfile(data []byte) []byte {
+	pos := len(pngSignature)
+	for pos+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		chunkType := string(data[pos+4 : pos+8])
+		start := pos + 8
+		end := start + length
+		if length < 0 || end+4 > len(data) {
+			return nil
+		}
+
+		switch chunkType {
+		case "iCCP":
+			// Profile name, NUL separator, compression method, zlib stream
+			body := data[start:end]
+			nameEnd := bytes.IndexByte(body, 0)
+			if nameEnd < 0 || nameEnd+2 > len(body) || body[nameEnd+1] != 0 {
+				return nil
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(body[nameEnd+2:]))
+			if err != nil {
+				return nil
+			}
+			defer zr.Close()
+			profile, err := io.ReadAll(zr)
+			if err != nil {
+				return nil
+			}
+			return profile
+		case "IDAT", "IEND":
+			// iCCP must precede the image data
+			return nil
+		}
+
+		pos = end + 4 // skip CRC
+	}
+	return nil
+}
+
+// jpegSegment is a single marker segment from the header section of a JPEG file
+type jpegSegment struct {
+	marker  byte
+	start   int // offset of the 0xFF marker byte
+	end     int // offset just past the segment
+	payload []byte
+}
+
+// jpegSegments lists the marker segments between SOI and the start of scan
+func jpegSegments(data []byte) []jpegSegment {
+	var segments []jpegSegment
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			break
+		}
+		marker := data[pos+1]
+		if marker == 0xFF { // fill byte
+			pos++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 { // SOS or EOI
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			break
+		}
+		segments = append(segments, jpegSegment{
+			marker:  marker,
+			start:   pos,
+			end:     end,
+			payload: data[pos+4 : end],
+		})
+		pos = end
+	}
+	return segments
+}
+
+// embedICCProfile rewrites a JPEG file with the given ICC profile stored in APP2 segments
+func embedICCProfile(jpegPath string, profile []byte) error {
+	data, err := os.ReadFile(longPath(jpegPath))
+	if err != nil {
+		return err
+	}
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return fmt.Errorf("%s is not a JPEG file", jpegPath)
+	}
+
+	chunkCount := (len(profile) + iccJPEGMaxChunk - 1) / iccJPEGMaxChunk
+	if chunkCount == 0 || chunkCount > 255 {
+		return fmt.Errorf("ICC profile of %d bytes cannot be embedded", len(profile))
+	}
+
+	// Insert after SOI, or after the JFIF APP0 segment if the encoder wrote one
+	insertAt := 2
+	if segments := jpegSegments(data); len(segments) > 0 && segments[0].marker == 0xE0 {
+		insertAt = segments[0].end
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data[:insertAt])
+	for i := 0; i < chunkCount; i++ {
+		chunk := profile[i*iccJPEGMaxChunk : min((i+1)*iccJPEGMaxChunk, len(profile))]
+		buf.Write([]byte{0xFF, 0xE2})
+		binary.Write(&buf, binary.BigEndian, uint16(2+iccJPEGHeaderSize+len(chunk)))
+		buf.WriteString(iccJPEGMarker)
+		buf.Write([]byte{byte(i + 1), byte(chunkCount)})
+		buf.Write(chunk)
+	}
+	buf.Write(data[insertAt:])
+
+	return os.WriteFile(longPath(jpegPath), buf.Bytes(), 0644)
+}
+
+// iccToneCurve maps an encoded channel value in [0,1] to linear light
+type iccToneCurve func(float64) float64
+
+// iccMatrixProfile is an RGB matrix/TRC profile, the kind used by Display P3, Adobe RGB and friends
+type iccMatrixProfile struct {
+	toXYZ [3][3]float64 // linear RGB → PCS XYZ (D50)
+	trc   [3]iccToneCurve
+}
+
+// xyzD50ToLinearSRGB is the Bradford-adapted inverse of the sRGB primaries matrix
+var xyzD50ToLinearSRGB = [3][3]float64{
+	{3.1338561, -1.6168667, -0.4906146},
+	{-0.9787684, 1.9161415, 0.0334540},
+	{0.0719453, -0.2289914, 1.4052427},
+}
+
+// parseICCMatrixProfile reads the colorant and tone curve tags of an RGB matrix/TRC profile
+func parseICCMatrixProfile(profile []byte) (*iccMatrixProfile, error) {
+	if len(profile) < 132 {
+		return nil, fmt.Errorf("ICC profile too short")
+	}
+	if iccColorSpace(profile) != "RGB" {
+		return nil, fmt.Errorf("unsupported ICC color space %q", string(profile[16:20]))
+	}
+
+	tags := map[string][]byte{}
+	tagCount := int(binary.BigEndian.Uint32(profile[128:]))
+	for i := 0; i < tagCount; i++ {
+		entry := 132 + i*12
+		if entry+12 > len(profile) {
+			return nil, fmt.Errorf("truncated ICC tag table")
+		}
+		sig := string(profile[entry : entry+4])
+		offset := int(binary.BigEndian.Uint32(profile[entry+4:]))
+		size := int(binary.BigEndian.Uint32(profile[entry+8:]))
+		if offset < 0 || size < 0 || offset+size > len(profile) {
+			return nil, fmt.Errorf("ICC tag %q out of range", sig)
+		}
+		tags[sig] = profile[offset : offset+size]
+	}
+
+	p := &iccMatrixProfile{}
+	for i, sig := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		xyz, err := parseICCXYZ(tags[sig])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v (only matrix/TRC profiles are supported)", sig, err)
+		}
+		for row := 0; row < 3; row++ {
+			p.toXYZ[row][i] = xyz[row]
+		}
+	}
+	for i, sig := range []string{"rTRC", "gTRC", "bTRC"} {
+		curve, err := parseICCCurve(tags[sig])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", sig, err)
+		}
+		p.trc[i] = curve
+	}
+	return p, nil
+}
+
+func parseICCXYZ(tag []byte) ([3]float64, error) {
+	if len(tag) < 20 || string(tag[:4]) != "XYZ " {
+		return [3]float64{}, fmt.Errorf("missing XYZ tag")
+	}
+	return [3]float64{
+		s15Fixed16(tag[8:]),
+		s15Fixed16(tag[12:]),
+		s15Fixed16(tag[16:]),
+	}, nil
+}
+
+func parseICCCurve(tag []byte) (iccToneCurve, error) {
+	if len(tag) < 12 {
+		return nil, fmt.Errorf("missing tone curve")
+	}
+
+	switch string(tag[:4]) {
+	case "curv":
+		count := int(binary.BigEndian.Uint32(tag[8:]))
+		if len(tag) < 12+count*2 {
+			return nil, fmt.Errorf("truncated curv tag")
+		}
+		switch count {
+		case 0:
+			return func(v float64) float64 { return v }, nil
+		case 1:
+			gamma := float64(binary.BigEndian.Uint16(tag[12:])) / 256
+			return func(v float64) float64 { return math.Pow(v, gamma) }, nil
+		}
+		table := make([]float64, count)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(tag[12+i*2:])) / 65535
+		}
+		return func(v float64) float64 {
+			pos := v * float64(count-1)
+			i := int(pos)
+			if i >= count-1 {
+				return table[count-1]
+			}
+			frac := pos - float64(i)
+			return table[i]*(1-frac) + table[i+1]*frac
+		}, nil
+
+	case "para":
+		funcType := int(binary.BigEndian.Uint16(tag[8:]))
+		paramCounts := []int{1, 3, 4, 5, 7}
+		if funcType >= len(paramCounts) || len(tag) < 12+paramCounts[funcType]*4 {
+			return nil, fmt.Errorf("unsupported parametric curve type %d", funcType)
+		}
+		var p [7]float64
+		for i := 0; i < paramCounts[funcType]; i++ {
+			p[i] = s15Fixed16(tag[12+i*4:])
+		}
+		g, a, b, c, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+		return func(v float64) float64 {
+			switch funcType {
+			case 0:
+				return math.Pow(v, g)
+			case 1:
+				if v >= -b/a {
+					return math.Pow(a*v+b, g)
+				}
+				return 0
+			case 2:
+				if v >= -b/a {
+					return math.Pow(a*v+b, g) + c
+				}
+				return c
+			case 3:
+				if v >= d {
+					return math.Pow(a*v+b, g)
+				}
+				return c * v
+			default:
+				if v >= d {
+					return math.Pow(a*v+b, g) + e
+				}
+				return c*v + f
+			}
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported tone curve type %q", string(tag[:4]))
+}
+
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+// toLinearSRGB returns the combined linear transform: source RGB → XYZ (D50) → linear sRGB
+func (p *iccMatrixProfile) toLinearSRGB() [3][3]float64 {
+	var m [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				m[i][j] += xyzD50ToLinearSRGB[i][k] * p.toXYZ[k][j]
+			}
+		}
+	}
+	return m
+}
+
+// isSRGB reports whether the profile describes sRGB itself, as most camera and phone JPEGs carry.
+// Converting those would change nothing but cost a re-encode.
+func (p *iccMatrixProfile) isSRGB() bool {
+	m := p.toLinearSRGB()
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			want := 0.0
+			if i == j {
+				want = 1
+			}
+			if math.Abs(m[i][j]-want) > 0.01 {
+				return false
+			}
+		}
+	}
+	for _, v := range []float64{0.02, 0.25, 0.5, 0.75} {
+		want := math.Pow((v+0.055)/1.055, 2.4)
+		if v <= 0.04045 {
+			want = v / 12.92
+		}
+		for _, trc := range p.trc {
+			if math.Abs(trc(v)-want) > 0.005 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// iccColorSpace returns the data color space of an ICC profile, such as "RGB", "CMYK" or "GRAY"
+func iccColorSpace(profile []byte) string {
+	if len(profile) < 20 {
+		return ""
+	}
+	return strings.TrimSpace(string(profile[16:20]))
+}
+
+// convertToSRGB transforms the pixel data of an image tagged with an RGB matrix/TRC profile into sRGB
+func convertToSRGB(img image.Image, profile []byte) (image.Image, error) {
+	p, err := parseICCMatrixProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	m := p.toLinearSRGB()
+
+	// 8-bit input lookup tables per channel, and a fine-grained sRGB encoding table
+	var linear [3][256]float64
+	for c := 0; c < 3; c++ {
+		for v := 0; v < 256; v++ {
+			linear[c][v] = p.trc[c](float64(v) / 255)
+		}
+	}
+	const encodeSteps = 4096
+	var encode [encodeSteps + 1]uint8
+	for i := range encode {
+		l := float64(i) / encodeSteps
+		var s float64
+		if l <= 0.0031308 {
+			s = 12.92 * l
+		} else {
+			s = 1.055*math.Pow(l, 1/2.4) - 0.055
+		}
+		encode[i] = uint8(math.Round(s * 255))
+	}
+	toSRGB := func(l float64) uint8 {
+		if l <= 0 {
+			return 0
+		}
+		if l >= 1 {
+			return 255
+		}
+		return encode[int(l*encodeSteps+0.5)]
+	}
+
+	bounds := img.Bounds()
+	out := image.NewNRGBA(bounds)
+	draw.Draw(out, bounds, img, bounds.Min, draw.Src)
+
+	for i := 0; i+3 < len(out.Pix); i += 4 {
+		r := linear[0][out.Pix[i]]
+		g := linear[1][out.Pix[i+1]]
+		b := linear[2][out.Pix[i+2]]
+		out.Pix[i] = toSRGB(m[0][0]*r + m[0][1]*g + m[0][2]*b)
+		out.Pix[i+1] = toSRGB(m[1][0]*r + m[1][1]*g + m[1][2]*b)
+		out.Pix[i+2] = toSRGB(m[2][0]*r + m[2][1]*g + m[2][2]*b)
+	}
+
+	return out, nil
+}
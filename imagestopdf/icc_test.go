@@ -0,0 +1,210 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// Colorants (D50 adapted XYZ of the red, green and blue primaries) of the fixture profiles
+var (
+	srgbColorants      = [3][3]float64{{0.4361, 0.2225, 0.0139}, {0.3851, 0.7169, 0.0971}, {0.1431, 0.0606, 0.7141}}
+	displayP3Colorants = [3][3]float64{{0.5151, 0.2412, -0.0011}, {0.2920, 0.6922, 0.0419}, {0.1571, 0.0666, 0.7841}}
+)
+
+// matrixProfile builds a minimal matrix/TRC ICC profile with the sRGB tone curve. A nil colorants
+// leaves out the tags, for profiles of other color spaces.
+func matrixProfile(space string, colorants *[3][3]float64) []byte {
+	s15 := func(b []byte, v float64) []byte {
+		return binary.BigEndian.AppendUint32(b, uint32(int32(math.Round(v*65536))))
+	}
+	type tag struct {
+		sig  string
+		data []byte
+	}
+	var tags []tag
+	if colorants != nil {
+		for i, sig := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+			data := []byte("XYZ \x00\x00\x00\x00")
+			for _, v := range colorants[i] {
+				data = s15(data, v)
+			}
+			tags = append(tags, tag{sig, data})
+		}
+		curve := []byte("para\x00\x00\x00\x00\x00\x03\x00\x00")
+		for _, v := range []float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045} {
+			curve = s15(curve, v)
+		}
+		for _, sig := range []string{"rTRC", "gTRC", "bTRC"} {
+			tags = append(tags, tag{sig, curve})
+		}
+	}
+
+	offset := 128 + 4 + 12*len(tags)
+	var table, data []byte
+	table = binary.BigEndian.AppendUint32(table, uint32(len(tags)))
+	for _, t := range tags {
+		table = append(table, t.sig...)
+		table = binary.BigEndian.AppendUint32(table, uint32(offset+len(data)))
+		table = binary.BigEndian.AppendUint32(table, uint32(len(t.data)))
+		data = append(data, t.data...)
+	}
+
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header, uint32(128+len(table)+len(data)))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000)
+	copy(header[12:], "mntr")
+	copy(header[16:], (space + "    ")[:4])
+	copy(header[20:], "XYZ ")
+	copy(header[36:], "acsp")
+	return append(append(header, table...), data...)
+}
+
+// withICCProfile embeds profile into a JPEG as a single APP2 chunk
+func withICCProfile(jpegData, profile []byte) []byte {
+	payload := append([]byte(iccJPEGMarker+"\x01\x01"), profile...)
+	return withAPPSegment(jpegData, 0xE2, payload)
+}
+
+func TestMatrixProfileIsSRGB(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		colorants [3][3]float64
+		want      bool
+	}{
+		{"sRGB", srgbColorants, true},
+		{"Display P3", displayP3Colorants, false},
+	} {
+		profile, err := parseICCMatrixProfile(matrixProfile("RGB", &tc.colorants))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := profile.isSRGB(); got != tc.want {
+			t.Errorf("%s: isSRGB() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestICCColorSpace(t *testing.T) {
+	if got := iccColorSpace(matrixProfile("RGB", &srgbColorants)); got != "RGB" {
+		t.Errorf("RGB profile: got %q", got)
+	}
+	if got := iccColorSpace(matrixProfile("CMYK", nil)); got != "CMYK" {
+		t.Errorf("CMYK profile: got %q", got)
+	}
+	if _, err := parseICCMatrixProfile(matrixProfile("CMYK", nil)); err == nil {
+		t.Error("parsing a CMYK profile as a matrix profile should fail")
+	}
+}
+
+func TestConvertToSRGBDisplayP3(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.SetRGBA(0, 0, color.RGBA{128, 128, 128, 255})
+	img.SetRGBA(1, 0, color.RGBA{180, 90, 60, 255})
+
+	converted, err := convertToSRGB(img, matrixProfile("RGB", &displayP3Colorants))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both spaces share the D65 white point, so grays stay gray
+	if r, g, b, _ := converted.At(0, 0).RGBA(); absDiff(r>>8, 128) > 1 || absDiff(g>>8, 128) > 1 || absDiff(b>>8, 128) > 1 {
+		t.Errorf("gray became %d,%d,%d", r>>8, g>>8, b>>8)
+	}
+	// A P3 orange lies further out than the same numbers in sRGB
+	if r, g, _, _ := converted.At(1, 0).RGBA(); r>>8 < 190 || g>>8 > 85 {
+		t.Errorf("P3 180,90,60 became %d,%d in red and green, want a more saturated orange", r>>8, g>>8)
+	}
+}
+
+func absDiff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// TestICCProfileHandling runs tagged JPEGs through the conversion and checks the embedded image
+// either carries the profile or has converted pixels. Rotating forces a re-encode, which has to
+// put a pass-through profile back.
+func TestICCProfileHandling(t *testing.T) {
+	source := encodeJPEG(t, solidImage(200, 200, color.RGBA{180, 90, 60, 255}), 90)
+	p3 := matrixProfile("RGB", &displayP3Colorants)
+	srgb := matrixProfile("RGB", &srgbColorants)
+	cmyk := matrixProfile("CMYK", nil)
+
+	for _, tc := range []struct {
+		name        string
+		profile     []byte
+		convertSRGB bool
+		rotate      int
+		wantProfile []byte
+		converted   bool
+	}{
+		{"no profile", nil, true, 0, nil, false},
+		{"no profile re-encoded", nil, true, 180, nil, false},
+		{"Display P3 converted", p3, true, 0, nil, true},
+		{"Display P3 passed through", p3, false, 0, p3, false},
+		{"Display P3 passed through re-encoded", p3, false, 180, p3, false},
+		{"sRGB kept as is", srgb, true, 0, srgb, false},
+		{"sRGB re-encoded", srgb, true, 180, nil, false},
+		{"CMYK profile re-encoded", cmyk, false, 180, nil, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data := source
+			if tc.profile != nil {
+				data = withICCProfile(source, tc.profile)
+			}
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "photo.jpg"), data)
+
+			pdf := convertForTest(t, Options{Inputs: []string{dir}, ConvertSRGB: tc.convertSRGB, Rotate: tc.rotate})
+			pages := pdfImages(t, pdf)
+			if len(pages) != 1 || len(pages[0]) != 1 {
+				t.Fatalf("expected one image on one page, got %d page(s)", len(pages))
+			}
+			embedded := readAll(t, pages[0][0])
+
+			if profile := extractICCProfile(embedded); !bytes.Equal(profile, tc.wantProfile) {
+				t.Errorf("embedded ICC profile of %d bytes, want %d bytes", len(profile), len(tc.wantProfile))
+			}
+
+			img, err := jpeg.Decode(bytes.NewReader(embedded))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, _, _, _ := img.At(100, 100).RGBA()
+			if tc.converted && r>>8 < 190 {
+				t.Errorf("red is %d, the pixels weren't converted to sRGB", r>>8)
+			}
+			if !tc.converted && absDiff(r>>8, 180) > 3 {
+				t.Errorf("red is %d, the pixels shouldn't have changed", r>>8)
+			}
+		})
+	}
+}
+
+// TestICCPassThroughByDefault converts a Display P3 JPEG with the command's defaults, which keep
+// the profile and the pixels as they are
+func TestICCPassThroughByDefault(t *testing.T) {
+	p3 := matrixProfile("RGB", &displayP3Colorants)
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "photo.jpg"), withICCProfile(encodeJPEG(t, solidImage(200, 200, color.RGBA{180, 90, 60, 255}), 90), p3))
+
+	opts := defaultOptions()
+	opts.Inputs = []string{dir}
+	opts.Status = io.Discard
+	pages := pdfImages(t, convertForTest(t, opts))
+	if len(pages) != 1 || len(pages[0]) != 1 {
+		t.Fatalf("expected one image on one page, got %d page(s)", len(pages))
+	}
+	if profile := extractICCProfile(readAll(t, pages[0][0])); !bytes.Equal(profile, p3) {
+		t.Errorf("embedded ICC profile of %d bytes, want the Display P3 profile of %d bytes", len(profile), len(p3))
+	}
+}
@@ -0,0 +1,133 @@
+package imagestopdf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFileName is the file listing patterns of images to leave out of the directory it is in
+const ignoreFileName = ".pdfignore"
+
+// ignoreFile is one loaded .pdfignore and how many images its patterns excluded
+type ignoreFile struct {
+	path     string
+	excluded int
+}
+
+// ignoreRule is one pattern line of an ignore file, following gitignore: a pattern without a
+// slash matches names at any depth, one with a slash is relative to the ignore file's directory,
+// a trailing slash only matches directories and a leading ! includes what earlier lines excluded
+type ignoreRule struct {
+	pattern []string // slash-separated segments, ** matches any number of them
+	negate  bool
+	dirOnly bool
+	base    string // directory of the ignore file, relative to the walked root
+	file    *ignoreFile
+}
+
+// loadIgnoreFile reads the rules of dir's .pdfignore, if there is one. rel is dir relative to the walked root.
+func loadIgnoreFile(dir, rel string) (*ignoreFile, []ignoreRule, error) {
+	ignorePath := filepath.Join(dir, ignoreFileName)
+	file, err := os.Open(ignorePath)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	loaded := &ignoreFile{path: ignorePath}
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: rel, file: loaded}
+		if strings.HasPrefix(line, "!") {
+			rule.negate, line = true, line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:] // escaped leading # or !
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly, line = true, strings.TrimRight(line, "/")
+		}
+		if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+		if line = strings.TrimPrefix(line, "/"); line == "" {
+			continue
+		}
+		rule.pattern = strings.Split(line, "/")
+		for _, segment := range rule.pattern {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, nil, fmt.Errorf("%s: invalid pattern %q", ignorePath, scanner.Text())
+			}
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return loaded, rules, nil
+}
+
+// ignoredBy returns the ignore file whose rules exclude the path, relative to the walked root
+// in slash form, or nil if it is kept. The last matching rule wins, as in gitignore.
+func ignoredBy(rules []ignoreRule, rel string, isDir bool) *ignoreFile {
+	var excludedBy *ignoreFile
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		target := rel
+		if rule.base != "" {
+			target = strings.TrimPrefix(rel, rule.base+"/")
+		}
+		if matchSegments(rule.pattern, strings.Split(target, "/")) {
+			excludedBy = rule.file
+			if rule.negate {
+				excludedBy = nil
+			}
+		}
+	}
+	return excludedBy
+}
+
+// matchSegments matches path segments against pattern segments, where ** stands for zero or
+// more segments and the others are matched with path.Match
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for skip := 0; skip <= len(segments); skip++ {
+			if matchSegments(pattern[1:], segments[skip:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+// reportIgnoreFiles prints how many images each ignore file excluded, so patterns that match
+// more than intended are easy to spot
+func reportIgnoreFiles(status io.Writer, files []*ignoreFile) {
+	for _, file := range files {
+		fmt.Fprintf(status, "Excluded %d image(s) by %s\n", file.excluded, file.path)
+	}
+}
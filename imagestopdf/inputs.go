@@ -0,0 +1,100 @@
+package imagestopdf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// collectInputs expands the --input values into image files. Directories are walked, honoring
+// .pdfignore files unless useIgnoreFiles is off, single files must have a supported image extension. A file reached twice is listed once.
+func collectInputs(ctx context.Context, status io.Writer, inputs []string, retry *retrier, useIgnoreFiles bool) ([]string, error) {
+	var imageFiles []string
+	seen := map[string]bool{}
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrInputNotFound, input)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input: %v", err)
+		}
+
+		files := []string{input}
+		if info.IsDir() {
+			// A transient error aborts the walk, the directory is then walked again
+			err = retry.do(ctx, input, func() (err error) {
+				files, err = findImageFiles(status, input, useIgnoreFiles)
+				return err
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to find image files: %v", err)
+			}
+		} else if err := checkImageFile(input); err != nil {
+			return nil, err
+		}
+
+		for _, path := range files {
+			if key := filepath.Clean(path); !seen[key] {
+				seen[key] = true
+				imageFiles = append(imageFiles, path)
+			}
+		}
+	}
+	return imageFiles, nil
+}
+
+// checkImageFile rejects a single input file findImageFiles would not have picked up
+func checkImageFile(path string) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".avif" && !avifSupported {
+		return fmt.Errorf("%s: AVIF support requires building with -tags avif (cgo and libavif)", path)
+	}
+	if !supportedExts[ext] {
+		exts := make([]string, 0, len(supportedExts))
+		for ext := range supportedExts {
+			exts = append(exts, ext)
+		}
+		slices.Sort(exts)
+		return fmt.Errorf("%s is not a supported image, expected one of: %s", path, strings.Join(exts, ", "))
+	}
+	return nil
+}
+
+// primaryInputDir is the directory of the first input, the one rotation files, relative
+// list entries and the {dir} placeholder refer to
+func primaryInputDir(inputs []string) string {
+	if len(inputs) == 0 {
+		return "."
+	}
+	if info, err := os.Stat(inputs[0]); err == nil && !info.IsDir() {
+		return filepath.Dir(inputs[0])
+	}
+	return inputs[0]
+}
+
+// inputFor returns the input a discovered image came from
+func inputFor(path string, inputs []string) string {
+	for _, input := range inputs {
+		if rel, err := filepath.Rel(input, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return input
+		}
+	}
+	return ""
+}
+
+// defaultOutputName names the PDF after the image when the only input is a single file,
+// and falls back to images.pdf otherwise
+func defaultOutputName(inputs []string) string {
+	if len(inputs) == 1 {
+		if info, err := os.Stat(inputs[0]); err == nil && !info.IsDir() {
+			base := filepath.Base(inputs[0])
+			return strings.TrimSuffix(base, filepath.Ext(base)) + ".pdf"
+		}
+	}
+	return defaultOptions().Name
+}
@@ -0,0 +1,160 @@
+package imagestopdf
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// planPreviewCount is how many file names --interactive lists from each end of the page order
+const planPreviewCount = 5
+
+// validateInteractive rejects --stdin-tar with --interactive, standard input can't carry both the images and the answer
+func validateInteractive(o Options) error {
+	if o.Interactive && o.StdinTar {
+		return fmt.Errorf("--interactive reads the answer from standard input, it can't be combined with --stdin-tar")
+	}
+	return nil
+}
+
+// stdinIsTerminal reports whether standard input is a terminal someone can answer a prompt on.
+// The null device is a character device too, scripts often redirect from it.
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// printPlan discovers and orders the images like a conversion would and prints what it is about
+// to do: the image count and input size, the first and last file names, the page size and where
+// the output goes. The auto page size is an estimate, see plannedPageBasis.
+func printPlan(ctx context.Context, opts Options) error {
+	retry := newRetrier(opts.Retries)
+	images, err := discoverImages(ctx, opts.Inputs, retry, opts)
+	if err != nil {
+		return err
+	}
+	if images, err = pageOrder(images, opts); err != nil {
+		return err
+	}
+	var backs []string
+	if opts.InputDir2 != "" {
+		if backs, err = discoverImages(ctx, []string{opts.InputDir2}, retry, opts); err != nil {
+			return err
+		}
+	}
+
+	var total int64
+	for _, imagePath := range append(images, backs...) {
+		if info, err := os.Stat(longPath(imagePath)); err == nil {
+			total += info.Size()
+		}
+	}
+	fmt.Fprintf(opts.status(), "Plan: %d image(s), %.2f MB of input\n", len(images)+len(backs), megabytes(total))
+	if len(backs) > 0 {
+		fmt.Fprintf(opts.status(), "%d front(s) interleaved with %d back(s) from %s\n", len(images), len(backs), opts.InputDir2)
+	}
+
+	inputDir := primaryInputDir(opts.Inputs)
+	name := func(imagePath string) string {
+		keys := sourceNameKeys(imagePath, inputDir)
+		return keys[len(keys)-1]
+	}
+	if len(images) <= 2*planPreviewCount {
+		fmt.Fprintf(opts.status(), "Page order:\n")
+		for _, imagePath := range images {
+			fmt.Fprintf(opts.status(), "  • %s\n", name(imagePath))
+		}
+	} else {
+		fmt.Fprintf(opts.status(), "Page order, first and last %d:\n", planPreviewCount)
+		for _, imagePath := range images[:planPreviewCount] {
+			fmt.Fprintf(opts.status(), "  • %s\n", name(imagePath))
+		}
+		fmt.Fprintf(opts.status(), "  ... %d more ...\n", len(images)-2*planPreviewCount)
+		for _, imagePath := range images[len(images)-planPreviewCount:] {
+			fmt.Fprintf(opts.status(), "  • %s\n", name(imagePath))
+		}
+	}
+
+	switch {
+	case opts.UseSourceDPI:
+		fmt.Fprintf(opts.status(), "Page size: from the DPI each image declares (%s)\n", opts.PageBasis)
+	case opts.PageSize == "auto":
+		basisWidth, basisHeight, err := plannedPageBasis(ctx, opts.status(), images, opts.PageBasis, retry)
+		if err != nil {
+			return fmt.Errorf("failed to calculate page size: %v", err)
+		}
+		_, _, pageWidth, pageHeight := sheetSize(opts.PageSize, opts.Booklet, basisWidth*72/opts.DPI, basisHeight*72/opts.DPI, opts.Margin)
+		fmt.Fprintf(opts.status(), "Page size: about %.1fx%.1f points at %g DPI (%s of the image sizes)\n", pageWidth, pageHeight, opts.DPI, opts.PageBasis)
+	default:
+		_, _, pageWidth, pageHeight := sheetSize(opts.PageSize, opts.Booklet, 0, 0, opts.Margin)
+		fmt.Fprintf(opts.status(), "Page size: %s (%.1fx%.1f mm per page)\n", strings.ToUpper(opts.PageSize), pageWidth, pageHeight)
+	}
+
+	switch {
+	case opts.OnePerImage:
+		fmt.Fprintf(opts.status(), "Output: one PDF per image in %s\n", opts.OutputDir)
+	case opts.BatchSize > 0:
+		fmt.Fprintf(opts.status(), "Output: %d PDF(s) of up to %d images in %s\n", (len(images)+opts.BatchSize-1)/opts.BatchSize, opts.BatchSize, opts.OutputDir)
+	case opts.OutputDir == "-":
+		fmt.Fprintf(opts.status(), "Output: standard output\n")
+	default:
+		fmt.Fprintf(opts.status(), "Output: %s\n", filepath.Join(opts.OutputDir, opts.Name))
+	}
+	return nil
+}
+
+// plannedPageBasis estimates the page size basis from the image headers the way
+// calculatePageBasisSize works it out from the optimized copies, taking images wider than
+// optimizedWidth as scaled down to it. Images that end up kept as they are, rotated or cropped
+// make the real size differ.
+func plannedPageBasis(ctx context.Context, status io.Writer, images []string, basis string, retry *retrier) (float64, float64, error) {
+	var widths, heights []float64
+	for _, imagePath := range images {
+		var config image.Config
+		err := retry.do(ctx, imagePath, func() error {
+			file, err := os.Open(longPath(imagePath))
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			config, _, err = image.DecodeConfig(file)
+			return err
+		})
+		if err != nil {
+			logger.Warn("could not read image size", "path", imagePath, "error", err)
+			continue
+		}
+		if config.Width == 0 || config.Height == 0 {
+			logger.Warn("skipping empty image", "path", imagePath, "width", config.Width, "height", config.Height)
+			continue
+		}
+		width, height := float64(config.Width), float64(config.Height)
+		if config.Width > optimizedWidth {
+			width, height = optimizedWidth, height*optimizedWidth/width
+		}
+		widths = append(widths, width)
+		heights = append(heights, height)
+	}
+	return pageBasis(status, widths, heights, basis, "pixels")
+}
+
+// confirm asks question on out and reports whether the answer read from in is yes. Anything else,
+// including an empty line or the end of input, is no.
+func confirm(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package imagestopdf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// interleaveModes are the accepted --interleave values
+var interleaveModes = []string{"reverse", "forward"}
+
+// validateInterleave checks the --interleave value and that the second input is usable
+func validateInterleave(opts Options) error {
+	if !slices.Contains(interleaveModes, opts.Interleave) {
+		return fmt.Errorf("invalid interleave mode %q, valid values are: %s", opts.Interleave, strings.Join(interleaveModes, ", "))
+	}
+	if opts.InputDir2 == "" {
+		return nil
+	}
+	if _, err := os.Stat(opts.InputDir2); os.IsNotExist(err) {
+		return fmt.Errorf("%w (--input2): %s", ErrInputNotFound, opts.InputDir2)
+	}
+	for _, input := range opts.Inputs {
+		if filepath.Clean(opts.InputDir2) == filepath.Clean(input) {
+			return fmt.Errorf("--input2 must be a different directory than --input")
+		}
+	}
+	return nil
+}
+
+// interleavePages alternates front and back pages: front1, back1, front2, back2, ...
+// Duplex scanners fed the stack a second time produce the backs last page first, which
+// the reverse mode undoes. Unequal counts fail in strict mode and are otherwise padded
+// with blank placeholders (images without a path) naming the image on the other side.
+func interleavePages(fronts, backs []optimizedImage, mode string, strict bool) ([]optimizedImage, error) {
+	if mode == "reverse" {
+		backs = slices.Clone(backs)
+		slices.Reverse(backs)
+	}
+
+	if len(fronts) != len(backs) {
+		if strict {
+			return nil, fmt.Errorf("cannot interleave %d front pages with %d back pages", len(fronts), len(backs))
+		}
+		logger.Warn("input page counts differ, padding with blank pages", "front_pages", len(fronts), "back_pages", len(backs))
+	}
+
+	pages := make([]optimizedImage, 0, 2*max(len(fronts), len(backs)))
+	for i := 0; i < len(fronts) || i < len(backs); i++ {
+		var front, back optimizedImage
+		if i < len(fronts) {
+			front = fronts[i]
+		}
+		if i < len(backs) {
+			back = backs[i]
+		}
+		// A sheet whose other side --skip-blank dropped needs no padding either
+		if front.path == "" {
+			front.counterpart, front.blank = back.sourcePath, front.blank || back.blank
+		}
+		if back.path == "" {
+			back.counterpart, back.blank = front.sourcePath, back.blank || front.blank
+		}
+		pages = append(pages, front, back)
+	}
+	return pages, nil
+}
@@ -0,0 +1,176 @@
+package imagestopdf
+
+import (
+	"fmt"
+	"image/color"
+	"slices"
+	"strings"
+
+	"github.com/johnfercher/maroto/v2/pkg/components/col"
+	marotoimage "github.com/johnfercher/maroto/v2/pkg/components/image"
+	"github.com/johnfercher/maroto/v2/pkg/components/row"
+	"github.com/johnfercher/maroto/v2/pkg/components/text"
+	"github.com/johnfercher/maroto/v2/pkg/consts/align"
+	"github.com/johnfercher/maroto/v2/pkg/consts/fontstyle"
+	"github.com/johnfercher/maroto/v2/pkg/core"
+	"github.com/johnfercher/maroto/v2/pkg/props"
+)
+
+// paperSizes are the named --page-size values as portrait width and height in millimeters
+var paperSizes = map[string][2]float64{
+	"a3":     {297, 420},
+	"a4":     {210, 297},
+	"a5":     {148, 210},
+	"letter": {215.9, 279.4},
+	"legal":  {215.9, 355.6},
+}
+
+// validatePageSize checks the --page-size value
+func validatePageSize(size string) error {
+	if size == "auto" {
+		return nil
+	}
+	if _, ok := paperSizes[size]; !ok {
+		names := []string{"auto"}
+		for name := range paperSizes {
+			names = append(names, name)
+		}
+		slices.Sort(names[1:])
+		return fmt.Errorf("invalid page size %q, valid values are: %s", size, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// sheetSize returns the output sheet dimensions and the area each source page occupies on it.
+// With the auto size the page comes from the images (width and height at the given DPI); a booklet
+// sheet holds two of those side by side, or is the named paper size in landscape.
+// The margin surrounds the sheet: auto sheets grow by it so images keep their size, named
+// paper sizes keep their size and leave less room for the pages.
+func sheetSize(pageSize string, booklet bool, autoWidth, autoHeight, margin float64) (sheetW, sheetH, pageW, pageH float64) {
+	paper, named := paperSizes[pageSize]
+	switch {
+	case !named && !booklet:
+		return autoWidth + 2*margin, autoHeight + 2*margin, autoWidth, autoHeight
+	case !named:
+		return 2*autoWidth + 2*margin, autoHeight + 2*margin, autoWidth, autoHeight
+	case !booklet:
+		return paper[0], paper[1], paper[0] - 2*margin, paper[1] - 2*margin
+	}
+	return paper[1], paper[0], (paper[1] - 2*margin) / 2, paper[0] - 2*margin
+}
+
+// validateMargin checks that a named paper size leaves room for the pages inside the margin
+func validateMargin(o Options) error {
+	if _, _, pageW, pageH := sheetSize(o.PageSize, o.Booklet, 1, 1, o.Margin); pageW <= 0 || pageH <= 0 {
+		return fmt.Errorf("margin of %.1f mm leaves no room for images on %s pages", o.Margin, strings.ToUpper(o.PageSize))
+	}
+	if o.Border && o.BorderWidth <= 0 {
+		return fmt.Errorf("invalid border width %.2f mm, must be positive", o.BorderWidth)
+	}
+	return nil
+}
+
+// bookletPageCount rounds a page count up to the multiple of 4 a folded booklet needs
+func bookletPageCount(pages int) int {
+	return (pages + 3) / 4 * 4
+}
+
+// bookletOrder returns the 1-based page numbers in the order they are placed on booklet sheets,
+// two per sheet side: n,1 on the first front, 2,n-1 on its back, n-2,3 on the next front and so on.
+// Printed double-sided (flip on short edge) and folded, the sheets read 1..n. pages must be a multiple of 4.
+func bookletOrder(pages int) []int {
+	order := make([]int, 0, pages)
+	for sheet := 0; sheet < pages/4; sheet++ {
+		order = append(order,
+			pages-2*sheet, 1+2*sheet, // front
+			2+2*sheet, pages-1-2*sheet, // back
+		)
+	}
+	return order
+}
+
+// page is one page of the document: an image, a section divider showing a title, or blank when both are empty
+type page struct {
+	imagePath     string
+	width, height int     // pixel size of the image, for --border
+	percent       float64 // share of the page the image fills, 0 for all of it
+	title         string
+	stamp         string // --date-stamp text, empty for no stamp
+	alt           string // --tagged alternate text of the image
+}
+
+// dividerTitleSize is the font size of the directory name on --sections divider pages
+const dividerTitleSize = 24
+
+// pageCol places an image filling its cell, a centered title for a divider, or an empty cell for a blank page.
+// With --border the image gets a frame on top.
+func pageCol(size int, p page, height float64, opts Options) core.Col {
+	if p.title != "" {
+		return text.NewCol(size, p.title, props.Text{
+			Top:   (height - dividerTitleSize*0.3528) / 2, // 1pt is 0.3528mm
+			Size:  dividerTitleSize,
+			Style: fontstyle.Bold,
+			Align: align.Center,
+			Color: pdfColor(textColor(opts.Background)),
+		})
+	}
+	if p.imagePath == "" {
+		return col.New(size)
+	}
+	percent := p.percent
+	if percent == 0 {
+		percent = 100 // Use full available space
+	}
+	image := marotoimage.NewFromFile(longPath(p.imagePath), props.Rect{
+		Center:  true,
+		Percent: percent,
+	})
+	components := []core.Component{image}
+	if opts.Border {
+		components = append(components, newImageFrame(p.width, p.height, percent, opts.BorderWidth, opts.BorderColor))
+	}
+	if p.stamp != "" {
+		components = append(components, newDateStamp(p.stamp, p.width, p.height, percent, opts.DateStampPosition))
+	}
+	return col.New(size).Add(components...)
+}
+
+// pageRow builds a row spanning a full sheet from the given columns
+func pageRow(height float64, background color.RGBA, cols ...core.Col) core.Row {
+	r := row.New(height).Add(cols...)
+	if background != colorWhite {
+		// Fills the areas the contained images leave uncovered
+		r.WithStyle(&props.Cell{BackgroundColor: pdfColor(background)})
+	}
+	return r
+}
+
+// rowHeightEpsilon is taken off the page height for the rows, in millimeters. maroto starts a new
+// page for a row taller than the space left, and the space it computes from the sheet height and
+// margins can round to a hair below the page height, which pushed every row onto a page of its own
+// behind a blank one.
+const rowHeightEpsilon = 1e-9
+
+// layoutRows turns the page list into one row per sheet side.
+// Booklets are padded with blank pages to a multiple of 4 and imposed two pages per side.
+func layoutRows(pages []page, height float64, opts Options) []core.Row {
+	height -= rowHeightEpsilon
+	if !opts.Booklet {
+		rows := make([]core.Row, len(pages))
+		for i, p := range pages {
+			rows[i] = pageRow(height, opts.Background, pageCol(12, p, height, opts))
+		}
+		return rows
+	}
+
+	padded := make([]page, bookletPageCount(len(pages)))
+	copy(padded, pages)
+	order := bookletOrder(len(padded))
+
+	rows := make([]core.Row, 0, len(padded)/2)
+	for i := 0; i < len(order); i += 2 {
+		left, right := padded[order[i]-1], padded[order[i+1]-1]
+		rows = append(rows, pageRow(height, opts.Background, pageCol(6, left, height, opts), pageCol(6, right, height, opts)))
+	}
+	return rows
+}
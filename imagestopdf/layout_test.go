@@ -0,0 +1,111 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestBookletOrder(t *testing.T) {
+	for _, tc := range []struct {
+		pages int
+		want  []int
+	}{
+		{4, []int{4, 1, 2, 3}},
+		{8, []int{8, 1, 2, 7, 6, 3, 4, 5}},
+		// Six pages are padded with two blank pages at the end
+		{6, []int{8, 1, 2, 7, 6, 3, 4, 5}},
+	} {
+		padded := bookletPageCount(tc.pages)
+		got := bookletOrder(padded)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%d pages: got %v, want %v", tc.pages, got, tc.want)
+		}
+
+		// Every page is placed exactly once
+		sorted := slices.Sorted(slices.Values(got))
+		for i, p := range sorted {
+			if p != i+1 {
+				t.Errorf("%d pages: order %v doesn't place every page once", tc.pages, got)
+				break
+			}
+		}
+	}
+}
+
+func TestBookletPageCount(t *testing.T) {
+	for pages, want := range map[int]int{1: 4, 4: 4, 5: 8, 6: 8, 8: 8, 9: 12} {
+		if got := bookletPageCount(pages); got != want {
+			t.Errorf("bookletPageCount(%d) = %d, want %d", pages, got, want)
+		}
+	}
+}
+
+func TestSheetSize(t *testing.T) {
+	for _, tc := range []struct {
+		name                         string
+		pageSize                     string
+		booklet                      bool
+		sheetW, sheetH, pageW, pageH float64
+	}{
+		{"auto", "auto", false, 120, 220, 100, 200},
+		{"auto booklet", "auto", true, 220, 220, 100, 200},
+		{"a4", "a4", false, 210, 297, 190, 277},
+		{"a4 booklet", "a4", true, 297, 210, 138.5, 190},
+	} {
+		sheetW, sheetH, pageW, pageH := sheetSize(tc.pageSize, tc.booklet, 100, 200, 10)
+		if sheetW != tc.sheetW || sheetH != tc.sheetH || pageW != tc.pageW || pageH != tc.pageH {
+			t.Errorf("%s: got sheet %gx%g page %gx%g, want sheet %gx%g page %gx%g", tc.name,
+				sheetW, sheetH, pageW, pageH, tc.sheetW, tc.sheetH, tc.pageW, tc.pageH)
+		}
+	}
+}
+
+// TestMarginOffset converts a 4:3 image with a margin and reads where it lands in the PDF: the
+// margin is in millimeters on auto and named page sizes alike, so the image starts that many
+// millimeters in from the left and bottom edges of the sheet
+func TestMarginOffset(t *testing.T) {
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "photo.png"), gradientImage(400, 300))
+
+	const points = 1 / mmPerPoint // points per millimeter
+	for _, tc := range []struct {
+		pageSize string
+		margin   float64
+		// want is the image placement and the sheet size in millimeters
+		want           pdfRect
+		sheetW, sheetH float64
+	}{
+		// 400x300 pixels at 200 DPI, the sheet grows by the margin on each side
+		{"auto", 10, pdfRect{10, 10, 144, 108}, 164, 128},
+		// Fitted into the 180x267 mm inside the margin and centered vertically
+		{"a4", 15, pdfRect{15, (297 - 135) / 2.0, 180, 135}, 210, 297},
+	} {
+		var buf bytes.Buffer
+		if _, err := Convert(context.Background(), &buf, Options{Inputs: []string{dir}, PageSize: tc.pageSize, Margin: tc.margin, Status: io.Discard}); err != nil {
+			t.Fatalf("%s: Convert: %v", tc.pageSize, err)
+		}
+		pdf := buf.Bytes()
+
+		dims := pdfPageDims(t, pdf)
+		if len(dims) != 1 || math.Abs(dims[0].Width-tc.sheetW*points) > 0.01 || math.Abs(dims[0].Height-tc.sheetH*points) > 0.01 {
+			t.Errorf("%s: got pages %v, want one of %.2fx%.2f points", tc.pageSize, dims, tc.sheetW*points, tc.sheetH*points)
+			continue
+		}
+		placed := imagePlacements(pdfPageContents(t, pdf)[0])
+		if len(placed) != 1 {
+			t.Fatalf("%s: %d image(s) placed, want 1", tc.pageSize, len(placed))
+		}
+		got, want := placed[0], tc.want
+		for _, v := range [][2]float64{{got.x, want.x}, {got.y, want.y}, {got.width, want.width}, {got.height, want.height}} {
+			if math.Abs(v[0]-v[1]*points) > 0.01 {
+				t.Errorf("%s: image placed at %+v points, want %+v mm", tc.pageSize, got, want)
+				break
+			}
+		}
+	}
+}
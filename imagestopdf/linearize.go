@@ -0,0 +1,76 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// optimizeForWeb deduplicates identical objects such as repeated images and, when qpdf is installed,
+// linearizes the document so viewers can show the first page before the whole file has loaded.
+// Each pass that fails is skipped with a warning, the input is returned if nothing succeeded.
+// Deterministic output skips the pdfcpu pass, which stamps the current time, and asks qpdf for
+// a file ID derived from the content.
+func optimizeForWeb(ctx context.Context, status io.Writer, data []byte, deterministic bool) []byte {
+	var optimized bytes.Buffer
+	if deterministic {
+		logger.Info("skipping PDF object deduplication for deterministic output")
+	} else if err := api.Optimize(bytes.NewReader(data), &optimized, nil); err != nil {
+		logger.Warn("could not optimize PDF, keeping unoptimized output", "error", err)
+	} else {
+		fmt.Fprintf(status, "Optimized PDF: %d KB → %d KB\n", len(data)/1024, optimized.Len()/1024)
+		data = optimized.Bytes()
+	}
+
+	qpdf, err := exec.LookPath("qpdf")
+	if err != nil {
+		logger.Warn("qpdf not found in PATH, the PDF is optimized but not linearized for fast web view")
+		return data
+	}
+
+	linearized, err := linearizeWithQPDF(ctx, qpdf, data, deterministic)
+	if err != nil {
+		logger.Warn("could not linearize PDF, keeping non-linearized output", "error", err)
+		return data
+	}
+	fmt.Fprintf(status, "Linearized PDF for fast web view\n")
+	return linearized
+}
+
+// linearizeWithQPDF runs the document through qpdf --linearize using temporary files
+func linearizeWithQPDF(ctx context.Context, qpdf string, data []byte, deterministic bool) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "images-to-pdf-linearize-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	inPath := filepath.Join(dir, "in.pdf")
+	outPath := filepath.Join(dir, "out.pdf")
+	if err := os.WriteFile(inPath, data, 0644); err != nil {
+		return nil, err
+	}
+
+	args := []string{"--linearize", "--object-streams=generate"}
+	if deterministic {
+		args = append(args, "--deterministic-id")
+	}
+	cmd := exec.CommandContext(ctx, qpdf, append(args, inPath, outPath)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		// Exit status 3 means qpdf succeeded but printed warnings
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+			return nil, fmt.Errorf("%v: %s", err, bytes.TrimSpace(output))
+		}
+	}
+
+	return os.ReadFile(outPath)
+}
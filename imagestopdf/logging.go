@@ -0,0 +1,45 @@
+package imagestopdf
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// logger receives diagnostics (warnings about skipped files, failed optional steps, debug details).
+// It writes to stderr so stdout stays free for progress output; --log-level and --log-format configure it
+var logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+var (
+	logLevel  string
+	logFormat string
+)
+
+// configureLogging replaces the logger according to --log-level and --log-format
+func configureLogging(level, format string) error {
+	var lvl slog.Level
+	switch strings.ToLower(level) {
+	case "debug":
+		lvl = slog.LevelDebug
+	case "info":
+		lvl = slog.LevelInfo
+	case "warn", "warning":
+		lvl = slog.LevelWarn
+	case "error":
+		lvl = slog.LevelError
+	default:
+		return fmt.Errorf("invalid log level %q, valid values are: debug, info, warn, error", level)
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "text":
+		logger = slog.New(slog.NewTextHandler(os.Stderr, handlerOpts))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, handlerOpts))
+	default:
+		return fmt.Errorf("invalid log format %q, valid values are: text, json", format)
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package imagestopdf
+
+// longPath is a no-op outside Windows, which is the only platform with a short path limit
+func longPath(path string) string {
+	return path
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package imagestopdf
+
+import "path/filepath"
+
+// longPath returns the extended-length form of paths too long for the Windows MAX_PATH limit,
+// shorter paths are returned unchanged
+func longPath(path string) string {
+	if len(path) < maxShortPath && filepath.IsAbs(path) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil || len(abs) < maxShortPath {
+		return path
+	}
+	return extendedLengthPath(abs)
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package imagestopdf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLongPath(t *testing.T) {
+	short := `C:\scans\page1.jpg`
+	if got := longPath(short); got != short {
+		t.Errorf("short path changed to %q", got)
+	}
+
+	long := `C:\Users\me\OneDrive` + strings.Repeat(`\nested folder`, 20) + `\page1.jpg`
+	if got := longPath(long); got != `\\?\`+long {
+		t.Errorf("longPath(%q) = %q", long, got)
+	}
+}
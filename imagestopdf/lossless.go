@@ -0,0 +1,110 @@
+package imagestopdf
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"strings"
+)
+
+// strategyValues are the accepted --strategy values
+var strategyValues = []string{"auto", "jpeg", "lossless", "quantize"}
+
+// validateStrategy checks the --strategy value
+func validateStrategy(strategy string) error {
+	for _, value := range strategyValues {
+		if strategy == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid strategy %q, valid values are: %s", strategy, strings.Join(strategyValues, ", "))
+}
+
+const (
+	// lineArtMinFlat is the share of identical neighboring pixels typical for screenshots and diagrams
+	lineArtMinFlat = 0.6
+	// lineArtMinSharp is the share of hard edges (large brightness jumps) needed alongside flat areas
+	lineArtMinSharp = 0.01
+	// lineArtSamples bounds how many pixels are inspected per image
+	lineArtSamples = 200000
+)
+
+// lineArtStats describes the sampled pixels of an image
+type lineArtStats struct {
+	colors int     // distinct colors among the samples
+	flat   float64 // share of horizontally adjacent samples with the same color
+	sharp  float64 // share of horizontally adjacent samples differing in brightness by more than a quarter
+}
+
+// isLineArt reports whether the image looks like a screenshot, diagram or scan of text, where JPEG's
+// ringing around hard edges is visible and lossless compression stays small. A few colors alone
+// don't make line art: smooth gradients and posterized photos have no hard edges.
+func (s lineArtStats) isLineArt() bool {
+	return s.flat >= lineArtMinFlat && s.sharp >= lineArtMinSharp
+}
+
+// analyzeLineArt samples rows of the image and counts colors, flat areas and hard edges
+func analyzeLineArt(img image.Image) lineArtStats {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 2 || height == 0 {
+		return lineArtStats{}
+	}
+
+	// Whole rows are sampled so neighboring pixels stay adjacent
+	rowStep := 1
+	if width*height > lineArtSamples {
+		rowStep = (width*height + lineArtSamples - 1) / lineArtSamples
+	}
+
+	sample := rgbaSampler(img)
+	colors := make(map[uint32]struct{})
+	var pairs, flat, sharp int
+	var px [4]byte
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += rowStep {
+		var prev uint32
+		var prevLuma int
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			sample(x, y, px[:])
+			c := uint32(px[0])<<16 | uint32(px[1])<<8 | uint32(px[2])
+			luma := (299*int(px[0]) + 587*int(px[1]) + 114*int(px[2])) / 1000
+			if len(colors) <= quantizeMaxSourceColors {
+				colors[c] = struct{}{}
+			}
+
+			if x > bounds.Min.X {
+				pairs++
+				if c == prev {
+					flat++
+				}
+				if d := luma - prevLuma; d > 64 || d < -64 {
+					sharp++
+				}
+			}
+			prev, prevLuma = c, luma
+		}
+	}
+
+	return lineArtStats{
+		colors: len(colors),
+		flat:   float64(flat) / float64(pairs),
+		sharp:  float64(sharp) / float64(pairs),
+	}
+}
+
+// encodeLosslessPNG writes the image as a maximally compressed PNG
+func encodeLosslessPNG(img image.Image, outputPath string) error {
+	outFile, err := os.Create(longPath(outputPath))
+	if err != nil {
+		return err
+	}
+
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(outFile, img); err != nil {
+		outFile.Close()
+		return err
+	}
+	return outFile.Close()
+}
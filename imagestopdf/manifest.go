@@ -0,0 +1,131 @@
+package imagestopdf
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultManifestPath is the --manifest value used when the flag is given without a path
+const defaultManifestPath = "<output>.manifest.json"
+
+// manifest is the sidecar file describing how source images map to PDF pages
+type manifest struct {
+	Output string         `json:"output"`
+	SHA256 string         `json:"sha256"`
+	Pages  []manifestPage `json:"pages"`
+}
+
+// manifestPage describes a single page of the generated PDF. Page is its number in reading order,
+// PDFPage the page of the PDF showing it, which differs for booklets.
+type manifestPage struct {
+	Page           int    `json:"page"`
+	PDFPage        int    `json:"pdf_page"`
+	Position       string `json:"position,omitempty"` // left or right half of a booklet sheet side
+	Blank          bool   `json:"blank,omitempty"`
+	Divider        bool   `json:"divider,omitempty"`
+	Section        string `json:"section,omitempty"`
+	Input          string `json:"input,omitempty"`
+	Source         string `json:"source,omitempty"`
+	SourceSHA256   string `json:"source_sha256,omitempty"`
+	OriginalWidth  int    `json:"original_width,omitempty"`
+	OriginalHeight int    `json:"original_height,omitempty"`
+	Width          int    `json:"width,omitempty"`
+	Height         int    `json:"height,omitempty"`
+	Strategy       string `json:"strategy,omitempty"`
+	OriginalBytes  int64  `json:"original_bytes,omitempty"`
+	Bytes          int64  `json:"bytes,omitempty"`
+
+	thumbnail []byte // JPEG preview for the HTML report, not part of the manifest
+}
+
+// newManifestPage builds the manifest entry for a page showing an optimized image
+func newManifestPage(page int, img optimizedImage) manifestPage {
+	return manifestPage{
+		Page:           page,
+		Input:          img.inputDir,
+		Source:         img.sourcePath,
+		SourceSHA256:   img.sourceSHA256,
+		OriginalWidth:  img.originalWidth,
+		OriginalHeight: img.originalHeight,
+		Width:          img.width,
+		Height:         img.height,
+		Strategy:       img.strategy,
+		OriginalBytes:  img.originalSize,
+		Bytes:          img.size,
+		thumbnail:      img.thumbnail,
+	}
+}
+
+// placePages fills in where each page ends up in the PDF: one page per PDF page, or for booklets
+// the sheet side and half bookletOrder imposes it on. pages must be in reading order, padded to a
+// multiple of 4 for booklets.
+func placePages(pages []manifestPage, booklet bool) {
+	if !booklet {
+		for i := range pages {
+			pages[i].PDFPage = pages[i].Page
+		}
+		return
+	}
+	for slot, n := range bookletOrder(len(pages)) {
+		pages[n-1].PDFPage = slot/2 + 1
+		pages[n-1].Position = "left"
+		if slot%2 == 1 {
+			pages[n-1].Position = "right"
+		}
+	}
+}
+
+// writeManifest writes the page manifest as CSV when the path ends in .csv and as JSON otherwise.
+// The CSV form has one row per page, so only the JSON form records the PDF's SHA-256.
+func writeManifest(path, outputPath, sum string, pages []manifestPage) error {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return writeManifestCSV(path, pages)
+	}
+
+	data, err := json.MarshalIndent(manifest{Output: outputPath, SHA256: sum, Pages: pages}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func writeManifestCSV(path string, pages []manifestPage) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.Write([]string{"page", "pdf_page", "position", "blank", "divider", "section", "input", "source", "source_sha256", "original_width", "original_height",
+		"width", "height", "strategy", "original_bytes", "bytes"})
+	for _, p := range pages {
+		w.Write([]string{
+			strconv.Itoa(p.Page),
+			strconv.Itoa(p.PDFPage),
+			p.Position,
+			strconv.FormatBool(p.Blank),
+			strconv.FormatBool(p.Divider),
+			p.Section,
+			p.Input,
+			p.Source,
+			p.SourceSHA256,
+			strconv.Itoa(p.OriginalWidth),
+			strconv.Itoa(p.OriginalHeight),
+			strconv.Itoa(p.Width),
+			strconv.Itoa(p.Height),
+			p.Strategy,
+			strconv.FormatInt(p.OriginalBytes, 10),
+			strconv.FormatInt(p.Bytes, 10),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
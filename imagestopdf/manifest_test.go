@@ -0,0 +1,90 @@
+package imagestopdf
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// numberedPages returns n manifest entries in reading order
+func numberedPages(n int) []manifestPage {
+	pages := make([]manifestPage, n)
+	for i := range pages {
+		pages[i].Page = i + 1
+	}
+	return pages
+}
+
+func TestPlacePages(t *testing.T) {
+	pages := numberedPages(3)
+	placePages(pages, false)
+	for _, p := range pages {
+		if p.PDFPage != p.Page || p.Position != "" {
+			t.Errorf("page %d placed on PDF page %d %q, want itself", p.Page, p.PDFPage, p.Position)
+		}
+	}
+
+	// Sheets of an 8 page booklet: 8,1 and 2,7 on the first, 6,3 and 4,5 on the second
+	want := map[int]struct {
+		pdfPage  int
+		position string
+	}{
+		8: {1, "left"}, 1: {1, "right"}, 2: {2, "left"}, 7: {2, "right"},
+		6: {3, "left"}, 3: {3, "right"}, 4: {4, "left"}, 5: {4, "right"},
+	}
+	pages = numberedPages(8)
+	placePages(pages, true)
+	for _, p := range pages {
+		if w := want[p.Page]; p.PDFPage != w.pdfPage || p.Position != w.position {
+			t.Errorf("booklet page %d placed on PDF page %d %q, want %d %q", p.Page, p.PDFPage, p.Position, w.pdfPage, w.position)
+		}
+	}
+}
+
+// TestWriteManifestBooklet writes a booklet's manifest in both forms and expects both numbers of
+// every page in it
+func TestWriteManifestBooklet(t *testing.T) {
+	pages := numberedPages(4)
+	placePages(pages, true)
+	dir := t.TempDir()
+
+	jsonPath := filepath.Join(dir, "manifest.json")
+	if err := writeManifest(jsonPath, "out.pdf", "", pages); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written manifest
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatal(err)
+	}
+	// The first page is the right half of the front of the sheet
+	if first := written.Pages[0]; first.Page != 1 || first.PDFPage != 1 || first.Position != "right" {
+		t.Errorf("JSON manifest has page 1 as %+v, want PDF page 1, right", first)
+	}
+
+	csvPath := filepath.Join(dir, "manifest.csv")
+	if err := writeManifest(csvPath, "out.pdf", "", pages); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header := records[0][:3]; header[0] != "page" || header[1] != "pdf_page" || header[2] != "position" {
+		t.Errorf("CSV header starts with %v", header)
+	}
+	// Page 3 goes on the back of the sheet, right of page 2
+	if row := records[3][:3]; row[0] != "3" || row[1] != "2" || row[2] != "right" {
+		t.Errorf("CSV row of page 3 is %v, want 3, 2, right", row)
+	}
+}
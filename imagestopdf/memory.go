@@ -0,0 +1,97 @@
+package imagestopdf
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"sync"
+)
+
+// memoryBudget is a weighted semaphore over bytes of decoded image data. Waiters are served in
+// arrival order so a large image isn't starved by a stream of small ones.
+type memoryBudget struct {
+	mu      sync.Mutex
+	size    int64
+	used    int64
+	waiters []budgetWaiter
+}
+
+type budgetWaiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+// newMemoryBudget creates a budget of size bytes
+func newMemoryBudget(size int64) *memoryBudget {
+	return &memoryBudget{size: size}
+}
+
+// acquire blocks until n bytes are available and returns the amount actually reserved, which is
+// capped at the budget size: an image larger than the whole budget runs alone instead of failing
+func (b *memoryBudget) acquire(ctx context.Context, n int64) (int64, error) {
+	n = min(n, b.size)
+
+	b.mu.Lock()
+	if len(b.waiters) == 0 && b.used+n <= b.size {
+		b.used += n
+		b.mu.Unlock()
+		return n, nil
+	}
+	w := budgetWaiter{n: n, ready: make(chan struct{})}
+	b.waiters = append(b.waiters, w)
+	b.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return n, nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		select {
+		case <-w.ready:
+			// Granted while cancelling, hand it back
+			b.used -= n
+			b.notify()
+		default:
+			for i, waiter := range b.waiters {
+				if waiter.ready == w.ready {
+					b.waiters = append(b.waiters[:i], b.waiters[i+1:]...)
+					break
+				}
+			}
+			b.notify()
+		}
+		return 0, ctx.Err()
+	}
+}
+
+// release returns n bytes obtained from acquire
+func (b *memoryBudget) release(n int64) {
+	b.mu.Lock()
+	b.used -= n
+	b.notify()
+	b.mu.Unlock()
+}
+
+// notify wakes waiters from the front of the queue while they fit, b.mu must be held
+func (b *memoryBudget) notify() {
+	for len(b.waiters) > 0 {
+		next := b.waiters[0]
+		if b.used+next.n > b.size {
+			return
+		}
+		b.used += next.n
+		b.waiters = b.waiters[1:]
+		close(next.ready)
+	}
+}
+
+// decodedSize estimates the memory a decoded image occupies
+func decodedSize(cfg image.Config) int64 {
+	bytesPerPixel := int64(4)
+	switch cfg.ColorModel {
+	case color.RGBA64Model, color.NRGBA64Model:
+		bytesPerPixel = 8
+	}
+	return int64(cfg.Width) * int64(cfg.Height) * bytesPerPixel
+}
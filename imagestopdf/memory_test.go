@@ -0,0 +1,119 @@
+package imagestopdf
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// waitForWaiters blocks until n reservations are queued on the budget
+func waitForWaiters(t *testing.T, b *memoryBudget, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b.mu.Lock()
+		queued := len(b.waiters)
+		b.mu.Unlock()
+		if queued == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d reservation(s) queued, want %d", queued, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestMemoryBudgetOrdering checks concurrent reservations block while the budget is used up and
+// are granted in arrival order as it is released, even when a later one would fit sooner
+func TestMemoryBudgetOrdering(t *testing.T) {
+	ctx := context.Background()
+	budget := newMemoryBudget(10)
+
+	first, err := budget.acquire(ctx, 6)
+	if err != nil || first != 6 {
+		t.Fatalf("first reservation: %d, %v", first, err)
+	}
+
+	granted := make(chan string, 2)
+	reserve := func(name string, n int64) {
+		reserved, err := budget.acquire(ctx, n)
+		if err != nil || reserved != n {
+			t.Errorf("%s: reserved %d, %v", name, reserved, err)
+		}
+		granted <- name
+	}
+	go reserve("large", 8)
+	waitForWaiters(t, budget, 1)
+	// 4 bytes are free, but the small reservation queues behind the large one
+	go reserve("small", 3)
+	waitForWaiters(t, budget, 2)
+
+	select {
+	case name := <-granted:
+		t.Fatalf("%s was granted while the budget was used up", name)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	budget.release(first)
+	if name := <-granted; name != "large" {
+		t.Fatalf("%s was granted first, want large", name)
+	}
+	select {
+	case name := <-granted:
+		t.Fatalf("%s was granted while large holds 8 of 10 bytes", name)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	budget.release(8)
+	if name := <-granted; name != "small" {
+		t.Fatalf("%s was granted, want small", name)
+	}
+	budget.release(3)
+	if budget.used != 0 {
+		t.Errorf("%d bytes still reserved", budget.used)
+	}
+}
+
+// TestMemoryBudgetOversized checks an image larger than the budget runs alone instead of failing
+func TestMemoryBudgetOversized(t *testing.T) {
+	budget := newMemoryBudget(10)
+	reserved, err := budget.acquire(context.Background(), 25)
+	if err != nil || reserved != 10 {
+		t.Fatalf("reserved %d, %v; want the whole budget", reserved, err)
+	}
+	budget.release(reserved)
+}
+
+// TestMemoryBudgetCancel checks a cancelled reservation leaves the queue and doesn't hold up the next
+func TestMemoryBudgetCancel(t *testing.T) {
+	budget := newMemoryBudget(10)
+	held, _ := budget.acquire(context.Background(), 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() {
+		_, err := budget.acquire(ctx, 8)
+		cancelled <- err
+	}()
+	waitForWaiters(t, budget, 1)
+
+	granted := make(chan struct{})
+	go func() {
+		if _, err := budget.acquire(context.Background(), 2); err == nil {
+			close(granted)
+		}
+	}()
+	waitForWaiters(t, budget, 2)
+
+	cancel()
+	if err := <-cancelled; err == nil {
+		t.Fatal("a cancelled reservation returned no error")
+	}
+	budget.release(held)
+	select {
+	case <-granted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the reservation behind the cancelled one was never granted")
+	}
+}
@@ -0,0 +1,186 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mergeOutput    string
+	mergeBookmarks bool
+	mergeLinearize bool
+)
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge <pdf>... -o <output.pdf>",
+	Short: "Combine PDFs into one, in the order given",
+	Long: `Concatenates the PDFs in the order given, keeping every page at its own size. Each input is read
+and validated first: invalid or encrypted files are listed and nothing is written.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := notifyInterrupt()
+		err := mergePDFs(ctx, args, mergeOutput, mergeBookmarks, mergeLinearize)
+		interrupted := ctx.Err() != nil
+		stop()
+		if err != nil && interrupted {
+			fmt.Fprintln(os.Stderr, "Interrupted, no PDF was written")
+			os.Exit(exitCodeInterrupted)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func init() {
+	flags := mergeCmd.Flags()
+	flags.StringVarP(&mergeOutput, "output", "o", "", "Path of the merged PDF")
+	flags.BoolVar(&mergeBookmarks, "bookmarks", false, "Give each input a top-level bookmark named after its file, holding its own bookmarks")
+	flags.BoolVar(&mergeLinearize, "linearize", false, "Deduplicate objects and linearize the merged PDF like --linearize does for conversions")
+	mergeCmd.MarkFlagRequired("output")
+	rootCmd.AddCommand(mergeCmd)
+}
+
+// mergeInput is a PDF read for merging
+type mergeInput struct {
+	path      string
+	data      []byte
+	pageCount int
+}
+
+// readMergeInput reads and validates a PDF to merge. Encrypted files are rejected, even those
+// that open without a password: merging would drop their encryption.
+func readMergeInput(path string) (mergeInput, error) {
+	data, err := os.ReadFile(longPath(path))
+	if err != nil {
+		return mergeInput{}, err
+	}
+	pdf, err := api.ReadContext(bytes.NewReader(data), nil)
+	if errors.Is(err, pdfcpu.ErrWrongPassword) {
+		return mergeInput{}, fmt.Errorf("encrypted with a password, decrypt it first")
+	}
+	if err != nil {
+		return mergeInput{}, fmt.Errorf("not a readable PDF: %v", err)
+	}
+	if pdf.Encrypt != nil {
+		return mergeInput{}, fmt.Errorf("encrypted, decrypt it first")
+	}
+	if err := api.ValidateContext(pdf); err != nil {
+		return mergeInput{}, fmt.Errorf("invalid PDF: %v", err)
+	}
+	return mergeInput{path: path, data: data, pageCount: pdf.PageCount}, nil
+}
+
+// mergeBookmarkTree returns a top-level bookmark per input, named after its file and holding the
+// input's own bookmarks moved to where its pages start in the merged document
+func mergeBookmarkTree(inputs []mergeInput) []pdfcpu.Bookmark {
+	var shift func(bookmarks []pdfcpu.Bookmark, offset int) []pdfcpu.Bookmark
+	shift = func(bookmarks []pdfcpu.Bookmark, offset int) []pdfcpu.Bookmark {
+		shifted := make([]pdfcpu.Bookmark, len(bookmarks))
+		for i, bookmark := range bookmarks {
+			shifted[i] = pdfcpu.Bookmark{
+				Title: bookmark.Title, PageFrom: bookmark.PageFrom + offset,
+				Bold: bookmark.Bold, Italic: bookmark.Italic, Color: bookmark.Color,
+				Kids: shift(bookmark.Kids, offset),
+			}
+		}
+		return shifted
+	}
+
+	var tree []pdfcpu.Bookmark
+	offset := 0
+	for _, input := range inputs {
+		own, err := api.Bookmarks(bytes.NewReader(input.data), nil)
+		if err != nil && !errors.Is(err, api.ErrNoOutlines) {
+			logger.Warn("could not read bookmarks, the file gets only its own entry", "path", input.path, "error", err)
+		}
+		tree = append(tree, pdfcpu.Bookmark{
+			Title:    filepath.Base(input.path),
+			PageFrom: offset + 1,
+			Kids:     shift(own, offset),
+		})
+		offset += input.pageCount
+	}
+	return tree
+}
+
+// mergePDFs concatenates the PDFs at paths into outputPath. Every input is checked before merging,
+// so a bad file fails the command without writing anything, listing all bad files at once.
+func mergePDFs(ctx context.Context, paths []string, outputPath string, bookmarks, linearize bool) error {
+	var inputs []mergeInput
+	failed := 0
+	pages := 0
+	for _, path := range paths {
+		input, err := readMergeInput(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed++
+			continue
+		}
+		inputs = append(inputs, input)
+		pages += input.pageCount
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d input(s) can't be merged, nothing was written", failed, len(paths))
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fmt.Printf("Merging %d PDFs with %d pages in total\n", len(inputs), pages)
+
+	readers := make([]io.ReadSeeker, len(inputs))
+	for i, input := range inputs {
+		readers[i] = bytes.NewReader(input.data)
+	}
+	var merged bytes.Buffer
+	if err := api.MergeRaw(readers, &merged, false, nil); err != nil {
+		return fmt.Errorf("failed to merge PDFs: %v", err)
+	}
+	data := merged.Bytes()
+
+	// Replaces the outline the merge carried over from the inputs
+	if bookmarks {
+		var withBookmarks bytes.Buffer
+		if err := api.AddBookmarks(bytes.NewReader(data), &withBookmarks, mergeBookmarkTree(inputs), true, nil); err != nil {
+			return fmt.Errorf("failed to add bookmarks: %v", err)
+		}
+		data = withBookmarks.Bytes()
+		fmt.Printf("Added a bookmark for each of the %d inputs\n", len(inputs))
+	}
+	if linearize {
+		data = optimizeForWeb(ctx, statusWriter(os.Stdout), data, false)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Renamed into place like a converted PDF, so a failed write never replaces a good file
+	if dir := filepath.Dir(outputPath); dir != "." {
+		if err := os.MkdirAll(longPath(dir), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %v", err)
+		}
+	}
+	tmpPath := outputPath + ".partial"
+	defer removeOnForcedExit(tmpPath)()
+	defer os.Remove(tmpPath) // no-op once renamed
+	if err := os.WriteFile(longPath(tmpPath), data, 0644); err != nil {
+		return fmt.Errorf("%w to %s: %v", ErrSaveFailed, outputPath, err)
+	}
+	if err := os.Rename(longPath(tmpPath), longPath(outputPath)); err != nil {
+		return fmt.Errorf("%w to %s: %v", ErrSaveFailed, outputPath, err)
+	}
+
+	fmt.Printf("PDF file size: %.2f MB\n", megabytes(int64(len(data))))
+	fmt.Printf("Successfully merged %d PDFs into %s\n", len(inputs), outputPath)
+	return nil
+}
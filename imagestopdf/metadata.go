@@ -0,0 +1,27 @@
+package imagestopdf
+
+import "bytes"
+
+// stripJPEGMetadata removes EXIF, XMP (APP1) and IPTC (APP13) segments from a JPEG file.
+// Other segments, including the APP2 ICC profile and the APP14 Adobe color transform, are kept.
+func stripJPEGMetadata(data []byte) []byte {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return data
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(data))
+	buf.Write(data[:2])
+
+	pos := 2
+	for _, seg := range jpegSegments(data) {
+		buf.Write(data[pos:seg.start]) // fill bytes between segments
+		if seg.marker != 0xE1 && seg.marker != 0xED {
+			buf.Write(data[seg.start:seg.end])
+		}
+		pos = seg.end
+	}
+	buf.Write(data[pos:])
+
+	return buf.Bytes()
+}
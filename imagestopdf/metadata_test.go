@@ -0,0 +1,81 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"image/color"
+	"image/jpeg"
+	"path/filepath"
+	"testing"
+)
+
+// hasSegment reports whether a JPEG has a segment with the given marker
+func hasSegment(data []byte, marker byte) bool {
+	for _, seg := range jpegSegments(data) {
+		if seg.marker == marker {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStripJPEGMetadata(t *testing.T) {
+	source := encodeJPEG(t, solidImage(16, 16, color.RGBA{200, 100, 50, 255}), 90)
+	data := withAPPSegment(source, 0xE1, exifPayload(true))
+	data = withAPPSegment(data, 0xED, []byte("Photoshop 3.0\x00"))
+	data = withICCProfile(data, matrixProfile("RGB", &srgbColorants))
+
+	stripped := stripJPEGMetadata(data)
+	if hasSegment(stripped, 0xE1) || hasSegment(stripped, 0xED) {
+		t.Error("APP1 or APP13 survived stripping")
+	}
+	if !hasSegment(stripped, 0xE2) {
+		t.Error("the APP2 ICC profile was stripped")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("stripped JPEG doesn't decode: %v", err)
+	}
+	if !bytes.Equal(stripJPEGMetadata(source), source) {
+		t.Error("a JPEG without metadata changed")
+	}
+}
+
+// TestEmbeddedImagesCarryNoGPS checks both the copied and the re-encoded path leave no APP1
+// segment in the PDF, and that the orientation is applied before the EXIF data goes
+func TestEmbeddedImagesCarryNoGPS(t *testing.T) {
+	source := encodeJPEG(t, photoImage(300, 200, 1), 90)
+	upright := exifEntry{tag: 0x0112, kind: 3, count: 1, value: 1}
+	rotated := exifEntry{tag: 0x0112, kind: 3, count: 1, value: 6} // displayed turned clockwise
+
+	for _, tc := range []struct {
+		name                  string
+		orientation           exifEntry
+		rotate                int
+		wantWidth, wantHeight int
+	}{
+		{"copied", upright, 0, 300, 200},
+		{"re-encoded", upright, 180, 300, 200},
+		{"oriented", rotated, 0, 200, 300},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "gps.jpg"), withAPPSegment(source, 0xE1, exifPayload(true, tc.orientation)))
+
+			pdf := convertForTest(t, Options{Inputs: []string{dir}, StripMetadata: true, Rotate: tc.rotate})
+			pages := pdfImages(t, pdf)
+			if len(pages) != 1 || len(pages[0]) != 1 {
+				t.Fatalf("expected one image on one page, got %d page(s)", len(pages))
+			}
+			embedded := readAll(t, pages[0][0])
+			if hasSegment(embedded, 0xE1) || bytes.Contains(embedded, []byte(exifHeader)) {
+				t.Error("the embedded image still carries EXIF data")
+			}
+			cfg, err := jpeg.DecodeConfig(bytes.NewReader(embedded))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Width != tc.wantWidth || cfg.Height != tc.wantHeight {
+				t.Errorf("embedded image is %dx%d, want %dx%d", cfg.Width, cfg.Height, tc.wantWidth, tc.wantHeight)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package imagestopdf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// namePlaceholders are the placeholders supported in the --name value
+var namePlaceholders = []string{"date", "time", "dir", "count", "n"}
+
+var placeholderPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// validateNameTemplate checks that the output name only uses known placeholders
+func validateNameTemplate(name string) error {
+	for _, match := range placeholderPattern.FindAllStringSubmatch(name, -1) {
+		valid := false
+		for _, placeholder := range namePlaceholders {
+			if match[1] == placeholder {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("invalid placeholder %s in output name %q, valid placeholders are: {%s}",
+				match[0], name, strings.Join(namePlaceholders, "}, {"))
+		}
+	}
+	return nil
+}
+
+// expandNameTemplate fills in the output name placeholders. {n} is replaced by the
+// smallest number, starting at 1, that doesn't collide with an existing file in outputDir.
+func expandNameTemplate(name, inputDir, outputDir string, pageCount int, now time.Time) string {
+	dirName := filepath.Base(inputDir)
+	if absDir, err := filepath.Abs(inputDir); err == nil {
+		dirName = filepath.Base(absDir)
+	}
+
+	replacer := strings.NewReplacer(
+		"{date}", now.Format("2006-01-02"),
+		"{time}", now.Format("150405"),
+		"{dir}", sanitizeNameValue(dirName),
+		"{count}", strconv.Itoa(pageCount),
+	)
+	expanded := replacer.Replace(name)
+
+	if !strings.Contains(expanded, "{n}") {
+		return expanded
+	}
+	for n := 1; ; n++ {
+		candidate := strings.ReplaceAll(expanded, "{n}", strconv.Itoa(n))
+		if _, err := os.Stat(filepath.Join(outputDir, candidate)); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+// sanitizeNameValue keeps a substituted value from introducing path separators or parent references
+func sanitizeNameValue(value string) string {
+	value = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':':
+			return '_'
+		}
+		return r
+	}, value)
+	if value == "." || value == ".." {
+		return "_"
+	}
+	return value
+}
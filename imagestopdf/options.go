@@ -0,0 +1,389 @@
+package imagestopdf
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"time"
+)
+
+// Options configures a conversion run. The CLI flags and the serve endpoint's form fields both map onto it.
+type Options struct {
+	Inputs    []string // directories and single image files, combined into one sorted list
+	InputDir2 string   // optional second input interleaved with the first (duplex backs)
+	OutputDir string
+	Name      string // output file name, may contain placeholders (see expandNameTemplate)
+
+	NoOverwrite bool // fail with ErrOutputExists instead of replacing an existing PDF
+
+	BatchSize   int  // images per output PDF, 0 puts all of them in one
+	Resume      bool // skip batches whose PDF is already up to date, see batchDone
+	OnePerImage bool // a PDF per image named after it, see convertOnePerImage
+	Interactive bool // print the plan and ask before converting, see printPlan
+
+	Diff          bool // list the source images changed since the previous manifest, see checkChanges
+	SkipUnchanged bool // don't rebuild the PDF when no source image changed
+
+	batch []string // the images of the current batch, replacing discovery
+
+	Interleave string // order of InputDir2 pages: reverse or forward
+	Strict     bool   // fail instead of padding when inputs don't line up
+
+	DPI     float64 // resolution used to turn pixel dimensions into page size
+	Quality int     // JPEG quality for re-encoded images, 0 picks it per image
+
+	Strategy string // auto, jpeg, lossless or quantize, chooses how images that are not kept as-is get re-encoded
+
+	QuantizeColors int  // palette size for quantized PNGs, at most 256
+	QuantizeDither bool // Floyd-Steinberg dithering when quantizing
+
+	MaxMemory int64 // bytes of decoded image data held at once, see memoryBudget
+
+	MaxDecodePixels int64         // larger images use their EXIF thumbnail or are skipped, 0 disables the check
+	DecodeTimeout   time.Duration // longer decodes are abandoned and the image skipped, 0 disables the timeout
+
+	MaxSize    int64  // byte budget of the PDF, 0 only reports against the default 3 MB target
+	BudgetMode string // per-image gives every JPEG an equal share of MaxSize, global spreads it by complexity
+
+	imageBudget int64 // MaxSize divided by the number of images in per-image mode
+
+	Retries int // extra attempts for reads failing with transient I/O errors, 0 disables retrying
+
+	Sharpen       bool    // unsharp mask after downscaling
+	SharpenAmount float64 // weight of the edge difference added back by Sharpen
+
+	Dither     bool       // ordered dithering when reducing 16-bit images to 8 bits
+	Background color.RGBA // flattening color for transparency and fill around contained images
+
+	ConvertSRGB   bool
+	StripMetadata bool
+
+	Linearize bool // deduplicate and linearize the generated PDF for fast web view
+
+	Tagged  bool   // tagged PDF with a Figure and alternate text per image, see tagPDF
+	AltFile string // alternate texts by image name for Tagged, see loadAltTexts
+	Lang    string // BCP-47 document language of Tagged output
+
+	Deterministic bool   // byte-identical output for identical input, see normalizeDocument
+	Date          string // fixed document date for Deterministic, see documentDate
+
+	SkipBlank      bool
+	BlankThreshold float64 // percentage of background above which --skip-blank drops a page
+	BlankTolerance int     // brightness difference still counted as background
+
+	ContentFit     bool    // crop images to their content, see contentBox
+	ContentPadding float64 // millimeters of paper kept around the content
+
+	DateStamp         bool   // print the capture date in a corner of each image, see dateStamp
+	DateStampPosition string // corner of the stamp, see dateStampPositions
+	DateStampFormat   string // Go time layout of the stamp
+
+	BlankAfterOdd   bool
+	InsertBlankFile string
+
+	ManifestPath string
+	Checksum     bool   // write <output>.sha256 next to the PDF
+	ReportPath   string // HTML overview written next to the PDF
+
+	Sections       bool // group pages by directory with divider pages and bookmarks
+	NoDividerPages bool // keep the section bookmarks but leave out the divider pages
+
+	NoIgnoreFiles bool // include images listed in .pdfignore files
+
+	Sort                string // name (byte order of the path) or natural (numbers compared by value), see newPathComparator
+	SortCaseInsensitive bool
+	CollateLocale       string
+
+	StdinTar bool   // read the images from a tar stream on stdin instead of Inputs
+	TarOrder string // sorted applies the normal sort to the extracted images, archive keeps the entry order
+
+	OrderFile string // images listed here come first, in this order
+	EmitOrder string // write the computed page order here for editing
+
+	PageBasis    string
+	UseSourceDPI bool   // size pages from each image's declared density instead of its pixels at DPI
+	PageSize     string // auto or a named paper size, see paperSizes
+	Booklet      bool   // impose two pages per sheet in saddle-stitch order
+
+	Margin      float64 // millimeters of blank paper around each sheet
+	Border      bool    // frame every image, see imageFrame
+	BorderWidth float64 // millimeters
+	BorderColor color.RGBA
+
+	RotateFile string
+	Rotate     int
+
+	// Progress, when set, is called before each image is handled in every stage.
+	// Calls are made one at a time from the goroutine running the conversion.
+	Progress ProgressFunc
+	// Status receives the progress and status lines, stdout when nil. io.Discard silences them.
+	Status io.Writer
+}
+
+// Stages reported to a ProgressFunc
+const (
+	StageDiscovery = "discovery"
+	StageOptimize  = "optimize"
+	StageAssemble  = "assemble"
+)
+
+// ProgressFunc receives the stage, the 1-based index of the current image, the number of images in
+// the stage and the source file name. Returning an error stops the conversion with that error.
+type ProgressFunc func(stage string, current, total int, filename string) error
+
+// Result describes a finished conversion
+type Result struct {
+	Pages  int           // including inserted blank pages
+	Images []ImageResult // the embedded images, in page order
+}
+
+// ImageResult describes how a source image was embedded
+type ImageResult struct {
+	Source   string // path of the source file
+	Strategy string // compression strategy chosen for it, like optimize_jpeg or quantize_png
+	Width    int    // embedded size in pixels
+	Height   int
+	Bytes    int64 // size of the embedded image data
+}
+
+// defaultOptions returns the settings used when nothing else is specified
+func defaultOptions() Options {
+	return Options{
+		OutputDir:         ".",
+		Name:              "images.pdf",
+		DPI:               200,
+		StripMetadata:     true,
+		PageBasis:         "mean",
+		Strategy:          "auto",
+		QuantizeColors:    256,
+		Background:        colorWhite,
+		Interleave:        "reverse",
+		TarOrder:          "sorted",
+		Sort:              "name",
+		PageSize:          "auto",
+		BorderWidth:       mmPerPoint,
+		BorderColor:       colorBlack,
+		MaxMemory:         1 << 30,
+		MaxDecodePixels:   150_000_000,
+		DecodeTimeout:     60 * time.Second,
+		BudgetMode:        "per-image",
+		Retries:           2,
+		SharpenAmount:     0.5,
+		BlankThreshold:    99.5,
+		BlankTolerance:    24,
+		ContentPadding:    5,
+		DateStampPosition: "bottom-right",
+		DateStampFormat:   "2006-01-02",
+		Lang:              "en",
+	}
+}
+
+// validate rejects option values that would fail later in the run
+func (o Options) validate() error {
+	if len(o.Inputs) == 0 && !o.StdinTar {
+		return fmt.Errorf("no input given, pass a directory or image file")
+	}
+	if err := validatePageBasis(o.PageBasis); err != nil {
+		return err
+	}
+	if err := validateRotation(o.Rotate); err != nil {
+		return err
+	}
+	if o.Quality < 0 || o.Quality > 100 {
+		return fmt.Errorf("invalid JPEG quality %d, must be between 1 and 100 (or 0 for automatic)", o.Quality)
+	}
+	if err := validatePageSize(o.PageSize); err != nil {
+		return err
+	}
+	if err := validateDateStamp(o); err != nil {
+		return err
+	}
+	if err := validateOnePerImage(o); err != nil {
+		return err
+	}
+	if err := validateStdio(o); err != nil {
+		return err
+	}
+	if err := validateInteractive(o); err != nil {
+		return err
+	}
+	if err := validateDiff(o); err != nil {
+		return err
+	}
+	if err := validateTagged(o); err != nil {
+		return err
+	}
+	if err := validateMargin(o); err != nil {
+		return err
+	}
+	if err := validateStrategy(o.Strategy); err != nil {
+		return err
+	}
+	if err := validateSort(o.Sort); err != nil {
+		return err
+	}
+	if err := validateInterleave(o); err != nil {
+		return err
+	}
+	if o.Sections && o.InputDir2 != "" {
+		return fmt.Errorf("--sections can't be combined with --input2, interleaved pages don't form directory sections")
+	}
+	if o.BlankThreshold <= 0 || o.BlankThreshold > 100 {
+		return fmt.Errorf("invalid blank threshold %g, must be a percentage above 0 and at most 100", o.BlankThreshold)
+	}
+	if o.BlankTolerance < 0 || o.BlankTolerance > 255 {
+		return fmt.Errorf("invalid blank tolerance %d, must be between 0 and 255", o.BlankTolerance)
+	}
+	if o.ContentPadding < 0 {
+		return fmt.Errorf("invalid content padding %gmm, must not be negative", o.ContentPadding)
+	}
+	if o.QuantizeColors < 2 || o.QuantizeColors > 256 {
+		return fmt.Errorf("invalid palette size %d, must be between 2 and 256", o.QuantizeColors)
+	}
+	if o.SharpenAmount <= 0 || o.SharpenAmount > 5 {
+		return fmt.Errorf("invalid sharpen amount %g, must be above 0 and at most 5", o.SharpenAmount)
+	}
+	if o.Date != "" && !o.Deterministic {
+		return fmt.Errorf("--date only applies to --deterministic output")
+	}
+	if o.Deterministic {
+		if _, err := o.documentDate(); err != nil {
+			return err
+		}
+	}
+	if o.MaxDecodePixels < 0 {
+		return fmt.Errorf("invalid decode pixel limit %d, must not be negative", o.MaxDecodePixels)
+	}
+	if o.DecodeTimeout < 0 {
+		return fmt.Errorf("invalid decode timeout %s, must not be negative", o.DecodeTimeout)
+	}
+	if err := validateBudgetMode(o); err != nil {
+		return err
+	}
+	if err := validateBatches(o); err != nil {
+		return err
+	}
+	if o.Retries < 0 {
+		return fmt.Errorf("invalid retry count %d, must not be negative", o.Retries)
+	}
+	if o.MaxMemory <= 0 {
+		return fmt.Errorf("invalid memory budget %d, must be positive", o.MaxMemory)
+	}
+	if o.DPI <= 0 {
+		return fmt.Errorf("invalid DPI %g, must be positive", o.DPI)
+	}
+	return nil
+}
+
+// report passes progress to the callback, if any
+func (o Options) report(stage string, current, total int, filename string) error {
+	if o.Progress == nil {
+		return nil
+	}
+	if err := o.Progress(stage, current, total, filename); err != nil {
+		return fmt.Errorf("stopped by progress callback during %s: %w", stage, err)
+	}
+	return nil
+}
+
+// Convert combines the images in opts.Inputs into a single PDF written to w.
+//
+// Zero values for DPI, memory budget, size budget mode, page basis, page size, strategy, palette
+// size, interleave mode, blank detection, sharpen amount, background, border style and date stamp
+// style fall back to the defaults. Booleans such as StripMetadata are used as given: false
+// turns them off even where the command defaults to on.
+//
+// OutputDir, Name, ManifestPath, ReportPath, Checksum, BatchSize, Resume, OnePerImage,
+// Interactive, Diff, SkipUnchanged and StdinTar belong to the command and are not used.
+//
+// Cancelling ctx stops the run between images.
+func Convert(ctx context.Context, w io.Writer, opts Options) (Result, error) {
+	defaults := defaultOptions()
+	if opts.DPI == 0 {
+		opts.DPI = defaults.DPI
+	}
+	if opts.PageBasis == "" {
+		opts.PageBasis = defaults.PageBasis
+	}
+	if opts.Strategy == "" {
+		opts.Strategy = defaults.Strategy
+	}
+	if opts.PageSize == "" {
+		opts.PageSize = defaults.PageSize
+	}
+	if opts.MaxMemory == 0 {
+		opts.MaxMemory = defaults.MaxMemory
+	}
+	if opts.BudgetMode == "" {
+		opts.BudgetMode = defaults.BudgetMode
+	}
+	if opts.BlankThreshold == 0 {
+		opts.BlankThreshold = defaults.BlankThreshold
+	}
+	if opts.SharpenAmount == 0 {
+		opts.SharpenAmount = defaults.SharpenAmount
+	}
+	if opts.QuantizeColors == 0 {
+		opts.QuantizeColors = defaults.QuantizeColors
+	}
+	if opts.BlankTolerance == 0 {
+		opts.BlankTolerance = defaults.BlankTolerance
+	}
+	if opts.Interleave == "" {
+		opts.Interleave = defaults.Interleave
+	}
+	if opts.TarOrder == "" {
+		opts.TarOrder = defaults.TarOrder
+	}
+	if opts.Sort == "" {
+		opts.Sort = defaults.Sort
+	}
+	if opts.DateStampPosition == "" {
+		opts.DateStampPosition = defaults.DateStampPosition
+	}
+	if opts.DateStampFormat == "" {
+		opts.DateStampFormat = defaults.DateStampFormat
+	}
+	if opts.Background == (color.RGBA{}) {
+		opts.Background = defaults.Background
+	}
+	if opts.BorderWidth == 0 {
+		opts.BorderWidth = defaults.BorderWidth
+	}
+	if opts.BorderColor == (color.RGBA{}) {
+		opts.BorderColor = defaults.BorderColor
+	}
+	if err := opts.validate(); err != nil {
+		return Result{}, err
+	}
+
+	// Nobody is there to answer the prompts
+	opts.Interactive = false
+
+	tempDir, err := os.MkdirTemp("", "images-to-pdf-")
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer cleanupConvertedImages(opts.status(), tempDir)
+
+	result, err := buildPDF(ctx, opts, tempDir)
+	if err != nil {
+		return Result{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	if _, err := w.Write(result.data); err != nil {
+		return Result{}, fmt.Errorf("failed to write PDF: %v", err)
+	}
+
+	images := make([]ImageResult, 0, len(result.pages))
+	for _, p := range result.pages {
+		if p.Source != "" {
+			images = append(images, ImageResult{Source: p.Source, Strategy: p.Strategy, Width: p.Width, Height: p.Height, Bytes: p.Bytes})
+		}
+	}
+	return Result{Pages: result.pageCount, Images: images}, nil
+}
@@ -0,0 +1,120 @@
+package imagestopdf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// orderEntry is one file name of an --order-file and the line it is on
+type orderEntry struct {
+	name string
+	line int
+}
+
+// loadOrderFile reads an order file, one file name per line, either a base name or a path
+// relative to the input directory. Blank lines and lines starting with # are skipped.
+func loadOrderFile(path string) ([]orderEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []orderEntry
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, orderEntry{name: filepath.ToSlash(filepath.Clean(line)), line: lineNum})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return entries, nil
+}
+
+// applyOrderFile moves the images listed in the order file to the front, in the listed order.
+// The others follow in their current order. A listed file that exists but was not discovered,
+// because a .pdfignore or the file type excluded it, is only warned about; one that doesn't exist
+// at all is an error pointing at its line.
+func applyOrderFile(status io.Writer, imageFiles []string, path, inputDir string) ([]string, error) {
+	entries, err := loadOrderFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read order file: %v", err)
+	}
+
+	byName := map[string]string{}
+	for _, imagePath := range imageFiles {
+		for _, key := range sourceNameKeys(imagePath, inputDir) {
+			if _, ok := byName[key]; !ok {
+				byName[key] = imagePath
+			}
+		}
+	}
+
+	ordered := make([]string, 0, len(imageFiles))
+	listed := map[string]int{}
+	for _, entry := range entries {
+		imagePath, ok := byName[entry.name]
+		if !ok {
+			if _, err := os.Stat(filepath.Join(inputDir, filepath.FromSlash(entry.name))); err != nil {
+				return nil, fmt.Errorf("%s:%d: %s does not exist in %s", path, entry.line, entry.name, inputDir)
+			}
+			logger.Warn("order file lists an excluded image, leaving it out", "entry", entry.name, "line", entry.line)
+			continue
+		}
+		if first, ok := listed[imagePath]; ok {
+			return nil, fmt.Errorf("%s:%d: %s is already listed on line %d", path, entry.line, entry.name, first)
+		}
+		listed[imagePath] = entry.line
+		ordered = append(ordered, imagePath)
+	}
+	for _, imagePath := range imageFiles {
+		if _, ok := listed[imagePath]; !ok {
+			ordered = append(ordered, imagePath)
+		}
+	}
+	fmt.Fprintf(status, "Ordered %d image(s) by %s, %d more follow in sort order\n", len(listed), path, len(imageFiles)-len(listed))
+	return ordered, nil
+}
+
+// writeOrderFile writes the page order as an order file, paths relative to the input directory,
+// so it can be edited and passed back with --order-file
+func writeOrderFile(path string, imageFiles []string, inputDir string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Page order of %s, one image per line. Move lines and pass this file with --order-file.\n", inputDir)
+	for _, imagePath := range imageFiles {
+		keys := sourceNameKeys(imagePath, inputDir)
+		b.WriteString(keys[len(keys)-1])
+		b.WriteByte('\n')
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// pageOrder puts the discovered images in page order: grouped by directory with --sections, then
+// rearranged by --order-file. With --emit-order the result is written out as well.
+func pageOrder(imageFiles []string, opts Options) ([]string, error) {
+	if opts.Sections {
+		imageFiles = sectionOrder(imageFiles)
+	}
+	inputDir := primaryInputDir(opts.Inputs)
+	if opts.OrderFile != "" {
+		var err error
+		if imageFiles, err = applyOrderFile(opts.status(), imageFiles, opts.OrderFile, inputDir); err != nil {
+			return nil, err
+		}
+	}
+	if opts.EmitOrder != "" {
+		if err := writeOrderFile(opts.EmitOrder, imageFiles, inputDir); err != nil {
+			return nil, fmt.Errorf("failed to write order file: %v", err)
+		}
+		fmt.Fprintf(opts.status(), "Wrote page order: %s\n", opts.EmitOrder)
+	}
+	return imageFiles, nil
+}
@@ -0,0 +1,122 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+)
+
+const exifHeader = "Exif\x00\x00"
+
+// exifIFD0 returns the TIFF data of a JPEG's EXIF segment, its byte order and the 12-byte entries
+// of its first IFD, or no entries if the file has no readable EXIF data
+func exifIFD0(data []byte) ([]byte, binary.ByteOrder, [][]byte) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, nil, nil
+	}
+
+	for _, seg := range jpegSegments(data) {
+		if seg.marker != 0xE1 || !bytes.HasPrefix(seg.payload, []byte(exifHeader)) {
+			continue
+		}
+		tiff := seg.payload[len(exifHeader):]
+		if len(tiff) < 8 {
+			return nil, nil, nil
+		}
+
+		var order binary.ByteOrder
+		switch string(tiff[:2]) {
+		case "II":
+			order = binary.LittleEndian
+		case "MM":
+			order = binary.BigEndian
+		default:
+			return nil, nil, nil
+		}
+
+		ifd := int(order.Uint32(tiff[4:]))
+		if ifd < 8 || ifd+2 > len(tiff) {
+			return nil, nil, nil
+		}
+		count := int(order.Uint16(tiff[ifd:]))
+		var entries [][]byte
+		for i := 0; i < count; i++ {
+			entry := ifd + 2 + i*12
+			if entry+12 > len(tiff) {
+				break
+			}
+			entries = append(entries, tiff[entry:entry+12])
+		}
+		return tiff, order, entries
+	}
+	return nil, nil, nil
+}
+
+// exifOrientation returns the EXIF orientation (1-8) of a JPEG file, or 1 if none is recorded
+func exifOrientation(data []byte) int {
+	_, order, entries := exifIFD0(data)
+	for _, entry := range entries {
+		if order.Uint16(entry) == 0x0112 { // Orientation
+			orientation := int(order.Uint16(entry[8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// applyOrientation transforms an image so it displays upright for the given EXIF orientation
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	src := toRGBA(img)
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 { // the transposing orientations swap the axes
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // needs 90° clockwise rotation
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // needs 90° counter-clockwise rotation
+				dx, dy = y, w-1-x
+			}
+			si := y*src.Stride + x*4
+			di := dy*dst.Stride + dx*4
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+
+	return dst
+}
+
+// toRGBA returns the image as an *image.RGBA anchored at the origin, converting it if necessary
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
@@ -0,0 +1,38 @@
+package imagestopdf
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// pageObjectPattern matches the type entry of a page object, but not of the page tree nodes (/Pages)
+var pageObjectPattern = regexp.MustCompile(`/Type\s*/Page\b`)
+
+// pdfPageCount counts the page objects of a generated PDF, or returns false when they can't be
+// seen, as when they are packed into compressed object streams
+func pdfPageCount(data []byte) (int, bool) {
+	count := len(pageObjectPattern.FindAllIndex(data, -1))
+	return count, count > 0
+}
+
+// checkPageCount compares the pages of the generated PDF to the sheet sides laid out for it, detail
+// says what they were made of. A difference means pages were added or lost on the way, it's warned
+// about, or fails with strict.
+func checkPageCount(status io.Writer, data []byte, expected int, detail string, strict bool) error {
+	actual, ok := pdfPageCount(data)
+	if !ok {
+		logger.Debug("page count of the generated PDF unknown, not checking it")
+		return nil
+	}
+	if actual == expected {
+		return nil
+	}
+
+	if strict {
+		return fmt.Errorf("generated PDF has %d page(s), %d were laid out (%s)", actual, expected, detail)
+	}
+	fmt.Fprintf(status, "⚠️  Warning: the generated PDF has %d page(s) but %d were laid out (%s), check it for blank or missing pages\n",
+		actual, expected, detail)
+	return nil
+}
@@ -0,0 +1,47 @@
+package imagestopdf
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+// TestRowHeightRounding converts pages whose sheet height, the page plus two 5 mm margins, loses a
+// hair to rounding when maroto takes the margins off again. Without rowHeightEpsilon these heights
+// gave one or three extra pages.
+func TestRowHeightRounding(t *testing.T) {
+	for _, height := range []int{101, 108, 120, 143, 157, 199} {
+		t.Run(fmt.Sprintf("400x%d", height), func(t *testing.T) {
+			dir := t.TempDir()
+			writeJPEG(t, filepath.Join(dir, "1.jpg"), solidImage(400, height, color.RGBA{200, 100, 50, 255}), 80)
+			writeJPEG(t, filepath.Join(dir, "2.jpg"), solidImage(400, height, color.RGBA{20, 100, 50, 255}), 80)
+
+			// --strict fails the conversion when the page count check finds extra pages
+			pdf := convertForTest(t, Options{Inputs: []string{dir}, Margin: 5, Strict: true})
+			if pages, ok := pdfPageCount(pdf); !ok || pages != 2 {
+				t.Errorf("got %d page(s), want 2", pages)
+			}
+		})
+	}
+}
+
+func TestCheckPageCount(t *testing.T) {
+	pdf := []byte("<< /Type /Pages /Count 3 >> << /Type /Page >> << /Type/Page >> << /Type /Page >>")
+	if pages, ok := pdfPageCount(pdf); !ok || pages != 3 {
+		t.Fatalf("counted %d page(s), want 3 without the page tree", pages)
+	}
+	if err := checkPageCount(io.Discard, pdf, 3, "", true); err != nil {
+		t.Errorf("matching count: %v", err)
+	}
+	if err := checkPageCount(io.Discard, pdf, 2, "2 images", true); err == nil {
+		t.Error("an extra page should fail with --strict")
+	}
+	if err := checkPageCount(io.Discard, pdf, 2, "2 images", false); err != nil {
+		t.Errorf("an extra page should only be warned about: %v", err)
+	}
+	if err := checkPageCount(io.Discard, []byte("<< /Type /ObjStm >>"), 2, "", true); err != nil {
+		t.Errorf("pages out of sight can't be checked: %v", err)
+	}
+}
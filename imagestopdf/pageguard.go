@@ -0,0 +1,79 @@
+package imagestopdf
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// minPageSide is the smallest auto page side, in points, half an inch
+	minPageSide = 36
+	// maxPageAspect is the most one side of an auto page may exceed the other by
+	maxPageAspect = 20
+	// pageGuardListCount is how many of the images behind a degenerate page size are named
+	pageGuardListCount = 5
+)
+
+// degeneratePage reports why a page of width by height points can't be used as it is, or "" when it can
+func degeneratePage(width, height float64) string {
+	short, long := min(width, height), max(width, height)
+	switch {
+	case short < minPageSide:
+		return fmt.Sprintf("a side under %d points", minPageSide)
+	case long > short*maxPageAspect:
+		return fmt.Sprintf("an aspect ratio over %d:1", maxPageAspect)
+	}
+	return ""
+}
+
+// imagePagePoints is the auto page an image would get on its own, in points
+func imagePagePoints(img optimizedImage, opts Options) (float64, float64) {
+	if opts.UseSourceDPI {
+		return physicalSize(img, opts)
+	}
+	return float64(img.width) * 72 / opts.DPI, float64(img.height) * 72 / opts.DPI
+}
+
+// guardPageSize checks the auto page size computed from the images. A strip of a few pixels
+// across, like a tracking pixel, makes pages no viewer opens: the aspect ratio is capped at
+// maxPageAspect by widening the short side and both sides are raised to minPageSide. The clamped
+// size is warned about, or fails with strict, naming the images that would be degenerate pages
+// on their own.
+func guardPageSize(width, height float64, images []optimizedImage, opts Options) (float64, float64, error) {
+	reason := degeneratePage(width, height)
+	if reason == "" {
+		return width, height, nil
+	}
+
+	var culprits []string
+	for _, img := range images {
+		if img.path == "" || degeneratePage(imagePagePoints(img, opts)) == "" {
+			continue
+		}
+		culprits = append(culprits, fmt.Sprintf("%s (%dx%d pixels)", filepath.Base(img.sourcePath), img.originalWidth, img.originalHeight))
+	}
+	drivenBy := fmt.Sprintf("the %s of the image sizes", opts.PageBasis)
+	if len(culprits) > 0 {
+		if len(culprits) > pageGuardListCount {
+			culprits = append(culprits[:pageGuardListCount], fmt.Sprintf("%d more", len(culprits)-pageGuardListCount))
+		}
+		drivenBy = strings.Join(culprits, ", ")
+	}
+
+	clampedWidth, clampedHeight := width, height
+	if clampedWidth < clampedHeight {
+		clampedWidth = max(clampedWidth, clampedHeight/maxPageAspect, minPageSide)
+		clampedHeight = max(clampedHeight, minPageSide)
+	} else {
+		clampedHeight = max(clampedHeight, clampedWidth/maxPageAspect, minPageSide)
+		clampedWidth = max(clampedWidth, minPageSide)
+	}
+
+	if opts.Strict {
+		return 0, 0, fmt.Errorf("computed page size %.1fx%.1f points has %s, from %s", width, height, reason, drivenBy)
+	}
+	fmt.Fprintf(opts.status(), "⚠️  Warning: computed page size %.1fx%.1f points has %s, from %s; clamped to %.1fx%.1f points\n",
+		width, height, reason, drivenBy, clampedWidth, clampedHeight)
+	return clampedWidth, clampedHeight, nil
+}
@@ -0,0 +1,95 @@
+package imagestopdf
+
+import (
+	"context"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDegeneratePage(t *testing.T) {
+	for _, tc := range []struct {
+		width, height float64
+		degenerate    bool
+	}{
+		{612, 792, false},
+		{36, 36, false},
+		{720, 36, false},
+		{35.9, 500, true},
+		{0.36, 0.36, true},
+		{1000, 40, true},
+		{40, 1000, true},
+	} {
+		if got := degeneratePage(tc.width, tc.height) != ""; got != tc.degenerate {
+			t.Errorf("%gx%g: degenerate %v, want %v", tc.width, tc.height, got, tc.degenerate)
+		}
+	}
+}
+
+// TestGuardPageSizeNamesCulprits clamps a page driven by strips among ordinary images and names
+// only the strips
+func TestGuardPageSizeNamesCulprits(t *testing.T) {
+	opts := defaultOptions()
+	images := []optimizedImage{
+		{sourcePath: "photo.jpg", path: "photo.jpg", width: 800, height: 600, originalWidth: 800, originalHeight: 600},
+		{sourcePath: "strip1.png", path: "strip1.png", width: 3000, height: 10, originalWidth: 3000, originalHeight: 10},
+		{sourcePath: "strip2.png", path: "strip2.png", width: 3000, height: 10, originalWidth: 3000, originalHeight: 10},
+	}
+
+	width, height, err := guardPageSize(1000, 10, images, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if width != 1000 || height != 1000/maxPageAspect {
+		t.Errorf("clamped to %gx%g, want the aspect ratio capped at %gx%g", width, height, 1000.0, 1000.0/maxPageAspect)
+	}
+
+	opts.Strict = true
+	_, _, err = guardPageSize(1000, 10, images, opts)
+	if err == nil {
+		t.Fatal("--strict should fail on a degenerate page size")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "strip1.png") || !strings.Contains(msg, "strip2.png") || strings.Contains(msg, "photo.jpg") {
+		t.Errorf("error should name the strips only: %v", err)
+	}
+}
+
+// TestDegeneratePageSizes converts pathological images on their own and among ordinary photos,
+// and checks the pages come out at a size viewers open
+func TestDegeneratePageSizes(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		images     map[string][2]int
+		pages      int
+		degenerate bool
+	}{
+		{"tracking pixel alone", map[string][2]int{"pixel.png": {1, 1}}, 1, true},
+		{"strip alone", map[string][2]int{"strip.png": {4000, 8}}, 1, true},
+		{"strips with a photo", map[string][2]int{"strip1.png": {4000, 8}, "strip2.png": {4000, 8}, "photo.png": {600, 400}}, 3, true},
+		{"tracking pixel among photos", map[string][2]int{"pixel.png": {1, 1}, "a.png": {600, 400}, "b.png": {600, 400}}, 3, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, size := range tc.images {
+				writePNG(t, filepath.Join(dir, name), photoImage(size[0], size[1], 1))
+			}
+
+			pdf := convertForTest(t, Options{Inputs: []string{dir}})
+			dims := pdfPageDims(t, pdf)
+			if len(dims) != tc.pages {
+				t.Fatalf("got %d page(s), want %d", len(dims), tc.pages)
+			}
+			// The PDF stores the sheet size rounded, a clamped side may read as 35.99
+			if d := dims[0]; degeneratePage(math.Ceil(d.Width), math.Ceil(d.Height)) != "" {
+				t.Errorf("page is %gx%g points, still degenerate", d.Width, d.Height)
+			}
+
+			_, err := Convert(context.Background(), io.Discard, Options{Inputs: []string{dir}, Strict: true})
+			if failed := err != nil; failed != tc.degenerate {
+				t.Errorf("--strict failed: %v, want %v", err, tc.degenerate)
+			}
+		})
+	}
+}
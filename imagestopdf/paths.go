@@ -0,0 +1,21 @@
+package imagestopdf
+
+import "strings"
+
+// maxShortPath is the longest path Windows opens without the \\?\ prefix. MAX_PATH is 260
+// including the terminating NUL, directories need room for an 8.3 file name on top.
+const maxShortPath = 248
+
+// extendedLengthPath prefixes an absolute Windows path with \\?\ (\\?\UNC\ for network shares)
+// so the API skips the MAX_PATH check. Paths already in that form are returned unchanged.
+func extendedLengthPath(abs string) string {
+	if strings.HasPrefix(abs, `\\?\`) {
+		return abs
+	}
+	// The prefix turns off all normalization, forward slashes are no longer accepted as separators
+	abs = strings.ReplaceAll(abs, "/", `\`)
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
@@ -0,0 +1,35 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExtendedLengthPath(t *testing.T) {
+	for _, tc := range []struct {
+		path, want string
+	}{
+		{`C:\Users\me\OneDrive\scans\page1.jpg`, `\\?\C:\Users\me\OneDrive\scans\page1.jpg`},
+		{`C:/Users/me/OneDrive/scans/page1.jpg`, `\\?\C:\Users\me\OneDrive\scans\page1.jpg`},
+		{`\\server\share\scans\page1.jpg`, `\\?\UNC\server\share\scans\page1.jpg`},
+		{`//server/share/scans`, `\\?\UNC\server\share\scans`},
+		{`\\?\C:\already\extended`, `\\?\C:\already\extended`},
+		{`\\?\UNC\server\share`, `\\?\UNC\server\share`},
+	} {
+		if got := extendedLengthPath(tc.path); got != tc.want {
+			t.Errorf("extendedLengthPath(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestASCIIWriter(t *testing.T) {
+	var buf bytes.Buffer
+	line := "✅ done → out.pdf ← in.jpg • ⚠️  Warning\n"
+	n, err := asciiWriter{&buf}.Write([]byte(line))
+	if err != nil || n != len(line) {
+		t.Fatalf("Write returned %d, %v; want %d, nil", n, err, len(line))
+	}
+	if want := "[OK] done -> out.pdf <- in.jpg * [!] Warning\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
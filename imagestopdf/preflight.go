@@ -0,0 +1,44 @@
+package imagestopdf
+
+import (
+	"fmt"
+	"os"
+)
+
+// diskSpaceSafetyFactor covers the optimized copies in the temp directory plus the PDF itself,
+// each at most about the size of the sources, with some headroom
+const diskSpaceSafetyFactor = 2.5
+
+// estimateDiskSpace returns a rough upper bound of the bytes a run writes for the given sources
+func estimateDiskSpace(imageFiles []string) int64 {
+	var total int64
+	for _, path := range imageFiles {
+		if info, err := os.Stat(path); err == nil {
+			total += info.Size()
+		}
+	}
+	return int64(float64(total) * diskSpaceSafetyFactor)
+}
+
+// checkDiskSpace compares the estimate with the free space where dir lives. A shortfall is a
+// warning, or ErrInsufficientSpace in strict mode; failing to query free space is never fatal
+func checkDiskSpace(dir string, needed int64, strict bool) error {
+	free, err := freeDiskSpace(dir)
+	if err != nil {
+		logger.Debug("could not determine free disk space", "path", dir, "error", err)
+		return nil
+	}
+	if free >= needed {
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("%w: about %.2f MB needed in %s, %.2f MB available", ErrInsufficientSpace, megabytes(needed), dir, megabytes(free))
+	}
+	logger.Warn("output filesystem may run out of space", "path", dir,
+		"needed_mb", fmt.Sprintf("%.2f", megabytes(needed)), "available_mb", fmt.Sprintf("%.2f", megabytes(free)))
+	return nil
+}
+
+func megabytes(n int64) float64 {
+	return float64(n) / (1024 * 1024)
+}
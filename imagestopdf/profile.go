@@ -0,0 +1,61 @@
+package imagestopdf
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// cpuProfilePath and memProfilePath are the --cpuprofile and --memprofile files, empty when not profiling
+var cpuProfilePath, memProfilePath string
+
+// startProfiling starts the CPU profile for --cpuprofile. The returned function stops it and writes
+// the heap profile for --memprofile, it must run before the process exits, deferring it is not enough
+// because failed runs leave through os.Exit.
+func startProfiling() (func(), error) {
+	var cpuFile *os.File
+	if cpuProfilePath != "" {
+		var err error
+		if cpuFile, err = os.Create(cpuProfilePath); err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile: %v", err)
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			cpuFile.Close()
+			return nil, fmt.Errorf("failed to start CPU profile: %v", err)
+		}
+	}
+
+	return func() {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuFile.Close(); err != nil {
+				logger.Warn("failed to write CPU profile", "path", cpuProfilePath, "error", err)
+			} else {
+				logger.Info("wrote CPU profile", "path", cpuProfilePath)
+			}
+		}
+		if memProfilePath != "" {
+			if err := writeHeapProfile(memProfilePath); err != nil {
+				logger.Warn("failed to write memory profile", "path", memProfilePath, "error", err)
+			} else {
+				logger.Info("wrote memory profile", "path", memProfilePath)
+			}
+		}
+	}, nil
+}
+
+// writeHeapProfile writes the allocations of the run so far, after a collection so that the
+// in-use figures are current
+func writeHeapProfile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
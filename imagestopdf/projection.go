@@ -0,0 +1,103 @@
+package imagestopdf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	// defaultSizeTarget is the PDF size warned about without --max-size
+	defaultSizeTarget = 3 << 20
+	// pdfDocumentOverhead estimates the bytes of the document structure: catalog, page tree, font and cross-reference table
+	pdfDocumentOverhead = 4096
+	// defaultQualitySlope is the size model slope assumed for JPEGs that weren't probed, see qualityProbe
+	defaultQualitySlope = 0.02
+)
+
+// errCancelled stops a run the user declined to continue, it exits successfully
+var errCancelled = errors.New("cancelled")
+
+// sizeTarget is the size the PDF should stay under: --max-size, or the default target
+func sizeTarget(maxSize int64) int64 {
+	if maxSize > 0 {
+		return maxSize
+	}
+	return defaultSizeTarget
+}
+
+// projectedPDFSize estimates the size of the PDF from the optimized images, which are embedded
+// as they are, plus pdfPageOverhead per page and pdfDocumentOverhead for the document. The PDF
+// holds identical images once, copies of a source file with the same optimized size count once.
+func projectedPDFSize(images []optimizedImage) int64 {
+	type embedded struct {
+		sum  string
+		size int64
+	}
+	seen := map[embedded]bool{}
+	size := int64(pdfDocumentOverhead)
+	for _, img := range images {
+		size += pdfPageOverhead
+		if key := (embedded{img.sourceSHA256, img.size}); img.sourceSHA256 == "" || !seen[key] {
+			seen[key] = true
+			size += img.size
+		}
+	}
+	return size
+}
+
+// projectSize prints the projected PDF size once the images are optimized and warns when it is
+// over the target. With --interactive it then offers to lower the quality of the re-encoded JPEGs
+// until they fit and asks whether to go on, declining returns errCancelled. The images, tightened
+// or not, and their projected size are returned.
+func projectSize(ctx context.Context, images []optimizedImage, rotations map[string]int, budget *memoryBudget, retry *retrier, opts Options) ([]optimizedImage, int64, error) {
+	projected := projectedPDFSize(images)
+	target := sizeTarget(opts.MaxSize)
+	fmt.Fprintf(opts.status(), "Projected PDF size: %.2f MB\n", megabytes(projected))
+	if projected <= target {
+		return images, projected, nil
+	}
+	fmt.Fprintf(opts.status(), "⚠️  Warning: the PDF is projected to exceed the %.2f MB target\n", megabytes(target))
+	if !opts.Interactive {
+		return images, projected, nil
+	}
+
+	jpegs := 0
+	for _, img := range images {
+		if img.quality > 0 {
+			jpegs++
+		}
+	}
+	if jpegs > 0 && confirm(os.Stdin, opts.status(), fmt.Sprintf("Lower the quality of %d re-encoded JPEG(s) to fit %.2f MB?", jpegs, megabytes(target))) {
+		var err error
+		if images, err = tightenToTarget(ctx, images, rotations, budget, retry, opts, target); err != nil {
+			return nil, 0, err
+		}
+		projected = projectedPDFSize(images)
+		fmt.Fprintf(opts.status(), "Projected PDF size: %.2f MB\n", megabytes(projected))
+		if projected <= target {
+			return images, projected, nil
+		}
+		fmt.Fprintf(opts.status(), "⚠️  Warning: still projected over the target by %d KB\n", (projected-target+1023)/1024)
+	}
+	if !confirm(os.Stdin, opts.status(), "Continue with the larger PDF?") {
+		return nil, 0, errCancelled
+	}
+	return images, projected, nil
+}
+
+// tightenToTarget fits the re-encoded JPEGs into target the way --budget-mode global does. Images
+// converted without a probe get a model with the default slope, the rounds of allocateGlobalBudget
+// refit it to the real sizes.
+func tightenToTarget(ctx context.Context, images []optimizedImage, rotations map[string]int, budget *memoryBudget, retry *retrier, opts Options, target int64) ([]optimizedImage, error) {
+	for i, img := range images {
+		if img.probe == nil && img.quality > 0 {
+			images[i].probe = &qualityProbe{quality: img.quality, size: img.size, slope: defaultQualitySlope}
+		}
+	}
+	budgetOpts := opts
+	// The page overhead is counted by allocateGlobalBudget, the document's is left to it here
+	budgetOpts.MaxSize = target - pdfDocumentOverhead
+	return allocateGlobalBudget(ctx, images, rotations, budget, retry, budgetOpts)
+}
@@ -0,0 +1,200 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// quantizeMaxSourceColors is the sampled color count above which auto mode keeps line art
+// lossless instead of trying a palette, such images lose visible detail when reduced to 256 colors.
+// It is also where analyzeLineArt stops counting.
+const quantizeMaxSourceColors = 16384
+
+// colorCount is one distinct color of an image and how many pixels have it
+type colorCount struct {
+	rgb   [3]uint8
+	count int
+}
+
+// colorBox is a group of colors that median cut splits further or averages into one palette entry
+type colorBox []colorCount
+
+// widestChannel returns the channel with the largest value range in the box, and that range
+func (b colorBox) widestChannel() (channel, spread int) {
+	for c := 0; c < 3; c++ {
+		lo, hi := 255, 0
+		for _, entry := range b {
+			lo, hi = min(lo, int(entry.rgb[c])), max(hi, int(entry.rgb[c]))
+		}
+		if hi-lo > spread {
+			channel, spread = c, hi-lo
+		}
+	}
+	return channel, spread
+}
+
+// mean is the pixel-weighted average color of the box
+func (b colorBox) mean() color.RGBA {
+	var sum [3]int
+	var total int
+	for _, entry := range b {
+		for c := 0; c < 3; c++ {
+			sum[c] += int(entry.rgb[c]) * entry.count
+		}
+		total += entry.count
+	}
+	return color.RGBA{
+		R: uint8((sum[0] + total/2) / total),
+		G: uint8((sum[1] + total/2) / total),
+		B: uint8((sum[2] + total/2) / total),
+		A: 255,
+	}
+}
+
+// medianCut picks a palette of at most size colors for the opaque image. Images with no more
+// distinct colors than that get exactly their own colors. Otherwise the box with the widest
+// channel is split at its pixel-weighted median until there are size boxes, each box becomes
+// the average of its colors.
+func medianCut(img *image.RGBA, size int) color.Palette {
+	counts := map[[3]uint8]int{}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(bounds.Min.X, y):]
+		for x := 0; x < bounds.Dx(); x++ {
+			counts[[3]uint8{row[x*4], row[x*4+1], row[x*4+2]}]++
+		}
+	}
+
+	all := make(colorBox, 0, len(counts))
+	for rgb, count := range counts {
+		all = append(all, colorCount{rgb, count})
+	}
+	// Map order is random, a fixed order keeps the palette the same from run to run
+	sort.Slice(all, func(i, j int) bool {
+		a, b := all[i].rgb, all[j].rgb
+		return a[0] < b[0] || a[0] == b[0] && (a[1] < b[1] || a[1] == b[1] && a[2] < b[2])
+	})
+
+	boxes := []colorBox{all}
+	if len(all) <= size {
+		boxes = boxes[:0]
+		for _, entry := range all {
+			boxes = append(boxes, colorBox{entry})
+		}
+	}
+	for len(boxes) < size {
+		widest, widestChannel, widestSpread := -1, 0, 0
+		for i, box := range boxes {
+			if channel, spread := box.widestChannel(); spread > widestSpread {
+				widest, widestChannel, widestSpread = i, channel, spread
+			}
+		}
+		if widest < 0 {
+			break
+		}
+
+		box := boxes[widest]
+		sort.SliceStable(box, func(i, j int) bool { return box[i].rgb[widestChannel] < box[j].rgb[widestChannel] })
+		var total, half int
+		for _, entry := range box {
+			total += entry.count
+		}
+		split := 1
+		for i, entry := range box[:len(box)-1] {
+			half += entry.count
+			split = i + 1
+			if half*2 >= total {
+				break
+			}
+		}
+		boxes[widest] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+
+	palette := make(color.Palette, len(boxes))
+	for i, box := range boxes {
+		palette[i] = box.mean()
+	}
+	return palette
+}
+
+// quantizeImage reduces the image to a palette of at most size colors, transparent areas are
+// flattened onto the background first. Dithering trades flat areas for smoother gradients.
+func quantizeImage(img image.Image, size int, dither bool, background color.RGBA) *image.Paletted {
+	bounds := img.Bounds()
+	flat := flattenImage(img, background)
+
+	palette := medianCut(flat, size)
+	paletted := image.NewPaletted(bounds, palette)
+	if dither {
+		draw.FloydSteinberg.Draw(paletted, bounds, flat, bounds.Min)
+		return paletted
+	}
+
+	// Flat regions repeat the same few colors, so nearest-color lookups are cached
+	nearest := map[[3]uint8]uint8{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := flat.Pix[flat.PixOffset(bounds.Min.X, y):]
+		out := paletted.Pix[paletted.PixOffset(bounds.Min.X, y):]
+		for x := 0; x < bounds.Dx(); x++ {
+			rgb := [3]uint8{row[x*4], row[x*4+1], row[x*4+2]}
+			index, ok := nearest[rgb]
+			if !ok {
+				index = uint8(palette.Index(color.RGBA{rgb[0], rgb[1], rgb[2], 255}))
+				nearest[rgb] = index
+			}
+			out[x] = index
+		}
+	}
+	return paletted
+}
+
+// encodeQuantizedPNG encodes the paletted image as an indexed PNG
+func encodeQuantizedPNG(img *image.Paletted) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeQuantizedPNG quantizes the image and writes it as an indexed PNG, returning its size
+func writeQuantizedPNG(img image.Image, outputPath string, opts Options) (int64, error) {
+	data, err := encodeQuantizedPNG(quantizeImage(img, opts.QuantizeColors, opts.QuantizeDither, opts.Background))
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(data)), os.WriteFile(longPath(outputPath), data, 0644)
+}
+
+// smallerOfQuantizedAndJPEG encodes the image both as an indexed PNG and as a JPEG and keeps the
+// smaller file, returning the chosen strategy, the path without extension plus the new one, and the size
+func smallerOfQuantizedAndJPEG(img image.Image, basePath string, totalPixels int, opts Options) (string, string, int64, error) {
+	quantized, err := encodeQuantizedPNG(quantizeImage(img, opts.QuantizeColors, opts.QuantizeDither, opts.Background))
+	if err != nil {
+		return "", "", 0, err
+	}
+	jpegPath := basePath + ".jpg"
+	if err := convertPNGToOptimalJPEG(img, jpegPath, totalPixels, opts.Quality, opts.Background); err != nil {
+		return "", "", 0, err
+	}
+	jpegInfo, err := os.Stat(longPath(jpegPath))
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	logger.Debug("compared palette and JPEG encodings", "image", filepath.Base(basePath), "quantized_bytes", len(quantized), "jpeg_bytes", jpegInfo.Size())
+	if jpegInfo.Size() <= int64(len(quantized)) {
+		return "convert_png_to_jpeg", jpegPath, jpegInfo.Size(), nil
+	}
+	os.Remove(longPath(jpegPath))
+	pngPath := basePath + ".png"
+	return "quantize_png", pngPath, int64(len(quantized)), os.WriteFile(longPath(pngPath), quantized, 0644)
+}
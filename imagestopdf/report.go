@@ -0,0 +1,140 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultReportPath is the --report value used when the flag is given without a path
+const defaultReportPath = "<output>.report.html"
+
+// reportThumbnailWidth is the pixel width of the page thumbnails embedded in the report
+const reportThumbnailWidth = 120
+
+// reportThumbnail encodes a small JPEG of an already scaled image for the HTML report,
+// transparent areas are flattened onto the background color
+func reportThumbnail(img image.Image, background color.RGBA) []byte {
+	flat := flattenImage(scaleImageToWidth(img, reportThumbnailWidth), background)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: 70}); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// reportRow is one page of the report table
+type reportRow struct {
+	manifestPage
+	SourceName string
+	Thumbnail  template.URL
+}
+
+// reportData is everything the report template shows
+type reportData struct {
+	Output         string
+	Created        string
+	ThumbnailWidth int
+	Pages          int
+	Images         int
+	OriginalBytes  int64
+	FinalBytes     int64
+	PDFBytes       int64
+	Strategies     map[string]int
+	Rows           []reportRow
+}
+
+// Reduction is the share of the source bytes saved by optimizing, in percent
+func (d reportData) Reduction() float64 {
+	if d.OriginalBytes == 0 {
+		return 0
+	}
+	return float64(d.OriginalBytes-d.FinalBytes) / float64(d.OriginalBytes) * 100
+}
+
+var reportFuncs = template.FuncMap{
+	"kb": func(n int64) string { return fmt.Sprintf("%.1f KB", float64(n)/1024) },
+	"mb": func(n int64) string { return fmt.Sprintf("%.2f MB", float64(n)/(1024*1024)) },
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(reportFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Conversion report: {{.Output}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+th, td { border-bottom: 1px solid #ddd; padding: 4px 10px; text-align: left; vertical-align: middle; }
+td.num { text-align: right; }
+img { display: block; max-width: {{.ThumbnailWidth}}px; border: 1px solid #ccc; }
+.note { color: #777; font-style: italic; }
+</style>
+</head>
+<body>
+<h1>{{.Output}}</h1>
+<p>Created {{.Created}}</p>
+<ul>
+<li>{{.Pages}} pages, {{.Images}} from images</li>
+<li>Sources: {{mb .OriginalBytes}}, optimized: {{mb .FinalBytes}} ({{printf "%.1f" .Reduction}}% reduction)</li>
+<li>PDF: {{mb .PDFBytes}}</li>
+<li>Strategies:{{range $name, $count := .Strategies}} {{$name}} &times; {{$count}}{{end}}</li>
+</ul>
+<table>
+<tr><th>Page</th><th>Preview</th><th>Source</th><th>Original</th><th>Final</th><th>Strategy</th><th>Before</th><th>After</th></tr>
+{{range .Rows}}<tr>
+<td class="num">{{.Page}}</td>
+{{if .Source}}<td>{{if .Thumbnail}}<img src="{{.Thumbnail}}" alt="page {{.Page}}">{{end}}</td>
+<td title="{{.Source}}">{{.SourceName}}</td>
+<td>{{.OriginalWidth}}&times;{{.OriginalHeight}}</td>
+<td>{{.Width}}&times;{{.Height}}</td>
+<td>{{.Strategy}}</td>
+<td class="num">{{kb .OriginalBytes}}</td>
+<td class="num">{{kb .Bytes}}</td>
+{{else}}<td colspan="7" class="note">{{if .Divider}}divider: {{.Section}}{{else}}blank page{{end}}</td>
+{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// writeReport writes a self-contained HTML overview of the run, thumbnails are embedded as data URLs
+func writeReport(path, outputPath string, pages []manifestPage, created time.Time) error {
+	data := reportData{
+		Output:         filepath.Base(outputPath),
+		Created:        created.Format("2006-01-02 15:04:05"),
+		ThumbnailWidth: reportThumbnailWidth,
+		Pages:          len(pages),
+		Strategies:     map[string]int{},
+	}
+	if info, err := os.Stat(outputPath); err == nil {
+		data.PDFBytes = info.Size()
+	}
+	for _, p := range pages {
+		row := reportRow{manifestPage: p, SourceName: filepath.Base(p.Source)}
+		if len(p.thumbnail) > 0 {
+			row.Thumbnail = template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(p.thumbnail))
+		}
+		if p.Source != "" {
+			data.Images++
+			data.OriginalBytes += p.OriginalBytes
+			data.FinalBytes += p.Bytes
+			data.Strategies[p.Strategy]++
+		}
+		data.Rows = append(data.Rows, row)
+	}
+
+	var buf bytes.Buffer
+	if err := reportTemplate.Execute(&buf, data); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
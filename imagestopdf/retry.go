@@ -0,0 +1,66 @@
+package imagestopdf
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// retryBackoff is the wait before the first retry, doubled for every further attempt
+const retryBackoff = 200 * time.Millisecond
+
+// retrier repeats file reads that fail with transient I/O errors, as network filesystems
+// occasionally produce, and counts the retries for the end-of-run summary
+type retrier struct {
+	max   int
+	count atomic.Int64
+}
+
+func newRetrier(max int) *retrier {
+	return &retrier{max: max}
+}
+
+// do runs read, retrying it up to max times with backoff while it fails with a transient error.
+// Anything else, such as a missing file or undecodable data, is returned right away.
+func (r *retrier) do(ctx context.Context, path string, read func() error) error {
+	err := read()
+	for attempt := 0; attempt < r.max && isTransientIOError(err); attempt++ {
+		r.count.Add(1)
+		logger.Warn("transient read error, retrying", "path", path, "attempt", attempt+1, "error", err)
+
+		timer := time.NewTimer(retryBackoff << attempt)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		err = read()
+	}
+	return err
+}
+
+// retries returns how many reads were repeated so far
+func (r *retrier) retries() int64 {
+	return r.count.Load()
+}
+
+// transientErrors are the read failures worth repeating
+var transientErrors = append([]error{syscall.EIO, syscall.EAGAIN, syscall.EINTR, syscall.ETIMEDOUT,
+	syscall.ECONNRESET, syscall.ESTALE, os.ErrDeadlineExceeded}, platformTransientErrors...)
+
+// isTransientIOError reports whether a failed read may succeed when repeated
+func isTransientIOError(err error) bool {
+	if err == nil || errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission) {
+		return false
+	}
+	for _, transient := range transientErrors {
+		if errors.Is(err, transient) {
+			return true
+		}
+	}
+	return false
+}
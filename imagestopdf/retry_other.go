@@ -0,0 +1,6 @@
+//go:build !windows
+
+package imagestopdf
+
+// platformTransientErrors extends the transient errors beyond the portable ones in isTransientIOError
+var platformTransientErrors []error
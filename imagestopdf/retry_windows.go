@@ -0,0 +1,12 @@
+//go:build windows
+
+package imagestopdf
+
+import "syscall"
+
+// platformTransientErrors are the errors SMB shares report for dropped or slow connections
+var platformTransientErrors = []error{
+	syscall.Errno(59),  // ERROR_UNEXP_NET_ERR
+	syscall.Errno(64),  // ERROR_NETNAME_DELETED
+	syscall.Errno(121), // ERROR_SEM_TIMEOUT
+}
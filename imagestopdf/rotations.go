@@ -0,0 +1,119 @@
+package imagestopdf
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// autoRotationsFile is picked up from the input directory when --rotate-file isn't given
+const autoRotationsFile = ".images-to-pdf-rotate"
+
+// rotationList maps source image names to a clockwise rotation in degrees
+type rotationList map[string]int
+
+// validateRotation checks that a rotation is a multiple of 90 degrees the tool can apply
+func validateRotation(degrees int) error {
+	switch degrees {
+	case 0, 90, 180, 270:
+		return nil
+	}
+	return fmt.Errorf("invalid rotation %d, must be 90, 180 or 270", degrees)
+}
+
+// loadRotations reads a rotations file with lines like "IMG_0042.jpg 90". Names are either
+// base names or paths relative to the input directory; lines starting with # are comments.
+// Without an explicit path, .images-to-pdf-rotate in the input directory is used if present.
+func loadRotations(status io.Writer, path, inputDir string) (rotationList, error) {
+	rotations := rotationList{}
+	if path == "" {
+		path = filepath.Join(inputDir, autoRotationsFile)
+		if _, err := os.Stat(path); err != nil {
+			return rotations, nil
+		}
+		fmt.Fprintf(status, "Using rotations from %s\n", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// The angle is the last field so file names may contain spaces
+		split := strings.LastIndexAny(line, " \t")
+		if split < 0 {
+			return nil, fmt.Errorf("%s:%d: expected \"<file> <degrees>\"", path, lineNum)
+		}
+		name := strings.TrimSpace(line[:split])
+		degrees, err := strconv.Atoi(line[split+1:])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid rotation %q", path, lineNum, line[split+1:])
+		}
+		if err := validateRotation(degrees); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNum, err)
+		}
+		rotations[filepath.ToSlash(filepath.Clean(name))] = degrees
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return rotations, nil
+}
+
+// rotationFor returns the rotation for a source image: its own entry if listed, otherwise the global rotation
+func (r rotationList) rotationFor(sourcePath, inputDir string, global int) int {
+	for _, key := range sourceNameKeys(sourcePath, inputDir) {
+		if degrees, ok := r[key]; ok {
+			return degrees
+		}
+	}
+	return global
+}
+
+// warnUnmatched prints a warning for every entry that doesn't refer to a selected image
+func (r rotationList) warnUnmatched(imageFiles []string, inputDir string) {
+	matched := map[string]bool{}
+	for _, imagePath := range imageFiles {
+		for _, key := range sourceNameKeys(imagePath, inputDir) {
+			matched[key] = true
+		}
+	}
+	var unmatched []string
+	for name := range r {
+		if !matched[name] {
+			unmatched = append(unmatched, name)
+		}
+	}
+	sort.Strings(unmatched)
+	for _, name := range unmatched {
+		logger.Warn("rotation entry doesn't match any selected image", "entry", name)
+	}
+}
+
+// rotateImage rotates an image clockwise by 90, 180 or 270 degrees
+func rotateImage(img image.Image, degrees int) image.Image {
+	switch degrees {
+	case 90:
+		return applyOrientation(img, 6)
+	case 180:
+		return applyOrientation(img, 3)
+	case 270:
+		return applyOrientation(img, 8)
+	}
+	return img
+}
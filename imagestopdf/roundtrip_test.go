@@ -0,0 +1,276 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// update rewrites the golden files instead of comparing against them: go test -run TestRoundTrip -update
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// roundTripGolden pins the compression strategy picked for each round-trip fixture, changes to
+// determineCompressionStrategy show up as a diff of it
+const roundTripGolden = "testdata/roundtrip_strategies.golden.json"
+
+const (
+	// roundTripWidth and roundTripHeight are the size every fixture is embedded at, the larger ones
+	// are scaled down to it
+	roundTripWidth, roundTripHeight = optimizedWidth, optimizedWidth * 3 / 4
+	// roundTripThumb is the width the embedded and source pixels are compared at
+	roundTripThumb = 32
+	// roundTripPageTolerance is how far, in points, a page may be off the size of its image
+	roundTripPageTolerance = 0.5
+)
+
+// roundTripFixture is a generated source image and how close its embedded copy has to stay to it
+type roundTripFixture struct {
+	name  string
+	img   image.Image // the pixels the page should show
+	write func(t *testing.T, path string)
+	// maxDiff is the largest mean difference of a thumbnail channel, out of 255
+	maxDiff float64
+	// colorSpace and alpha are what the embedded image should be: its color space and whether it
+	// has a soft mask
+	colorSpace string
+	alpha      bool
+}
+
+// roundTripFixtures generates one source of each kind the pipeline treats differently, all 4:3
+func roundTripFixtures() []roundTripFixture {
+	png := func(img image.Image) func(t *testing.T, path string) {
+		return func(t *testing.T, path string) { writePNG(t, path, img) }
+	}
+	jpg := func(img image.Image, quality int) func(t *testing.T, path string) {
+		return func(t *testing.T, path string) { writeJPEG(t, path, img, quality) }
+	}
+
+	solid := solidImage(roundTripWidth, roundTripHeight, color.RGBA{200, 40, 40, 255})
+	gradient := gradientImage(roundTripWidth, roundTripHeight)
+	text := screenshotImage(roundTripWidth, roundTripHeight, false)
+	photo := photoImage(2*roundTripWidth, 2*roundTripHeight, 1)
+	pngPhoto := photoImage(roundTripWidth*5/4, roundTripHeight*5/4, 2)
+	translucent := translucentImage(roundTripWidth, roundTripHeight)
+
+	cmykColor := func(bx, by int) color.CMYK {
+		return color.CMYK{C: uint8(bx * 2), M: uint8(by * 3), Y: 60, K: uint8((bx / 10 % 2) * 80)}
+	}
+	cmyk := image.NewCMYK(image.Rect(0, 0, roundTripWidth, roundTripHeight))
+	for y := 0; y < roundTripHeight; y++ {
+		for x := 0; x < roundTripWidth; x++ {
+			cmyk.SetCMYK(x, y, cmykColor(x/8, y/8))
+		}
+	}
+
+	// Three frames of a moving bar, the first one is the page
+	var frames []*image.Paletted
+	for i := 0; i < 3; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, roundTripWidth, roundTripHeight), palette.Plan9)
+		draw.Draw(frame, frame.Bounds(), image.NewUniform(color.RGBA{240, 240, 200, 255}), image.Point{}, draw.Src)
+		draw.Draw(frame, image.Rect(100+i*200, 200, 300+i*200, 400), image.NewUniform(color.RGBA{0, 0, 160, 255}), image.Point{}, draw.Src)
+		frames = append(frames, frame)
+	}
+	animated := func(t *testing.T, path string) {
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, &gif.GIF{Image: frames, Delay: []int{10, 10, 10}}); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, path, buf.Bytes())
+	}
+
+	// A screenshot saved as GIF is re-encoded, and as line art can go to an indexed PNG
+	textGIF := image.NewPaletted(image.Rect(0, 0, roundTripWidth, roundTripHeight), palette.Plan9)
+	draw.Draw(textGIF, textGIF.Bounds(), text, image.Point{}, draw.Src)
+	still := func(t *testing.T, path string) {
+		var buf bytes.Buffer
+		if err := gif.Encode(&buf, textGIF, nil); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, path, buf.Bytes())
+	}
+
+	return []roundTripFixture{
+		{"01-solid.png", solid, png(solid), 1, "DeviceRGB", false},
+		{"02-gradient.png", gradient, png(gradient), 2, "DeviceRGB", false},
+		{"03-text.png", text, png(text), 2, "DeviceRGB", false},
+		{"04-photo.jpg", photo, jpg(photo, 95), 4, "DeviceRGB", false},
+		{"05-photo.png", pngPhoto, png(pngPhoto), 4, "DeviceRGB", false},
+		// Kept as it is, the alpha goes into a soft mask
+		{"06-translucent.png", translucent, png(translucent), 2, "DeviceRGB", true},
+		{"07-cmyk.jpg", cmyk, func(t *testing.T, path string) {
+			writeFile(t, path, cmykJPEG(roundTripWidth, roundTripHeight, cmykColor))
+		}, 2, "DeviceCMYK", false},
+		{"08-animated.gif", frames[0], animated, 2, "DeviceRGB", false},
+		{"09-text.gif", textGIF, still, 1, "Indexed", false},
+	}
+}
+
+// TestRoundTrip converts the generated fixtures and reads the PDF back: the page count and sizes,
+// the strategy picked per image against the golden file, and the embedded pixels against the sources
+func TestRoundTrip(t *testing.T) {
+	fixtures := roundTripFixtures()
+	dir := t.TempDir()
+	for _, f := range fixtures {
+		f.write(t, filepath.Join(dir, f.name))
+	}
+
+	var buf bytes.Buffer
+	result, err := Convert(context.Background(), &buf, Options{Inputs: []string{dir}, StripMetadata: true})
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	pdf := buf.Bytes()
+
+	dims := pdfPageDims(t, pdf)
+	if result.Pages != len(fixtures) || len(dims) != len(fixtures) || len(result.Images) != len(fixtures) {
+		t.Fatalf("got %d page(s), %d in the PDF, %d image(s); want %d", result.Pages, len(dims), len(result.Images), len(fixtures))
+	}
+	// The auto page is the image size at the DPI in points, maroto lays it out in millimeters
+	wantWidth, wantHeight := float64(roundTripWidth)*72/200/mmPerPoint, float64(roundTripHeight)*72/200/mmPerPoint
+	for i, d := range dims {
+		if math.Abs(d.Width-wantWidth) > roundTripPageTolerance || math.Abs(d.Height-wantHeight) > roundTripPageTolerance {
+			t.Errorf("page %d is %.2fx%.2f points, want %.2fx%.2f", i+1, d.Width, d.Height, wantWidth, wantHeight)
+		}
+	}
+
+	strategies := map[string]string{}
+	for _, img := range result.Images {
+		strategies[filepath.Base(img.Source)] = img.Strategy
+	}
+	checkGolden(t, roundTripGolden, strategies)
+
+	// Adobe CMYK JPEGs store inverted values, the PDF has to say so or viewers show a negative
+	if !bytes.Contains(pdf, []byte("/Decode[1 0 1 0 1 0 1 0]")) {
+		t.Error("the CMYK JPEG is embedded without an inverting decode array")
+	}
+
+	embedded := pdfImagesDecoded(t, pdf)
+	for i, f := range fixtures {
+		if len(embedded[i]) != 1 {
+			t.Errorf("%s: page %d has %d image(s), want 1", f.name, i+1, len(embedded[i]))
+			continue
+		}
+		got := embedded[i][0]
+		if got.Cs != f.colorSpace || got.HasSMask != f.alpha {
+			t.Errorf("%s: embedded in %s with soft mask %v, want %s and %v", f.name, got.Cs, got.HasSMask, f.colorSpace, f.alpha)
+		}
+		if diff := thumbnailDiff(f.img, got.decoded); diff > f.maxDiff {
+			t.Errorf("%s: embedded image differs from the source by %.2f, want at most %.2f", f.name, diff, f.maxDiff)
+		}
+	}
+}
+
+// checkGolden compares got to the JSON golden file at path, or rewrites it with -update
+func checkGolden(t *testing.T, path string, got map[string]string) {
+	t.Helper()
+	data, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, '\n')
+	if *update {
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run with -update to create it", err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("%s differs, run with -update if the change is intended\ngot:\n%s\nwant:\n%s", path, data, want)
+	}
+}
+
+// decodedImage is an image extracted from a PDF: its image dictionary and its decoded pixels,
+// with the soft mask applied as alpha
+type decodedImage struct {
+	model.Image
+	decoded image.Image
+}
+
+// pdfImagesDecoded returns the images shown on each page of a PDF, decoded the way pdfcpu exports
+// them, keyed by page
+func pdfImagesDecoded(t *testing.T, data []byte) [][]decodedImage {
+	t.Helper()
+	ctx, _, _, _, err := api.ReadValidateAndOptimize(bytes.NewReader(data), model.NewDefaultConfiguration(), time.Now())
+	if err != nil {
+		t.Fatalf("reading PDF: %v", err)
+	}
+	pages := make([][]decodedImage, ctx.PageCount)
+	for i := range pages {
+		// Stubs carry the image dictionary, the full extraction the pixels
+		stubs, err := pdfcpu.ExtractPageImages(ctx, i+1, true)
+		if err != nil {
+			t.Fatalf("page %d: %v", i+1, err)
+		}
+		images, err := pdfcpu.ExtractPageImages(ctx, i+1, false)
+		if err != nil {
+			t.Fatalf("page %d: %v", i+1, err)
+		}
+		for objNr, stub := range stubs {
+			img, ok := images[objNr]
+			if !ok {
+				t.Fatalf("page %d: image object %d (%s) could not be extracted", i+1, objNr, stub.Cs)
+			}
+			decoded, _, err := image.Decode(img)
+			if err != nil {
+				t.Fatalf("page %d: decoding image object %d: %v", i+1, objNr, err)
+			}
+			pages[i] = append(pages[i], decodedImage{stub, decoded})
+		}
+	}
+	return pages
+}
+
+// thumbnailDiff box-averages both images down to roundTripThumb pixels wide and returns the mean
+// absolute difference of their channels, which tolerates compression noise and resampling but
+// not shifted, missing or discolored content
+func thumbnailDiff(a, b image.Image) float64 {
+	ta, tb := thumbnail(a), thumbnail(b)
+	var sum float64
+	for i := range ta {
+		sum += math.Abs(ta[i] - tb[i])
+	}
+	return sum / float64(len(ta))
+}
+
+// thumbnail returns the RGB channels of img averaged over a roundTripThumb wide grid
+func thumbnail(img image.Image) []float64 {
+	bounds := img.Bounds()
+	thumbHeight := roundTripThumb * bounds.Dy() / bounds.Dx()
+	sums := make([]float64, roundTripThumb*thumbHeight*3)
+	counts := make([]float64, roundTripThumb*thumbHeight)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		ty := min((y-bounds.Min.Y)*thumbHeight/bounds.Dy(), thumbHeight-1)
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			tx := (x - bounds.Min.X) * roundTripThumb / bounds.Dx()
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			cell := ty*roundTripThumb + tx
+			sums[cell*3] += float64(c.R)
+			sums[cell*3+1] += float64(c.G)
+			sums[cell*3+2] += float64(c.B)
+			counts[cell]++
+		}
+	}
+	for i := range sums {
+		sums[i] /= counts[i/3]
+	}
+	return sums
+}
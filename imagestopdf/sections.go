@@ -0,0 +1,134 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// sectionOrder groups the sorted images by their parent directory for --sections. Directories
+// follow each other in natural order, the images inside a directory keep their sort order.
+func sectionOrder(imageFiles []string) []string {
+	groups := map[string][]string{}
+	var dirs []string
+	for _, path := range imageFiles {
+		dir := filepath.Dir(path)
+		if _, ok := groups[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		groups[dir] = append(groups[dir], path)
+	}
+
+	sort.SliceStable(dirs, func(i, j int) bool {
+		return naturalComparePaths(dirs[i], dirs[j]) < 0
+	})
+
+	ordered := make([]string, 0, len(imageFiles))
+	for _, dir := range dirs {
+		ordered = append(ordered, groups[dir]...)
+	}
+	return ordered
+}
+
+// naturalComparePaths compares paths component by component with naturalCompare,
+// so a parent directory comes before its subdirectories
+func naturalComparePaths(a, b string) int {
+	partsA := strings.Split(filepath.ToSlash(a), "/")
+	partsB := strings.Split(filepath.ToSlash(b), "/")
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		if c := naturalCompare(partsA[i], partsB[i]); c != 0 {
+			return c
+		}
+	}
+	return len(partsA) - len(partsB)
+}
+
+// naturalCompare orders strings with runs of digits compared by their numeric value,
+// so "chapter2" sorts before "chapter10"
+func naturalCompare(a, b string) int {
+	return naturalCompareWith(a, b, strings.Compare)
+}
+
+// naturalCompareWith is naturalCompare with the text between the runs of digits compared by
+// compareText, such as a locale's collation
+func naturalCompareWith(a, b string, compareText func(a, b string) int) int {
+	for a != "" && b != "" {
+		digitsA, digitsB := leadingDigits(a), leadingDigits(b)
+		if digitsA != "" && digitsB != "" {
+			numA, numB := strings.TrimLeft(digitsA, "0"), strings.TrimLeft(digitsB, "0")
+			if len(numA) != len(numB) {
+				return len(numA) - len(numB)
+			}
+			if c := strings.Compare(numA, numB); c != 0 {
+				return c
+			}
+			a, b = a[len(digitsA):], b[len(digitsB):]
+			continue
+		}
+		tokenA, tokenB := leadingToken(a), leadingToken(b)
+		if c := compareText(tokenA, tokenB); c != 0 {
+			return c
+		}
+		a, b = a[len(tokenA):], b[len(tokenB):]
+	}
+	return compareText(a, b)
+}
+
+// leadingToken is the run of digits or the text up to the next digit that s starts with
+func leadingToken(s string) string {
+	if digits := leadingDigits(s); digits != "" {
+		return digits
+	}
+	end := strings.IndexAny(s, "0123456789")
+	if end < 0 {
+		return s
+	}
+	return s[:end]
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+// sectionName is the title of the section an image belongs to: its directory relative to the
+// input it was found in, or the directory's own name for images directly in an input
+func sectionName(sourcePath string, inputs []string) string {
+	dir := filepath.Dir(sourcePath)
+	if root := inputFor(sourcePath, inputs); root != "" {
+		if info, err := os.Stat(root); err == nil && info.IsDir() {
+			if rel, err := filepath.Rel(root, dir); err == nil && rel != "." {
+				return rel
+			}
+		}
+	}
+	if absDir, err := filepath.Abs(dir); err == nil {
+		dir = absDir
+	}
+	return filepath.Base(dir)
+}
+
+// addBookmarks writes the directory → image outline into the PDF. Failing to add it is
+// not fatal, the document is returned without bookmarks and a warning is logged.
+func addBookmarks(status io.Writer, data []byte, bookmarks []pdfcpu.Bookmark) []byte {
+	if len(bookmarks) == 0 {
+		return data
+	}
+	var withBookmarks bytes.Buffer
+	if err := api.AddBookmarks(bytes.NewReader(data), &withBookmarks, bookmarks, true, nil); err != nil {
+		logger.Warn("could not add section bookmarks", "error", err)
+		return data
+	}
+	fmt.Fprintf(status, "Added bookmarks for %d section(s)\n", len(bookmarks))
+	return withBookmarks.Bytes()
+}
@@ -0,0 +1,460 @@
+package imagestopdf
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// zipExpansionLimit bounds how much an uploaded zip may unpack to, relative to --max-upload
+const zipExpansionLimit = 4
+
+var (
+	serveAddr          string
+	serveMaxUpload     = byteSize(64 << 20)
+	serveMaxConcurrent int
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run an HTTP server that converts uploaded images to a PDF",
+	Long: `Starts an HTTP server exposing POST /convert, which accepts a multipart form of image files
+(or a single zip archive) and responds with the combined PDF. Conversion options are passed as form
+fields named like the command line flags (dpi, quality, page-size, sort, sort-case-insensitive, collate, ...).`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runServer(serveAddr, int64(serveMaxUpload), serveMaxConcurrent)
+	},
+}
+
+func init() {
+	flags := serveCmd.Flags()
+	flags.StringVar(&serveAddr, "addr", ":8080", "Address to listen on")
+	flags.Var(&serveMaxUpload, "max-upload", "Maximum size of a request body (e.g. 64MB)")
+	flags.IntVar(&serveMaxConcurrent, "max-concurrent", runtime.NumCPU(), "Maximum number of conversions running at the same time")
+	rootCmd.AddCommand(serveCmd)
+}
+
+// converter handles /convert requests, bounding concurrent conversions with a semaphore
+type converter struct {
+	maxUpload int64
+	slots     chan struct{}
+}
+
+// runServer serves until SIGINT/SIGTERM, then waits for in-flight conversions to finish
+func runServer(addr string, maxUpload int64, maxConcurrent int) error {
+	if maxUpload <= 0 {
+		return fmt.Errorf("invalid --max-upload %d, must be positive", maxUpload)
+	}
+	if maxConcurrent < 1 {
+		return fmt.Errorf("invalid --max-concurrent %d, must be at least 1", maxConcurrent)
+	}
+
+	conv := &converter{
+		maxUpload: maxUpload,
+		slots:     make(chan struct{}, maxConcurrent),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /convert", conv.handleConvert)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "ok")
+	})
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           logRequests(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := notifyInterrupt()
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("listening", "addr", addr, "max_upload", formatByteSize(maxUpload), "max_concurrent", maxConcurrent)
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutting down, waiting for running conversions")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+// handleConvert stores the uploaded images in a per-request temp dir, converts them and streams the PDF back
+func (c *converter) handleConvert(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, c.maxUpload)
+
+	select {
+	case c.slots <- struct{}{}:
+		defer func() { <-c.slots }()
+	case <-r.Context().Done():
+		return
+	}
+
+	workDir, err := os.MkdirTemp("", "images-to-pdf-")
+	if err != nil {
+		c.fail(w, r, http.StatusInternalServerError, fmt.Errorf("failed to create temp directory: %v", err))
+		return
+	}
+	defer os.RemoveAll(workDir)
+
+	inputDir := filepath.Join(workDir, "input")
+	if err := os.Mkdir(inputDir, 0755); err != nil {
+		c.fail(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		c.fail(w, r, http.StatusBadRequest, fmt.Errorf("expected a multipart/form-data upload: %v", err))
+		return
+	}
+
+	fields := make(map[string]string)
+	uploaded := 0
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			c.fail(w, r, uploadErrorStatus(err), fmt.Errorf("failed to read upload: %v", err))
+			return
+		}
+
+		if part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, 4096))
+			part.Close()
+			if err != nil {
+				c.fail(w, r, uploadErrorStatus(err), fmt.Errorf("failed to read form field %q: %v", part.FormName(), err))
+				return
+			}
+			fields[part.FormName()] = string(value)
+			continue
+		}
+
+		err = c.saveUpload(part, inputDir)
+		part.Close()
+		if err != nil {
+			c.fail(w, r, uploadErrorStatus(err), err)
+			return
+		}
+		uploaded++
+	}
+	if uploaded == 0 {
+		c.fail(w, r, http.StatusBadRequest, errors.New("no files uploaded"))
+		return
+	}
+
+	opts, err := optionsFromForm(fields)
+	if err != nil {
+		c.fail(w, r, http.StatusBadRequest, err)
+		return
+	}
+	opts.Inputs = []string{inputDir}
+	// Concurrent conversions would interleave their status lines on stdout
+	opts.Status = &statusLog{remote: r.RemoteAddr}
+
+	var pdf bytes.Buffer
+	result, err := Convert(r.Context(), &pdf, opts)
+	if err != nil {
+		c.fail(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	name := expandNameTemplate(opts.Name, inputDir, workDir, result.Pages, opts.now())
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Header().Set("Content-Length", strconv.Itoa(pdf.Len()))
+	pdf.WriteTo(w)
+}
+
+// saveUpload writes one uploaded file into inputDir, unpacking it first if it is a zip archive
+func (c *converter) saveUpload(part *multipart.Part, inputDir string) error {
+	name := filepath.Base(filepath.Clean("/" + filepath.ToSlash(part.FileName())))
+	if name == "/" || name == "." {
+		return fmt.Errorf("invalid file name %q", part.FileName())
+	}
+
+	target := filepath.Join(inputDir, name)
+	if err := writeNewFile(target, part); err != nil {
+		return fmt.Errorf("failed to store %s: %w", name, err)
+	}
+
+	if strings.ToLower(filepath.Ext(name)) != ".zip" {
+		return nil
+	}
+	defer os.Remove(target)
+	return extractZip(target, inputDir, c.maxUpload*zipExpansionLimit)
+}
+
+// extractZip unpacks archive into dir, rejecting entries that would escape it and stopping after limit bytes
+func extractZip(archive, dir string, limit int64) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("failed to open zip %s: %v", filepath.Base(archive), err)
+	}
+	defer zr.Close()
+
+	remaining := limit
+	for _, entry := range zr.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		cleaned := path.Clean(strings.ReplaceAll(entry.Name, "\\", "/"))
+		if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") || strings.Contains(cleaned, ":") {
+			return fmt.Errorf("zip entry %q points outside the archive", entry.Name)
+		}
+		if strings.HasPrefix(path.Base(cleaned), ".") || strings.HasPrefix(cleaned, "__MACOSX/") {
+			continue
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(cleaned))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		src, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read zip entry %s: %v", entry.Name, err)
+		}
+		limited := &io.LimitedReader{R: src, N: remaining + 1}
+		err = writeNewFile(target, limited)
+		src.Close()
+		if err != nil {
+			return fmt.Errorf("failed to extract %s: %w", entry.Name, err)
+		}
+		remaining = limited.N - 1
+		if remaining < 0 {
+			return &http.MaxBytesError{Limit: limit}
+		}
+	}
+	return nil
+}
+
+// writeNewFile copies r into a file that must not exist yet
+func writeNewFile(path string, r io.Reader) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("duplicate file name %s", filepath.Base(path))
+		}
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// optionsFromForm builds conversion options from form fields named like the CLI flags
+func optionsFromForm(fields map[string]string) (Options, error) {
+	opts := defaultOptions()
+	var err error
+
+	for key, value := range fields {
+		value = strings.TrimSpace(value)
+		switch key {
+		case "dpi":
+			opts.DPI, err = strconv.ParseFloat(value, 64)
+		case "quality":
+			opts.Quality, err = strconv.Atoi(value)
+		case "rotate":
+			opts.Rotate, err = strconv.Atoi(value)
+		case "strategy":
+			opts.Strategy = value
+		case "max-decode-pixels":
+			opts.MaxDecodePixels, err = strconv.ParseInt(value, 10, 64)
+		case "decode-timeout":
+			opts.DecodeTimeout, err = time.ParseDuration(value)
+		case "max-size":
+			opts.MaxSize, err = parseByteSize(value)
+		case "budget-mode":
+			opts.BudgetMode = value
+		case "quantize-colors":
+			opts.QuantizeColors, err = strconv.Atoi(value)
+		case "quantize-dither":
+			opts.QuantizeDither, err = strconv.ParseBool(value)
+		case "lossless":
+			var lossless bool
+			if lossless, err = strconv.ParseBool(value); lossless {
+				opts.Strategy = "lossless"
+			}
+		case "page-size":
+			opts.PageSize = value
+		case "booklet":
+			opts.Booklet, err = strconv.ParseBool(value)
+		case "page-basis":
+			opts.PageBasis = value
+		case "collate":
+			opts.CollateLocale = value
+		case "no-ignore-files":
+			opts.NoIgnoreFiles, err = strconv.ParseBool(value)
+		case "sort":
+			opts.Sort = value
+		case "sort-case-insensitive":
+			opts.SortCaseInsensitive, err = strconv.ParseBool(value)
+		case "background":
+			opts.Background, err = parseColor(value)
+		case "use-source-dpi":
+			opts.UseSourceDPI, err = strconv.ParseBool(value)
+		case "margin":
+			opts.Margin, err = parseLength(value)
+		case "border":
+			opts.Border, err = strconv.ParseBool(value)
+		case "border-width":
+			opts.BorderWidth, err = parseLength(value)
+		case "border-color":
+			opts.BorderColor, err = parseColor(value)
+		case "dither":
+			opts.Dither, err = strconv.ParseBool(value)
+		case "convert-srgb":
+			opts.ConvertSRGB, err = strconv.ParseBool(value)
+		case "strip-metadata":
+			opts.StripMetadata, err = strconv.ParseBool(value)
+		case "sharpen":
+			opts.Sharpen, err = strconv.ParseBool(value)
+		case "sharpen-amount":
+			opts.SharpenAmount, err = strconv.ParseFloat(value, 64)
+		case "linearize":
+			opts.Linearize, err = strconv.ParseBool(value)
+		case "tagged":
+			opts.Tagged, err = strconv.ParseBool(value)
+		case "lang":
+			opts.Lang = value
+		case "deterministic":
+			opts.Deterministic, err = strconv.ParseBool(value)
+		case "date":
+			opts.Date = value
+		case "skip-blank":
+			opts.SkipBlank, err = strconv.ParseBool(value)
+		case "blank-threshold":
+			opts.BlankThreshold, err = strconv.ParseFloat(value, 64)
+		case "blank-tolerance":
+			opts.BlankTolerance, err = strconv.Atoi(value)
+		case "blank-after-odd":
+			opts.BlankAfterOdd, err = strconv.ParseBool(value)
+		case "sections":
+			opts.Sections, err = strconv.ParseBool(value)
+		case "no-divider-pages":
+			opts.NoDividerPages, err = strconv.ParseBool(value)
+		case "name":
+			if value != filepath.Base(value) || strings.ContainsAny(value, `/\`) {
+				return opts, fmt.Errorf("invalid name %q, must be a plain file name", value)
+			}
+			if err := validateNameTemplate(value); err != nil {
+				return opts, err
+			}
+			opts.Name = value
+		default:
+			return opts, fmt.Errorf("unknown option %q", key)
+		}
+		if err != nil {
+			return opts, fmt.Errorf("invalid value %q for %s", value, key)
+		}
+	}
+
+	return opts, nil
+}
+
+// uploadErrorStatus maps upload read failures to 413 when the body was too large
+func uploadErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// fail logs err and sends it to the client as plain text
+func (c *converter) fail(w http.ResponseWriter, r *http.Request, status int, err error) {
+	logger.Warn("conversion failed", "path", r.URL.Path, "status", status, "error", err.Error())
+	http.Error(w, err.Error(), status)
+}
+
+// statusLog turns the status output of a request's conversion into debug records of the request
+// log, one per line
+type statusLog struct {
+	remote string
+	line   []byte
+}
+
+func (s *statusLog) Write(p []byte) (int, error) {
+	s.line = append(s.line, p...)
+	for {
+		end := bytes.IndexByte(s.line, '\n')
+		if end < 0 {
+			return len(p), nil
+		}
+		if message := strings.TrimSpace(string(s.line[:end])); message != "" {
+			logger.Debug("conversion status", "remote", s.remote, "message", message)
+		}
+		s.line = s.line[end+1:]
+	}
+}
+
+// statusRecorder captures the response status and size for request logging
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(p)
+	s.bytes += int64(n)
+	return n, err
+}
+
+// logRequests writes one structured log line per request
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		logger.Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"remote", r.RemoteAddr,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	})
+}
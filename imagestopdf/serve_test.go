@@ -0,0 +1,65 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"image/png"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOptionsFromForm(t *testing.T) {
+	opts, err := optionsFromForm(map[string]string{"sort": "natural", "sort-case-insensitive": "true", "page-size": "a4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Sort != "natural" || !opts.SortCaseInsensitive || opts.PageSize != "a4" {
+		t.Errorf("got sort %q, case-insensitive %v, page size %q", opts.Sort, opts.SortCaseInsensitive, opts.PageSize)
+	}
+	if opts, _ := optionsFromForm(nil); opts.Sort != "name" {
+		t.Errorf("default sort is %q, want name", opts.Sort)
+	}
+	if _, err := optionsFromForm(map[string]string{"sorting": "natural"}); err == nil {
+		t.Error("an unknown field should be rejected")
+	}
+}
+
+// TestServeStatusGoesToLog posts two images and expects the conversion's status lines in the
+// request log, not on stdout
+func TestServeStatusGoesToLog(t *testing.T) {
+	var logged bytes.Buffer
+	previous := logger
+	logger = slog.New(slog.NewTextHandler(&logged, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	t.Cleanup(func() { logger = previous })
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for _, name := range []string{"page10.png", "page2.png"} {
+		part, err := form.CreateFormFile("files", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := png.Encode(part, gradientImage(64, 48)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	form.WriteField("sort", "natural")
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/convert", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	conv := &converter{maxUpload: 1 << 20, slots: make(chan struct{}, 1)}
+	conv.handleConvert(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body.String())
+	}
+	log := logged.String()
+	if !strings.Contains(log, "Processing image 1/2: page2.png") || !strings.Contains(log, "remote="+req.RemoteAddr) {
+		t.Errorf("the request log is missing the status lines in natural order:\n%s", log)
+	}
+}
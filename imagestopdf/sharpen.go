@@ -0,0 +1,91 @@
+package imagestopdf
+
+import (
+	"image"
+	"math"
+)
+
+// sharpenSigma is the blur radius in pixels of the unsharp mask, small enough to only
+// restore the edges of text softened by downscaling
+const sharpenSigma = 1.0
+
+// gaussianKernel returns normalized weights of a Gaussian covering three standard deviations each side
+func gaussianKernel(sigma float64) []float32 {
+	radius := int(math.Ceil(3 * sigma))
+	kernel := make([]float32, 2*radius+1)
+	var sum float64
+	for i := range kernel {
+		x := float64(i - radius)
+		w := math.Exp(-x * x / (2 * sigma * sigma))
+		kernel[i] = float32(w)
+		sum += w
+	}
+	for i := range kernel {
+		kernel[i] /= float32(sum)
+	}
+	return kernel
+}
+
+// unsharpMask sharpens img in place: each color channel becomes c + amount*(c - blur(c)).
+// The blur is separable, a horizontal and a vertical pass over float buffers, so the cost
+// grows with the kernel width instead of its area. Alpha is left unchanged.
+func unsharpMask(img *image.RGBA, amount float64) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 || amount == 0 {
+		return
+	}
+	kernel := gaussianKernel(sharpenSigma)
+	radius := len(kernel) / 2
+
+	// Horizontal pass. Each row is copied into a float buffer padded with its edge pixels,
+	// the blur then adds whole shifted rows so the inner loops run over contiguous memory
+	horizontal := make([]float32, width*height*3)
+	padded := make([]float32, (width+2*radius)*3)
+	for y := 0; y < height; y++ {
+		row := img.Pix[y*img.Stride:]
+		for x := -radius; x < width+radius; x++ {
+			sx := min(max(x, 0), width-1) * 4
+			i := (x + radius) * 3
+			padded[i], padded[i+1], padded[i+2] = float32(row[sx]), float32(row[sx+1]), float32(row[sx+2])
+		}
+
+		out := horizontal[y*width*3 : (y+1)*width*3]
+		for k, w := range kernel {
+			src := padded[k*3 : k*3+len(out)]
+			for i, v := range src {
+				out[i] += w * v
+			}
+		}
+	}
+
+	// Vertical pass a row at a time so the reads stay sequential, then the weighted subtraction
+	a := float32(amount)
+	blur := make([]float32, width*3)
+	for y := 0; y < height; y++ {
+		clear(blur)
+		for k, w := range kernel {
+			sy := min(max(y+k-radius, 0), height-1)
+			src := horizontal[sy*width*3 : (sy+1)*width*3]
+			for i, v := range src {
+				blur[i] += w * v
+			}
+		}
+
+		row := img.Pix[y*img.Stride:]
+		for x := 0; x < width; x++ {
+			// Premultiplied channels may not exceed alpha
+			alpha := float32(row[x*4+3])
+			for c := 0; c < 3; c++ {
+				v := float32(row[x*4+c])
+				sharpened := v + a*(v-blur[x*3+c])
+				if sharpened < 0 {
+					sharpened = 0
+				} else if sharpened > alpha {
+					sharpened = alpha
+				}
+				row[x*4+c] = uint8(sharpened + 0.5)
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package imagestopdf
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// edgeImage returns a dark left half next to a light right half
+func edgeImage(width, height int) *image.RGBA {
+	img := solidImage(width, height, color.RGBA{200, 200, 200, 255})
+	for y := 0; y < height; y++ {
+		for x := 0; x < width/2; x++ {
+			img.SetRGBA(x, y, color.RGBA{60, 60, 60, 255})
+		}
+	}
+	return img
+}
+
+func TestGaussianKernel(t *testing.T) {
+	kernel := gaussianKernel(sharpenSigma)
+	var sum float64
+	for i, w := range kernel {
+		sum += float64(w)
+		if w != kernel[len(kernel)-1-i] {
+			t.Errorf("kernel isn't symmetric: %v", kernel)
+			break
+		}
+	}
+	if math.Abs(sum-1) > 1e-5 {
+		t.Errorf("weights sum to %g, want 1", sum)
+	}
+}
+
+// TestUnsharpMaskEdgeContrast checks the mask steepens edges and leaves flat areas alone
+func TestUnsharpMaskEdgeContrast(t *testing.T) {
+	// A blurred edge, as downscaling leaves it
+	img := image.NewRGBA(image.Rect(0, 0, 40, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 40; x++ {
+			v := uint8(60 + 140*max(0, min(1, float64(x-17)/6)))
+			img.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	before := int(img.RGBAAt(22, 4).R) - int(img.RGBAAt(18, 4).R)
+
+	unsharpMask(img, 0.5)
+
+	if after := int(img.RGBAAt(22, 4).R) - int(img.RGBAAt(18, 4).R); after <= before {
+		t.Errorf("contrast across the edge went from %d to %d, want it to increase", before, after)
+	}
+	if c := img.RGBAAt(2, 4); c.R != 60 || c.A != 255 {
+		t.Errorf("flat area changed to %v", c)
+	}
+	if c := img.RGBAAt(37, 4); c.R != 200 {
+		t.Errorf("flat area changed to %v", c)
+	}
+}
+
+// TestSharpenOnlyAfterDownscale checks --sharpen leaves images kept at their size byte-identical
+// and only changes the ones that were scaled down
+func TestSharpenOnlyAfterDownscale(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		width   int
+		changes bool
+	}{
+		{"kept at its size", optimizedWidth / 2, false},
+		{"downscaled", optimizedWidth * 2, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			// A PNG is re-encoded either way, the sharpening is the only difference
+			writePNG(t, filepath.Join(dir, "edge.png"), edgeImage(tc.width, tc.width/2))
+			opts := Options{Inputs: []string{dir}, Deterministic: true, Strategy: "jpeg", Rotate: 180}
+
+			plain := convertForTest(t, opts)
+			opts.Sharpen = true
+			sharpened := convertForTest(t, opts)
+
+			if changed := !bytes.Equal(plain, sharpened); changed != tc.changes {
+				t.Errorf("--sharpen changed the PDF: %v, want %v", changed, tc.changes)
+			}
+		})
+	}
+}
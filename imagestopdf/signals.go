@@ -0,0 +1,77 @@
+package imagestopdf
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// exitCodeInterrupted is the conventional exit code for a run stopped by SIGINT
+const exitCodeInterrupted = 130
+
+// notifyInterrupt returns a context that is cancelled on the first SIGINT/SIGTERM so the
+// current image can finish and temporary files get cleaned up. A second signal exits immediately.
+func notifyInterrupt() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 2)
+	done := make(chan struct{})
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-sigs:
+		case <-done:
+			return
+		}
+
+		fmt.Fprintln(os.Stderr, "\nInterrupted, finishing the current image and cleaning up (press Ctrl+C again to force exit)")
+		cancel()
+
+		select {
+		case <-sigs:
+			fmt.Fprintln(os.Stderr, "Forced exit")
+			removeForcedExitPaths()
+			os.Exit(exitCodeInterrupted)
+		case <-done:
+		}
+	}()
+
+	stop := func() {
+		signal.Stop(sigs)
+		close(done)
+		cancel()
+	}
+	return ctx, stop
+}
+
+// forcedExitPaths are removed when a second signal skips the deferred cleanup
+var forcedExitPaths = struct {
+	sync.Mutex
+	paths map[string]bool
+}{paths: map[string]bool{}}
+
+// removeOnForcedExit makes sure path is deleted even if the run is force-exited,
+// the returned function unregisters it once the normal cleanup has taken over
+func removeOnForcedExit(path string) func() {
+	forcedExitPaths.Lock()
+	forcedExitPaths.paths[path] = true
+	forcedExitPaths.Unlock()
+
+	return func() {
+		forcedExitPaths.Lock()
+		delete(forcedExitPaths.paths, path)
+		forcedExitPaths.Unlock()
+	}
+}
+
+// removeForcedExitPaths deletes every registered path
+func removeForcedExitPaths() {
+	forcedExitPaths.Lock()
+	defer forcedExitPaths.Unlock()
+	for path := range forcedExitPaths.paths {
+		os.RemoveAll(path)
+	}
+}
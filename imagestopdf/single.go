@@ -0,0 +1,137 @@
+package imagestopdf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// validateOnePerImage rejects options that combine pages into one document, which --one-per-image can't honor
+func validateOnePerImage(o Options) error {
+	if !o.OnePerImage {
+		return nil
+	}
+	conflicts := []struct {
+		set  bool
+		flag string
+	}{
+		{o.BatchSize > 0, "--batch-size"},
+		{o.InputDir2 != "", "--input2"},
+		{o.Sections, "--sections"},
+		{o.Booklet, "--booklet"},
+		{o.BlankAfterOdd, "--blank-after-odd"},
+		{o.InsertBlankFile != "", "--insert-blank"},
+		{o.OutputDir == "-", "--output -"},
+		{o.PageSize != "auto", "--page-size " + o.PageSize},
+	}
+	for _, conflict := range conflicts {
+		if conflict.set {
+			return fmt.Errorf("--one-per-image writes a single-page PDF per image, it can't be combined with %s", conflict.flag)
+		}
+	}
+	if o.ManifestPath != "" && o.ManifestPath != defaultManifestPath {
+		return fmt.Errorf("--one-per-image writes a manifest next to each PDF, use --manifest without a path")
+	}
+	if o.ReportPath != "" && o.ReportPath != defaultReportPath {
+		return fmt.Errorf("--one-per-image writes a report next to each PDF, use --report without a path")
+	}
+	return nil
+}
+
+// perImageNames returns the PDF name of each image for --one-per-image: its base name with a .pdf
+// extension. Images whose names collide once the extension is gone, like a.png and a.jpg, keep
+// their extension in the name (a_png.pdf, a_jpg.pdf), and any clash left after that, such as the
+// same file name in two directories, is numbered.
+func perImageNames(images []string) []string {
+	stem := func(imagePath string) string {
+		base := filepath.Base(imagePath)
+		return strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	// Compared case-insensitively, the output may land on a case-insensitive file system
+	stems := map[string]int{}
+	for _, imagePath := range images {
+		stems[strings.ToLower(stem(imagePath))]++
+	}
+
+	names := make([]string, len(images))
+	used := map[string]bool{}
+	for i, imagePath := range images {
+		name := stem(imagePath)
+		if stems[strings.ToLower(name)] > 1 {
+			name += "_" + strings.TrimPrefix(strings.ToLower(filepath.Ext(imagePath)), ".")
+		}
+		candidate := name
+		for n := 2; used[strings.ToLower(candidate)]; n++ {
+			candidate = fmt.Sprintf("%s_%d", name, n)
+		}
+		used[strings.ToLower(candidate)] = true
+		names[i] = candidate + ".pdf"
+	}
+	return names
+}
+
+// convertOnePerImage discovers the images once and writes each to its own single-page PDF named
+// after it. Every document is sized to its image and gets --max-size to itself. Images that can't
+// be converted are skipped like in a combined run, the run fails only when none could.
+func convertOnePerImage(ctx context.Context, opts Options) error {
+	images, err := discoverImages(ctx, opts.Inputs, newRetrier(opts.Retries), opts)
+	if err != nil {
+		return err
+	}
+	if images, err = pageOrder(images, opts); err != nil {
+		return err
+	}
+	names := perImageNames(images)
+	inputDir := primaryInputDir(opts.Inputs)
+	fmt.Fprintf(opts.status(), "Found %d image files, writing one PDF per image\n", len(images))
+
+	if opts.NoOverwrite {
+		for _, name := range names {
+			path := filepath.Join(opts.OutputDir, name)
+			if _, err := os.Stat(path); err == nil {
+				return fmt.Errorf("%w: %s", ErrOutputExists, path)
+			}
+		}
+	}
+
+	var written, skipped []string
+	for i, imagePath := range images {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		imageOpts := opts
+		imageOpts.batch = []string{imagePath}
+		imageOpts.Name = names[i]
+		fmt.Fprintf(opts.status(), "Image %d/%d: %s\n", i+1, len(images), filepath.Base(imagePath))
+		err := writePDF(ctx, imageOpts)
+		if errors.Is(err, ErrAllImagesFailed) {
+			skipped = append(skipped, filepath.Base(imagePath))
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", imagePath, err)
+		}
+		keys := sourceNameKeys(imagePath, inputDir)
+		written = append(written, fmt.Sprintf("%s ← %s", filepath.Join(opts.OutputDir, names[i]), keys[len(keys)-1]))
+	}
+
+	fmt.Fprintf(opts.status(), "Wrote %d PDF(s):\n", len(written))
+	for _, line := range written {
+		fmt.Fprintf(opts.status(), "  • %s\n", line)
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(opts.status(), "⚠️  No PDF for %d image(s) that were skipped or failed:\n", len(skipped))
+		for _, name := range skipped {
+			fmt.Fprintf(opts.status(), "  • %s\n", name)
+		}
+	}
+	if len(written) == 0 {
+		return fmt.Errorf("%w (%d tried)", ErrAllImagesFailed, len(images))
+	}
+	return nil
+}
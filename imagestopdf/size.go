@@ -0,0 +1,71 @@
+package imagestopdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// byteSize is a flag value holding a size in bytes, written as e.g. "32MB", "512KiB" or "1048576"
+type byteSize int64
+
+// byteSizeUnits maps size suffixes to multipliers. Decimal and binary suffixes are both treated as powers of 1024,
+// matching how file sizes are reported elsewhere in the tool
+var byteSizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"gib", 1 << 30}, {"mib", 1 << 20}, {"kib", 1 << 10},
+	{"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10},
+	{"g", 1 << 30}, {"m", 1 << 20}, {"k", 1 << 10},
+	{"b", 1},
+}
+
+// parseByteSize parses a size with an optional unit suffix
+func parseByteSize(s string) (int64, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected a number with an optional KB, MB or GB suffix", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// formatByteSize renders a size using the largest unit that keeps the value at or above 1
+func formatByteSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return strconv.FormatFloat(float64(n)/(1<<30), 'f', -1, 64) + "GB"
+	case n >= 1<<20:
+		return strconv.FormatFloat(float64(n)/(1<<20), 'f', -1, 64) + "MB"
+	case n >= 1<<10:
+		return strconv.FormatFloat(float64(n)/(1<<10), 'f', -1, 64) + "KB"
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
+
+func (b *byteSize) String() string {
+	return formatByteSize(int64(*b))
+}
+
+func (b *byteSize) Set(s string) error {
+	n, err := parseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = byteSize(n)
+	return nil
+}
+
+func (b *byteSize) Type() string {
+	return "size"
+}
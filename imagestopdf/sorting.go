@@ -0,0 +1,104 @@
+package imagestopdf
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// sortValues are the accepted --sort values
+var sortValues = []string{"name", "natural"}
+
+// validateSort checks the --sort value
+func validateSort(mode string) error {
+	for _, value := range sortValues {
+		if mode == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid sort %q, valid values are: %s", mode, strings.Join(sortValues, ", "))
+}
+
+// sortImageFiles orders the discovered files by path, optionally comparing numbers by value,
+// ignoring case or using a locale's collation
+func sortImageFiles(files []string, natural, caseInsensitive bool, locale string) error {
+	compare, err := newPathComparator(natural, caseInsensitive, locale)
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return compare(files[i], files[j]) < 0
+	})
+	return nil
+}
+
+// newPathComparator builds the path comparison for sortImageFiles. By default paths compare byte
+// by byte, the order sort.Strings gives, and ignoring case keeps that order on the lowercased paths.
+// Natural sorting, which compares runs of digits by their value so "page2" sorts before "page10",
+// and collation work component by component, so directory separators don't take part in them.
+// Paths that compare equal fall back to a byte-wise comparison, keeping the page order identical
+// between runs.
+func newPathComparator(natural, caseInsensitive bool, locale string) (func(a, b string) int, error) {
+	compareText := strings.Compare
+	if caseInsensitive {
+		compareText = func(a, b string) int {
+			return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+		}
+	}
+
+	var compareName func(a, b string) int
+	switch {
+	case locale != "":
+		tag, err := language.Parse(locale)
+		if err != nil {
+			return nil, fmt.Errorf("invalid collation locale %q: %v", locale, err)
+		}
+		var options []collate.Option
+		if caseInsensitive {
+			options = append(options, collate.IgnoreCase)
+		}
+		collator := collate.New(tag, options...)
+		compareName = collator.CompareString
+		if natural {
+			// Numbers outrank differences in case and accents, as letters do in the collation itself,
+			// so those only break ties
+			loose := collate.New(tag, collate.Loose)
+			compareName = func(a, b string) int {
+				if c := naturalCompareWith(a, b, loose.CompareString); c != 0 {
+					return c
+				}
+				return naturalCompareWith(a, b, collator.CompareString)
+			}
+		}
+	case natural:
+		compareName = func(a, b string) int {
+			return naturalCompareWith(a, b, compareText)
+		}
+	default:
+		return func(a, b string) int {
+			if c := compareText(a, b); c != 0 {
+				return c
+			}
+			return strings.Compare(a, b)
+		}, nil
+	}
+
+	return func(a, b string) int {
+		partsA := strings.Split(filepath.ToSlash(a), "/")
+		partsB := strings.Split(filepath.ToSlash(b), "/")
+		for i := 0; i < len(partsA) && i < len(partsB); i++ {
+			if c := compareName(partsA[i], partsB[i]); c != 0 {
+				return c
+			}
+		}
+		if len(partsA) != len(partsB) {
+			return len(partsA) - len(partsB)
+		}
+		return strings.Compare(a, b)
+	}, nil
+}
@@ -0,0 +1,111 @@
+package imagestopdf
+
+import (
+	"slices"
+	"sort"
+	"testing"
+)
+
+// TestSortDefaultIsByteOrder checks the default against sort.Strings on names where comparing
+// component by component would differ: '-' and '.' sort before the '/' separator
+func TestSortDefaultIsByteOrder(t *testing.T) {
+	files := []string{
+		"scans/a/2.jpg", "scans/a-b/1.jpg", "scans/a.b/1.jpg", "scans/a/10.jpg",
+		"scans/Page10.jpg", "scans/page2.jpg", "scans/Page2.jpg", "scans/a b/1.jpg", "scans/ä.jpg",
+	}
+	want := slices.Clone(files)
+	sort.Strings(want)
+
+	for i := 0; i < 3; i++ {
+		got := slices.Clone(files)
+		slices.Reverse(got)
+		if i == 1 {
+			got[0], got[4] = got[4], got[0]
+		}
+		if err := sortImageFiles(got, false, false, ""); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("got %q, want sort.Strings order %q", got, want)
+		}
+	}
+}
+
+func TestPathComparator(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		natural, noCase bool
+		locale          string
+		files, want     []string
+	}{
+		{
+			name:   "case-insensitive keeps byte order of the lowercased paths",
+			noCase: true,
+			files:  []string{"page2.jpg", "Page10.jpg", "b.jpg", "A.jpg", "a.jpg"},
+			want:   []string{"A.jpg", "a.jpg", "b.jpg", "Page10.jpg", "page2.jpg"},
+		},
+		{
+			name:   "case-insensitive doesn't split at separators",
+			noCase: true,
+			files:  []string{"A/1.jpg", "a-b/1.jpg"},
+			want:   []string{"a-b/1.jpg", "A/1.jpg"},
+		},
+		{
+			name:    "natural",
+			natural: true,
+			files:   []string{"page10.jpg", "page2.jpg", "page1.jpg", "Page3.jpg"},
+			want:    []string{"Page3.jpg", "page1.jpg", "page2.jpg", "page10.jpg"},
+		},
+		{
+			name:    "natural and case-insensitive",
+			natural: true, noCase: true,
+			files: []string{"page10.jpg", "Page2.jpg", "page1.jpg"},
+			want:  []string{"page1.jpg", "Page2.jpg", "page10.jpg"},
+		},
+		{
+			name:    "natural works per component",
+			natural: true,
+			files:   []string{"ch10/1.jpg", "ch2/10.jpg", "ch2/9.jpg"},
+			want:    []string{"ch2/9.jpg", "ch2/10.jpg", "ch10/1.jpg"},
+		},
+		{
+			name:   "collate",
+			locale: "de",
+			files:  []string{"z.jpg", "b.jpg", "ä.jpg", "a.jpg"},
+			want:   []string{"a.jpg", "ä.jpg", "b.jpg", "z.jpg"},
+		},
+		{
+			name:   "collate compares digits as text",
+			locale: "de",
+			files:  []string{"Seite2.jpg", "Seite10.jpg"},
+			want:   []string{"Seite10.jpg", "Seite2.jpg"},
+		},
+		{
+			name:    "collate and natural",
+			locale:  "de",
+			natural: true,
+			files:   []string{"Über10.jpg", "über2.jpg", "uber3.jpg"},
+			want:    []string{"über2.jpg", "uber3.jpg", "Über10.jpg"},
+		},
+		{
+			name:   "collated ties fall back to byte order",
+			locale: "de", noCase: true,
+			files: []string{"a.jpg", "A.jpg"},
+			want:  []string{"A.jpg", "a.jpg"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := slices.Clone(tc.files)
+			if err := sortImageFiles(got, tc.natural, tc.noCase, tc.locale); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := newPathComparator(false, false, "not a locale!"); err == nil {
+		t.Error("an invalid locale should fail")
+	}
+}
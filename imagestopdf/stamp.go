@@ -0,0 +1,165 @@
+package imagestopdf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/johnfercher/go-tree/node"
+	"github.com/johnfercher/maroto/v2/pkg/consts/align"
+	"github.com/johnfercher/maroto/v2/pkg/consts/fontfamily"
+	"github.com/johnfercher/maroto/v2/pkg/consts/linestyle"
+	"github.com/johnfercher/maroto/v2/pkg/consts/orientation"
+	"github.com/johnfercher/maroto/v2/pkg/core"
+	"github.com/johnfercher/maroto/v2/pkg/core/entity"
+	"github.com/johnfercher/maroto/v2/pkg/props"
+)
+
+// dateStampPositions are the accepted --date-stamp-position values
+var dateStampPositions = []string{"top-left", "top-right", "bottom-left", "bottom-right"}
+
+const (
+	// exifDateLayout is how EXIF writes dates, in the camera's local time without a zone
+	exifDateLayout = "2006:01:02 15:04:05"
+	// dateStampSize is the font size of the stamp in points
+	dateStampSize = 8
+	// dateStampPadding is the space between the stamp's text and its box, dateStampInset between
+	// the box and the image corner, both in millimeters
+	dateStampPadding = 1.0
+	dateStampInset   = 2.0
+	// dateStampCharWidth estimates the advance of a character in ems, Helvetica's digits take 0.556
+	dateStampCharWidth = 0.6
+)
+
+// validateDateStamp checks --date-stamp-position and --date-stamp-format
+func validateDateStamp(o Options) error {
+	valid := false
+	for _, position := range dateStampPositions {
+		valid = valid || o.DateStampPosition == position
+	}
+	if !valid {
+		return fmt.Errorf("invalid date stamp position %q, valid values are: %s", o.DateStampPosition, strings.Join(dateStampPositions, ", "))
+	}
+	// A layout without any reference-time element would print itself on every page
+	if time.Unix(0, 0).UTC().Format(o.DateStampFormat) == o.DateStampFormat {
+		return fmt.Errorf("invalid date stamp format %q, use a Go reference-time layout such as 2006-01-02 or \"Jan 2, 2006 15:04\"", o.DateStampFormat)
+	}
+	return nil
+}
+
+// exifCaptureTime returns the DateTimeOriginal of a JPEG's EXIF data, stored in the Exif sub-IFD
+func exifCaptureTime(data []byte) (time.Time, bool) {
+	tiff, order, entries := exifIFD0(data)
+	for _, entry := range entries {
+		if order.Uint16(entry) != 0x8769 { // ExifIFDPointer
+			continue
+		}
+		ifd := int(order.Uint32(entry[8:]))
+		if ifd < 8 || ifd+2 > len(tiff) {
+			return time.Time{}, false
+		}
+		for i := 0; i < int(order.Uint16(tiff[ifd:])); i++ {
+			field := ifd + 2 + i*12
+			if field+12 > len(tiff) {
+				return time.Time{}, false
+			}
+			if order.Uint16(tiff[field:]) != 0x9003 { // DateTimeOriginal, ASCII of 20 bytes
+				continue
+			}
+			offset := int(order.Uint32(tiff[field+8:]))
+			if offset < 0 || offset+len(exifDateLayout) > len(tiff) {
+				return time.Time{}, false
+			}
+			captured, err := time.ParseInLocation(exifDateLayout, string(tiff[offset:offset+len(exifDateLayout)]), time.Local)
+			return captured, err == nil
+		}
+	}
+	return time.Time{}, false
+}
+
+// captureTime is when the photo was taken according to its EXIF data, or else the file's
+// modification time. Archives written for reproducible builds set every time to the epoch,
+// those count as unknown and give the zero time.
+func captureTime(data []byte, info os.FileInfo) time.Time {
+	if captured, ok := exifCaptureTime(data); ok {
+		return captured
+	}
+	if info == nil || info.ModTime().Unix() <= 0 {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// dateStamp draws a date in a corner of an image placed centered in its cell, the way pageCol
+// places images. The text sits on a white box with a thin outline, so it stays readable on
+// light and dark corners alike.
+type dateStamp struct {
+	text          string
+	width, height int     // pixel size of the stamped image, only its aspect ratio matters
+	percent       float64 // share of the cell the image fills, as in its props.Rect
+	position      string
+	config        *entity.Config
+}
+
+// newDateStamp returns the stamp component for an image of the given pixel size
+func newDateStamp(text string, width, height int, percent float64, position string) core.Component {
+	return &dateStamp{text: text, width: width, height: height, percent: percent, position: position}
+}
+
+// Render draws the box and the text inside the image's corner
+func (s *dateStamp) Render(provider core.Provider, cell *entity.Cell) {
+	if s.width <= 0 || s.height <= 0 {
+		return
+	}
+	image := placedImage(cell, s.width, s.height, s.percent)
+	font := props.Text{Family: fontfamily.Helvetica, Size: dateStampSize, Align: align.Center, Color: &props.Color{Red: 20, Green: 20, Blue: 20}}
+	fontHeight := provider.GetFontHeight(&props.Font{Family: font.Family, Size: font.Size})
+
+	box := &entity.Cell{
+		Width:  float64(len([]rune(s.text)))*dateStampCharWidth*dateStampSize*mmPerPoint + 2*dateStampPadding,
+		Height: fontHeight + 2*dateStampPadding,
+	}
+	box.X = image.X + dateStampInset
+	if strings.HasSuffix(s.position, "right") {
+		box.X = image.X + image.Width - dateStampInset - box.Width
+	}
+	box.Y = image.Y + dateStampInset
+	if strings.HasPrefix(s.position, "bottom") {
+		box.Y = image.Y + image.Height - dateStampInset - box.Height
+	}
+
+	// A line as thick as the box fills it
+	white := props.WhiteColor
+	provider.AddLine(box, &props.Line{
+		Orientation: orientation.Horizontal, OffsetPercent: 50, SizePercent: 100,
+		Style: linestyle.Solid, Thickness: box.Height, Color: &white,
+	})
+	outline := &props.Color{Red: 128, Green: 128, Blue: 128}
+	drawRectangle(provider, box, 0.25*mmPerPoint, outline)
+
+	// The baseline lies a font height below Top, moving it up centers digits in the box
+	font.Top = dateStampPadding - 0.15*fontHeight
+	provider.AddText(s.text, box, &font)
+}
+
+// GetHeight is the height of the stamp, it always takes the height of its cell
+func (s *dateStamp) GetHeight(provider core.Provider, cell *entity.Cell) float64 {
+	return cell.Height
+}
+
+// SetConfig keeps the document configuration, the stamp doesn't depend on it
+func (s *dateStamp) SetConfig(config *entity.Config) {
+	s.config = config
+}
+
+// GetStructure describes the stamp for maroto's document structure
+func (s *dateStamp) GetStructure() *node.Node[core.Structure] {
+	return node.New(core.Structure{
+		Type:  "date_stamp",
+		Value: s.text,
+		Details: map[string]interface{}{
+			"position": s.position,
+		},
+	})
+}
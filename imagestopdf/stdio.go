@@ -0,0 +1,185 @@
+package imagestopdf
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// tarOrderValues are the accepted --tar-order values
+var tarOrderValues = []string{"sorted", "archive"}
+
+// validateStdio checks --stdin-tar, --tar-order and what can be combined with --output -
+func validateStdio(o Options) error {
+	switch o.TarOrder {
+	case "sorted":
+	case "archive":
+		if !o.StdinTar {
+			return fmt.Errorf("--tar-order archive needs --stdin-tar")
+		}
+		if o.OrderFile != "" {
+			return fmt.Errorf("--tar-order archive can't be combined with --order-file")
+		}
+	default:
+		return fmt.Errorf("invalid tar order %q, valid values are: %s", o.TarOrder, strings.Join(tarOrderValues, ", "))
+	}
+	if o.StdinTar && len(o.Inputs) > 0 {
+		return fmt.Errorf("--stdin-tar reads the images from standard input, it can't be combined with --input")
+	}
+
+	if o.OutputDir != "-" {
+		return nil
+	}
+	switch {
+	case o.BatchSize > 0:
+		return fmt.Errorf("--output - writes a single PDF, it can't be combined with --batch-size")
+	case o.Checksum:
+		return fmt.Errorf("--checksum needs an output file, it can't be combined with --output -")
+	case o.ManifestPath == defaultManifestPath:
+		return fmt.Errorf("--manifest needs a path with --output -")
+	case o.ReportPath == defaultReportPath:
+		return fmt.Errorf("--report needs a path with --output -")
+	}
+	return nil
+}
+
+// tarEntryName returns the slash-separated path of a tar entry, rejecting absolute paths and ..
+// segments, which would place the file outside the extraction directory
+func tarEntryName(name string) (string, error) {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	if path.IsAbs(slashed) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("tar entry %q has an absolute path", name)
+	}
+	for _, segment := range strings.Split(slashed, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("tar entry %q points outside the archive", name)
+		}
+	}
+	return path.Clean(slashed), nil
+}
+
+// extractImageTar writes the images of a tar stream, gzip-compressed or not, into dir and returns
+// their paths relative to dir in archive order. Anything but a regular file with a supported image
+// extension is skipped, an entry with an unsafe path fails the whole stream.
+func extractImageTar(r io.Reader, dir string) ([]string, error) {
+	buffered := bufio.NewReader(r)
+	var stream io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip stream: %v", err)
+		}
+		defer gz.Close()
+		stream = gz
+	}
+
+	var names []string
+	seen := map[string]bool{}
+	archive := tar.NewReader(stream)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar stream: %v", err)
+		}
+		name, err := tarEntryName(header.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !header.FileInfo().Mode().IsRegular() || !supportedExts[strings.ToLower(path.Ext(name))] {
+			continue
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+		file, err := os.Create(target)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(file, archive)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %v", name, err)
+		}
+		// Keep the archived time, --date-stamp falls back to it for images without EXIF dates
+		if err := os.Chtimes(target, header.ModTime, header.ModTime); err != nil {
+			return nil, err
+		}
+
+		// A later entry with the same name replaces the file but keeps its place
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// readStdinTar extracts the images piped in for --stdin-tar into runDir and points opts at them.
+// With --tar-order archive the entry order is kept by writing it as the run's order file.
+func readStdinTar(r io.Reader, runDir string, opts Options) (Options, error) {
+	inputDir := filepath.Join(runDir, "input")
+	names, err := extractImageTar(r, inputDir)
+	if err != nil {
+		return opts, err
+	}
+	if len(names) == 0 {
+		return opts, fmt.Errorf("%w: standard input", ErrNoImages)
+	}
+	fmt.Fprintf(opts.status(), "Extracted %d image(s) from standard input\n", len(names))
+
+	opts.Inputs = []string{inputDir}
+	if opts.TarOrder == "archive" {
+		orderPath := filepath.Join(runDir, "archive-order.txt")
+		if err := os.WriteFile(orderPath, []byte(strings.Join(names, "\n")+"\n"), 0644); err != nil {
+			return opts, fmt.Errorf("failed to write archive order: %v", err)
+		}
+		opts.OrderFile = orderPath
+	}
+	return opts, nil
+}
+
+// writePDFToStdout converts the images and writes the PDF to standard output for --output -.
+// The optimized copies go to a temp directory, a manifest or report only to an explicit path.
+func writePDFToStdout(ctx context.Context, opts Options) error {
+	tempDir, err := os.MkdirTemp("", "images-to-pdf-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer cleanupConvertedImages(opts.status(), tempDir)
+	defer removeOnForcedExit(tempDir)()
+	result, err := buildPDF(ctx, opts, tempDir)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, err := os.Stdout.Write(result.data); err != nil {
+		return fmt.Errorf("%w to standard output: %v", ErrSaveFailed, err)
+	}
+	sum := sha256.Sum256(result.data)
+	if err := writeManifestAndReport(opts, "-", hex.EncodeToString(sum[:]), result.pages); err != nil {
+		return err
+	}
+
+	reportFileSize(opts.status(), int64(len(result.data)), opts.MaxSize, result.projected)
+	fmt.Fprintf(opts.status(), "Successfully wrote PDF to standard output\n")
+	return nil
+}
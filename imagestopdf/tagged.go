@@ -0,0 +1,317 @@
+package imagestopdf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"golang.org/x/text/language"
+)
+
+// validateTagged checks the --tagged options. Tagging rewrites the PDF with pdfcpu, which stamps
+// the current time, and needs one image per page to keep the reading order.
+func validateTagged(o Options) error {
+	if !o.Tagged {
+		if o.AltFile != "" {
+			return fmt.Errorf("--alt-file only applies to --tagged output")
+		}
+		return nil
+	}
+	if o.Deterministic {
+		return fmt.Errorf("--tagged rewrites the PDF with the current time, it can't be combined with --deterministic")
+	}
+	if o.Booklet {
+		return fmt.Errorf("--tagged needs pages in reading order, it can't be combined with --booklet")
+	}
+	if _, err := language.Parse(o.Lang); err != nil {
+		return fmt.Errorf("invalid document language %q: %v", o.Lang, err)
+	}
+	return nil
+}
+
+// altTextList maps source image names to the alternate text of their figure
+type altTextList map[string]string
+
+// loadAltTexts reads an --alt-file with lines like "IMG_0042.jpg<TAB>Signed contract, page 1".
+// Names are either base names or paths relative to the input directory; lines starting with #
+// are comments.
+func loadAltTexts(path string) (altTextList, error) {
+	texts := altTextList{}
+	if path == "" {
+		return texts, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// The tab separates the name so both the name and the text may contain spaces
+		name, text, ok := strings.Cut(line, "\t")
+		name, text = strings.TrimSpace(name), strings.TrimSpace(text)
+		if !ok || name == "" || text == "" {
+			return nil, fmt.Errorf("%s:%d: expected \"<file><TAB><text>\"", path, lineNum)
+		}
+		texts[filepath.ToSlash(filepath.Clean(name))] = text
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return texts, nil
+}
+
+// altFor returns the alternate text of an image: its --alt-file entry, else its EXIF
+// ImageDescription, else its file name without the extension
+func (a altTextList) altFor(img optimizedImage, inputDir string) string {
+	for _, key := range sourceNameKeys(img.sourcePath, inputDir) {
+		if text, ok := a[key]; ok {
+			return text
+		}
+	}
+	if img.description != "" {
+		return img.description
+	}
+	base := filepath.Base(img.sourcePath)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// warnUnmatched prints a warning for every entry that doesn't refer to a selected image
+func (a altTextList) warnUnmatched(imageFiles []string, inputDir string) {
+	matched := map[string]bool{}
+	for _, imagePath := range imageFiles {
+		for _, key := range sourceNameKeys(imagePath, inputDir) {
+			matched[key] = true
+		}
+	}
+	var unmatched []string
+	for name := range a {
+		if !matched[name] {
+			unmatched = append(unmatched, name)
+		}
+	}
+	sort.Strings(unmatched)
+	for _, name := range unmatched {
+		logger.Warn("alt text entry doesn't match any selected image", "entry", name)
+	}
+}
+
+// exifDescription returns the ImageDescription of a JPEG's EXIF data, or "" if it has none.
+// Cameras that fill it with spaces count as having none.
+func exifDescription(data []byte) string {
+	tiff, order, entries := exifIFD0(data)
+	for _, entry := range entries {
+		if order.Uint16(entry) != 0x010E || order.Uint16(entry[2:]) != 2 { // ImageDescription, ASCII
+			continue
+		}
+		count := int(order.Uint32(entry[4:]))
+		value := entry[8:12]
+		if count > 4 {
+			offset := int(order.Uint32(entry[8:]))
+			if offset < 8 || offset+count > len(tiff) {
+				return ""
+			}
+			value = tiff[offset : offset+count]
+		} else {
+			value = value[:count]
+		}
+		return strings.TrimSpace(strings.ToValidUTF8(strings.TrimRight(string(value), "\x00"), ""))
+	}
+	return ""
+}
+
+var (
+	// imageDrawLine is how gofpdf draws an image: a transformation scaling the unit square to the
+	// image's place on the page, then the image XObject, in a graphics state of its own
+	imageDrawLine = regexp.MustCompile(`^q [-0-9. ]+ cm /\S+ Do Q$`)
+	// textShowLine is a text object showing text, as gofpdf writes one for each cell of text
+	textShowLine = regexp.MustCompile(`^BT .*\bT[jJ] ET$`)
+)
+
+// tagContent marks up the content stream of a page gofpdf wrote, one operation per line. Image
+// draws become Figure marked content and, on divider pages, the title an H1; the rest, such as
+// borders and date stamps, becomes artifacts screen readers skip. It returns the rewritten content
+// and the structure type of each marked-content ID.
+func tagContent(content []byte, divider bool) ([]byte, []string, error) {
+	var tagged bytes.Buffer
+	var roles []string
+	var artifact []string
+	depth := 0
+	flush := func() {
+		if strings.TrimSpace(strings.Join(artifact, "")) != "" {
+			tagged.WriteString("/Artifact BMC\n")
+			for _, line := range artifact {
+				tagged.WriteString(line + "\n")
+			}
+			tagged.WriteString("EMC\n")
+		}
+		artifact = nil
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(string(content), "\n"), "\n") {
+		role := ""
+		switch trimmed := strings.TrimSpace(line); {
+		case imageDrawLine.MatchString(trimmed):
+			role = "Figure"
+		case textShowLine.MatchString(trimmed):
+			if divider {
+				role = "H1"
+			}
+		default:
+			// Marked content has to nest with the graphics state saves and restores
+			for _, op := range strings.Fields(trimmed) {
+				switch op {
+				case "q":
+					depth++
+				case "Q":
+					depth--
+				}
+			}
+		}
+		if role == "" {
+			artifact = append(artifact, line)
+			continue
+		}
+		if depth != 0 {
+			return nil, nil, fmt.Errorf("content drawn inside a saved graphics state can't be tagged")
+		}
+		flush()
+		fmt.Fprintf(&tagged, "/%s <</MCID %d>> BDC\n%s\nEMC\n", role, len(roles), line)
+		roles = append(roles, role)
+	}
+	flush()
+	return tagged.Bytes(), roles, nil
+}
+
+// tagPDF turns the generated PDF into a tagged one: a structure tree with a Figure carrying the
+// alternate text of each image page and a heading for each divider page, in page order, and lang
+// as the document language. pages are the laid out pages, one per page of the PDF.
+func tagPDF(status io.Writer, data []byte, pages []page, lang string) ([]byte, error) {
+	pdf, err := api.ReadContext(bytes.NewReader(data), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := pdf.EnsurePageCount(); err != nil {
+		return nil, err
+	}
+	if pdf.PageCount != len(pages) {
+		return nil, fmt.Errorf("the PDF has %d page(s), %d were laid out", pdf.PageCount, len(pages))
+	}
+	xref := pdf.XRefTable
+	catalog, err := xref.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	treeRoot := types.Dict{"Type": types.Name("StructTreeRoot")}
+	treeRootRef, err := xref.IndRefForNewObject(treeRoot)
+	if err != nil {
+		return nil, err
+	}
+	document := types.Dict{"Type": types.Name("StructElem"), "S": types.Name("Document"), "P": *treeRootRef}
+	documentRef, err := xref.IndRefForNewObject(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var elements, parentTree types.Array
+	figures := 0
+	for i, p := range pages {
+		pageDict, pageRef, _, err := xref.PageDict(i+1, false)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %v", i+1, err)
+		}
+		content, err := xref.PageContent(pageDict)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %v", i+1, err)
+		}
+		content, roles, err := tagContent(content, p.title != "")
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %v", i+1, err)
+		}
+		if p.imagePath != "" && !slices.Contains(roles, "Figure") {
+			return nil, fmt.Errorf("page %d: the image wasn't found in the page content", i+1)
+		}
+
+		stream, err := xref.NewStreamDictForBuf(content)
+		if err != nil {
+			return nil, err
+		}
+		if err := stream.Encode(); err != nil {
+			return nil, err
+		}
+		streamRef, err := xref.IndRefForNewObject(*stream)
+		if err != nil {
+			return nil, err
+		}
+		pageDict.Update("Contents", *streamRef)
+		if len(roles) == 0 {
+			continue
+		}
+
+		// Each page lists its structure elements by marked-content ID in the parent tree
+		var parents types.Array
+		for mcid, role := range roles {
+			element := types.Dict{
+				"Type": types.Name("StructElem"),
+				"S":    types.Name(role),
+				"P":    *documentRef,
+				"Pg":   *pageRef,
+				"K":    types.Integer(mcid),
+			}
+			if role == "Figure" {
+				alt, err := types.EscapeUTF16String(p.alt)
+				if err != nil {
+					return nil, err
+				}
+				element["Alt"] = types.StringLiteral(*alt)
+				figures++
+			}
+			elementRef, err := xref.IndRefForNewObject(element)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, *elementRef)
+			parents = append(parents, *elementRef)
+		}
+		pageDict.Update("StructParents", types.Integer(i))
+		pageDict.Update("Tabs", types.Name("S"))
+		parentTree = append(parentTree, types.Integer(i), parents)
+	}
+
+	parentTreeRef, err := xref.IndRefForNewObject(types.Dict{"Nums": parentTree})
+	if err != nil {
+		return nil, err
+	}
+	document["K"] = elements
+	treeRoot["K"] = *documentRef
+	treeRoot["ParentTree"] = *parentTreeRef
+	treeRoot["ParentTreeNextKey"] = types.Integer(len(pages))
+	catalog.Update("StructTreeRoot", *treeRootRef)
+	catalog.Update("MarkInfo", types.Dict{"Marked": types.Boolean(true)})
+	catalog.Update("Lang", types.StringLiteral(lang))
+
+	var tagged bytes.Buffer
+	if err := api.WriteContext(pdf, &tagged); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(status, "Tagged the PDF for accessibility: %d figure(s) with alternate text, language %s\n", figures, lang)
+	return tagged.Bytes(), nil
+}